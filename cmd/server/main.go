@@ -3,13 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/connect4/backend/internal/analytics"
+	"github.com/connect4/backend/internal/config"
 	"github.com/connect4/backend/internal/database"
+	"github.com/connect4/backend/internal/metrics"
+	"github.com/connect4/backend/internal/tournament"
+	"github.com/connect4/backend/internal/utils"
+	"github.com/connect4/backend/internal/webhook"
 	"github.com/connect4/backend/internal/ws"
 	"github.com/joho/godotenv"
 )
@@ -24,90 +31,30 @@ func main() {
 		}
 	}
 
+	// "migrate status|up|down" manages the schema instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	log.Println("Starting Connect 4 Game Server...")
 
+	// Cleanup functions run in registration order on SIGINT/SIGTERM
+	resources := utils.NewResourceManager()
+
 	// -----------------------------------------
 	// ✅ Initialize Database (Railway or Local)
 	// -----------------------------------------
-	var db *database.DB
+	db := connectDB()
 	var err error
 
-	if os.Getenv("DATABASE_URL") != "" {
-		// Railway DATABASE_URL connection
-		log.Println("[DB] Connecting using DATABASE_URL...")
-		db, err = database.NewDB(database.Config{})
-		if err != nil {
-			log.Fatalf("❌ Database connection failed: %v", err)
-		}
-		log.Println("✅ Database connected successfully (via DATABASE_URL)")
-	} else if os.Getenv("PGHOST") != "" {
-		// Railway PG* environment vars
-		log.Println("[DB] Connecting using PG* environment variables...")
-		portStr := os.Getenv("PGPORT")
-		port, _ := strconv.Atoi(portStr)
-		dbConfig := database.Config{
-			Host:     os.Getenv("PGHOST"),
-			Port:     port,
-			User:     os.Getenv("PGUSER"),
-			Password: os.Getenv("PGPASSWORD"),
-			DBName:   os.Getenv("PGDATABASE"),
-		}
-		db, err = database.NewDB(dbConfig)
-		if err != nil {
-			log.Fatalf("❌ Database connection failed: %v", err)
-		}
-		log.Println("✅ Database connected successfully (via PGHOST)")
-	} else {
-		log.Println("⚠️ No database configuration found. Running without DB.")
-	}
-
 	// -----------------------------------------
-	// 🧱 Auto-create schema (tables + view)
+	// 🧱 Bring the schema up to date
 	// -----------------------------------------
 	if db != nil {
-		schemaSQL := `
-CREATE TABLE IF NOT EXISTS players (
-	id SERIAL PRIMARY KEY,
-	username VARCHAR(50) UNIQUE NOT NULL,
-	games_played INT DEFAULT 0,
-	games_won INT DEFAULT 0,
-	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE TABLE IF NOT EXISTS games (
-	id SERIAL PRIMARY KEY,
-	player1_id INT NOT NULL,
-	player2_id INT,
-	winner_id INT,
-	is_bot_game BOOLEAN DEFAULT FALSE,
-	start_time TIMESTAMP NOT NULL,
-	end_time TIMESTAMP,
-	game_state JSON,
-	FOREIGN KEY (player1_id) REFERENCES players(id),
-	FOREIGN KEY (player2_id) REFERENCES players(id),
-	FOREIGN KEY (winner_id) REFERENCES players(id)
-);
-
-CREATE INDEX IF NOT EXISTS idx_players_games_won ON players(games_won DESC);
-
-CREATE OR REPLACE VIEW leaderboard AS
-SELECT 
-	username,
-	games_played,
-	games_won,
-	CASE 
-		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
-		ELSE 0
-	END AS win_percentage
-FROM players
-WHERE games_played > 0
-ORDER BY games_won DESC, win_percentage DESC;
-`
-		if _, err := db.ExecContext(context.Background(), schemaSQL); err != nil {
-			log.Printf("⚠️ Failed to initialize schema: %v", err)
-		} else {
-			log.Println("✅ Database schema ensured (tables & leaderboard view ready)")
+		if err := db.Migrate(context.Background()); err != nil {
+			log.Fatalf("❌ Database migration failed: %v", err)
 		}
+		log.Println("✅ Database schema up to date")
 	}
 
 	// -----------------------------------------
@@ -122,7 +69,7 @@ ORDER BY games_won DESC, win_percentage DESC;
 			log.Printf("Warning: Kafka producer init failed: %v", err)
 		} else {
 			log.Println("Kafka producer initialized successfully")
-			defer producer.Close()
+			resources.AddCleanupFunc(producer.Close)
 		}
 	}
 
@@ -134,16 +81,13 @@ ORDER BY games_won DESC, win_percentage DESC;
 			log.Printf("Warning: Kafka consumer init failed: %v", err)
 		} else {
 			log.Println("Kafka consumer initialized successfully")
-			if _, err := db.DB.Exec(analytics.CreateAnalyticsTableSQL); err != nil {
-				log.Printf("Warning: Failed to create analytics table: %v", err)
-			}
 			ctx := context.Background()
 			go func() {
 				if err := consumer.Start(ctx, []string{"game-events"}); err != nil {
 					log.Printf("Error in consumer: %v", err)
 				}
 			}()
-			defer consumer.Close()
+			resources.AddCleanupFunc(consumer.Close)
 		}
 	}
 
@@ -151,14 +95,56 @@ ORDER BY games_won DESC, win_percentage DESC;
 	// Initialize WebSocket Hub
 	// -----------------------------------------
 	hub := ws.NewHub()
+
+	// Matchmaking and reconnect timings per game mode (POLICY_* variables)
+	hub.SetPolicies(config.LoadPolicies())
+
 	if db != nil {
 		hub.SetDB(db)
+		hub.SetSnapshotStore(db)
+		if err := hub.RestoreGames(context.Background()); err != nil {
+			log.Printf("Warning: Failed to restore active games: %v", err)
+		}
+		if err := hub.LoadBans(context.Background()); err != nil {
+			log.Printf("Warning: Failed to load bans: %v", err)
+		}
+		if err := hub.LoadBotAccounts(context.Background()); err != nil {
+			log.Printf("Warning: Failed to load bot accounts: %v", err)
+		}
+	}
+
+	// The admin API stays disabled unless a token is configured
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		hub.SetAdminToken(token)
 	}
 	if producer != nil {
 		hub.SetProducer(producer)
 	}
+
+	// Webhooks registered through the admin API receive game and tournament events
+	webhooks := webhook.NewDispatcher()
+	if db != nil {
+		webhooks.SetDB(db)
+		if err := webhooks.Load(context.Background()); err != nil {
+			log.Printf("Warning: Failed to load webhooks: %v", err)
+		}
+	}
+	hub.SetWebhooks(webhooks)
+	resources.AddCleanupFunc(webhooks.Close)
 	go hub.Run()
 
+	// Tournaments run their games on the hub and hear back about results
+	tournaments := tournament.NewManager(hub, hub)
+	tournaments.SetRoundListener(hub)
+	hub.AddResultListener(tournaments)
+
+	// Snapshot live games before the process exits so a deploy does not end them
+	resources.AddCleanupFunc(hub.SnapshotAll)
+	if db != nil {
+		resources.AddCleanupFunc(db.Close)
+	}
+	resources.HandleGracefulShutdown(context.Background())
+
 	// -----------------------------------------
 	// Allowed Origins for CORS
 	// -----------------------------------------
@@ -190,10 +176,38 @@ ORDER BY games_won DESC, win_percentage DESC;
 		ws.ServeWs(hub, w, r)
 	})
 
+	// -----------------------------------------
+	// HTTP Fallback Transport (SSE / long polling)
+	// -----------------------------------------
+	http.HandleFunc("/fallback/", func(w http.ResponseWriter, r *http.Request) {
+		addCORSHeaders(w, r, allowedOrigins)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		hub.HandleFallback(w, r)
+	})
+
+	// -----------------------------------------
+	// REST Game API
+	// -----------------------------------------
+	http.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		addCORSHeaders(w, r, allowedOrigins)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		hub.HandleAPI(w, r)
+	})
+
 	// -----------------------------------------
 	// Leaderboard Endpoint
 	// -----------------------------------------
-	http.HandleFunc("/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+	// Registered bots are ranked separately on /leaderboard/bots. Query
+	// parameters: sort (rating, wins, winRate), window (today, week, month,
+	// all), minGames, humanOnly, limit and cursor; the cursor of the next
+	// page comes back in the X-Next-Cursor header.
+	leaderboardHandler := func(w http.ResponseWriter, r *http.Request) {
 		addCORSHeaders(w, r, allowedOrigins)
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -208,18 +222,54 @@ ORDER BY games_won DESC, win_percentage DESC;
 			json.NewEncoder(w).Encode([]database.Player{})
 			return
 		}
-		ctx := context.Background()
-		players, err := db.GetLeaderboard(ctx, 100)
-		if err != nil {
+		params := r.URL.Query()
+		query := database.LeaderboardQuery{
+			Bots:   r.URL.Path == "/leaderboard/bots",
+			Sort:   params.Get("sort"),
+			Window: params.Get("window"),
+			Cursor: params.Get("cursor"),
+		}
+		var err error
+		if v := params.Get("minGames"); v != "" {
+			if query.MinGames, err = strconv.Atoi(v); err != nil || query.MinGames < 0 {
+				http.Error(w, "minGames must be a non-negative number", http.StatusBadRequest)
+				return
+			}
+		}
+		if v := params.Get("limit"); v != "" {
+			if query.Limit, err = strconv.Atoi(v); err != nil || query.Limit < 1 || query.Limit > database.MaxLeaderboardLimit {
+				http.Error(w, "limit must be between 1 and "+strconv.Itoa(database.MaxLeaderboardLimit), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := params.Get("humanOnly"); v != "" {
+			if query.HumanOnly, err = strconv.ParseBool(v); err != nil {
+				http.Error(w, "humanOnly must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+		page, err := db.Leaderboard(context.Background(), query)
+		switch {
+		case errors.Is(err, database.ErrInvalidSort), errors.Is(err, database.ErrInvalidWindow), errors.Is(err, database.ErrInvalidCursor):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
 			log.Printf("Error fetching leaderboard: %v", err)
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 			return
 		}
+		if page.NextCursor != "" {
+			w.Header().Set("X-Next-Cursor", page.NextCursor)
+		}
+		w.Header().Set("Access-Control-Expose-Headers", "X-Next-Cursor")
+		players := page.Players
 		type PlayerStats struct {
-			Username      string  `json:"username"`
-			GamesPlayed   int     `json:"gamesPlayed"`
-			GamesWon      int     `json:"gamesWon"`
-			WinPercentage float64 `json:"winPercentage"`
+			Username        string  `json:"username"`
+			Rating          int     `json:"rating"`
+			RatingDeviation int     `json:"ratingDeviation"`
+			GamesPlayed     int     `json:"gamesPlayed"`
+			GamesWon        int     `json:"gamesWon"`
+			WinPercentage   float64 `json:"winPercentage"`
 		}
 		stats := make([]PlayerStats, len(players))
 		for i, p := range players {
@@ -228,13 +278,48 @@ ORDER BY games_won DESC, win_percentage DESC;
 				winPct = float64(p.GamesWon) / float64(p.GamesPlayed) * 100
 			}
 			stats[i] = PlayerStats{
-				Username:      p.Username,
-				GamesPlayed:   p.GamesPlayed,
-				GamesWon:      p.GamesWon,
-				WinPercentage: winPct,
+				Username:        p.Username,
+				Rating:          p.Rating,
+				RatingDeviation: p.RatingDeviation,
+				GamesPlayed:     p.GamesPlayed,
+				GamesWon:        p.GamesWon,
+				WinPercentage:   winPct,
 			}
 		}
 		json.NewEncoder(w).Encode(stats)
+	}
+	http.HandleFunc("/leaderboard", leaderboardHandler)
+	http.HandleFunc("/leaderboard/bots", leaderboardHandler)
+
+	// -----------------------------------------
+	// Rating History Endpoint
+	// -----------------------------------------
+	http.HandleFunc("/ratings/", func(w http.ResponseWriter, r *http.Request) {
+		addCORSHeaders(w, r, allowedOrigins)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		username := strings.TrimPrefix(r.URL.Path, "/ratings/")
+		if username == "" || strings.Contains(username, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		if db == nil {
+			json.NewEncoder(w).Encode([]database.RatingChange{})
+			return
+		}
+		history, err := db.GetRatingHistory(context.Background(), username, 100)
+		if err != nil {
+			log.Printf("Error fetching rating history: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(history)
 	})
 
 	// -----------------------------------------
@@ -249,6 +334,30 @@ ORDER BY games_won DESC, win_percentage DESC;
 		hub.HandleActiveUsers(w, r)
 	})
 
+	// -----------------------------------------
+	// Tournament Endpoints
+	// -----------------------------------------
+	tournamentHandler := func(w http.ResponseWriter, r *http.Request) {
+		addCORSHeaders(w, r, allowedOrigins)
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		tournaments.HandleTournaments(w, r)
+	}
+	http.HandleFunc("/tournaments", tournamentHandler)
+	http.HandleFunc("/tournaments/", tournamentHandler)
+
+	// -----------------------------------------
+	// Admin Endpoints (bearer token, no CORS)
+	// -----------------------------------------
+	http.HandleFunc("/admin/", hub.HandleAdmin)
+
+	// -----------------------------------------
+	// Metrics Endpoint
+	// -----------------------------------------
+	http.Handle("/metrics", metrics.Handler())
+
 	// -----------------------------------------
 	// Default Route
 	// -----------------------------------------
@@ -309,3 +418,40 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// connectDB opens the database configured in the environment, or returns
+// nil when none is configured
+func connectDB() *database.DB {
+	var db *database.DB
+	var err error
+
+	if os.Getenv("DATABASE_URL") != "" {
+		// Railway DATABASE_URL connection
+		log.Println("[DB] Connecting using DATABASE_URL...")
+		db, err = database.NewDB(database.Config{})
+		if err != nil {
+			log.Fatalf("❌ Database connection failed: %v", err)
+		}
+		log.Println("✅ Database connected successfully (via DATABASE_URL)")
+	} else if os.Getenv("PGHOST") != "" {
+		// Railway PG* environment vars
+		log.Println("[DB] Connecting using PG* environment variables...")
+		portStr := os.Getenv("PGPORT")
+		port, _ := strconv.Atoi(portStr)
+		dbConfig := database.Config{
+			Host:     os.Getenv("PGHOST"),
+			Port:     port,
+			User:     os.Getenv("PGUSER"),
+			Password: os.Getenv("PGPASSWORD"),
+			DBName:   os.Getenv("PGDATABASE"),
+		}
+		db, err = database.NewDB(dbConfig)
+		if err != nil {
+			log.Fatalf("❌ Database connection failed: %v", err)
+		}
+		log.Println("✅ Database connected successfully (via PGHOST)")
+	} else {
+		log.Println("⚠️ No database configuration found. Running without DB.")
+	}
+	return db
+}
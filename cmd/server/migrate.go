@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/connect4/backend/internal/database"
+)
+
+const migrateUsage = `usage: server migrate <command>
+
+  status        list migrations and whether they are applied
+  up [VERSION]  apply pending migrations, up to VERSION if given
+  down [STEPS]  revert the latest STEPS applied migrations (default 1)`
+
+// runMigrate runs the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	n := 0
+	if len(args) == 2 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+	}
+	if args[0] != "status" && args[0] != "up" && args[0] != "down" {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db := connectDB()
+	if db == nil {
+		fmt.Fprintln(os.Stderr, "migrate: no database configured (set DATABASE_URL or PGHOST)")
+		return 1
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatus(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Unknown {
+				state += " (not in this build)"
+			}
+			fmt.Printf("%04d  %-28s %s\n", s.Version, s.Name, state)
+		}
+
+	case "up":
+		applied, err := db.MigrateUp(ctx, n)
+		printMigrations("applied", applied)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		reverted, err := db.MigrateDown(ctx, n)
+		printMigrations("reverted", reverted)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func printMigrations(verb string, migrations []database.Migration) {
+	if len(migrations) == 0 {
+		fmt.Printf("nothing %s\n", verb)
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %04d_%s\n", verb, m.Version, m.Name)
+	}
+}
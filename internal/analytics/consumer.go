@@ -122,7 +122,7 @@ func (h *ConsumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 					INSERT INTO failed_events (
 						topic,
 						partition,
-						offset,
+						"offset",
 						message,
 						error,
 						timestamp
@@ -157,24 +157,12 @@ func (h *ConsumerGroupHandler) processEvent(event GameEvent) error {
 	return nil
 }
 
-// Database schema for analytics
-const (
-	CreateAnalyticsTableSQL = `
-		CREATE TABLE IF NOT EXISTS game_analytics (
-			game_id TEXT,
-			event_type TEXT,
-			event_time TIMESTAMP,
-			player TEXT,
-			duration FLOAT,
-			is_bot_game BOOLEAN,
-			additional_data JSONB
-		)`
-
-	insertAnalyticsSQL = `
-		INSERT INTO game_analytics (
-			game_id, event_type, event_time, player, duration, is_bot_game, additional_data
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-)
+// insertAnalyticsSQL records one event. game_analytics and failed_events
+// are created by the database migrations.
+const insertAnalyticsSQL = `
+	INSERT INTO game_analytics (
+		game_id, event_type, event_time, player, duration, is_bot_game, additional_data
+	) VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
 func (h *ConsumerGroupHandler) processGameEndEvent(event GameEvent) error {
 	winner, ok := event.Data["winner"].(string)
@@ -0,0 +1,81 @@
+package backplane
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Envelope kinds exchanged between hub instances
+const (
+	KindDeliver    = "deliver"    // send Data to a player connected to the receiving instance
+	KindForward    = "forward"    // a player's message for the game owned by the receiving instance
+	KindDisconnect = "disconnect" // a remote player's socket went away
+	KindBroadcast  = "broadcast"  // send Data to every player on the receiving instance
+	KindPresence   = "presence"   // send Data to presence subscribers on the receiving instance
+)
+
+// Envelope is a message routed between instances. It is plain JSON so that
+// network-backed implementations can carry it unchanged.
+type Envelope struct {
+	Kind     string          `json:"kind"`
+	From     string          `json:"from"`
+	Username string          `json:"username,omitempty"`
+	GameID   string          `json:"gameId,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// Presence describes an online player and the instance holding its socket
+type Presence struct {
+	Username   string    `json:"username"`
+	InstanceID string    `json:"instanceId"`
+	Status     string    `json:"status"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// WaitingEntry is a player waiting in the shared matchmaking queue
+type WaitingEntry struct {
+	Username   string    `json:"username"`
+	InstanceID string    `json:"instanceId"`
+	QueuedAt   time.Time `json:"queuedAt"`
+	Rating     int       `json:"rating"`
+}
+
+// Backplane connects hubs running on different server instances. It routes
+// messages between them and keeps the state that must be shared: which
+// instance owns each game, who is online, and who is waiting for a match.
+type Backplane interface {
+	// Subscribe registers the handler for envelopes addressed to instanceID.
+	// Envelopes from one sender are delivered in the order they were published.
+	Subscribe(instanceID string, handler func(Envelope)) error
+	// Publish sends an envelope to a single instance
+	Publish(instanceID string, env Envelope) error
+	// PublishAll sends an envelope to every instance except the sender
+	PublishAll(env Envelope) error
+
+	// ClaimGame records instanceID as the owner of gameID
+	ClaimGame(gameID, instanceID string) error
+	// GameOwner returns the instance that owns gameID
+	GameOwner(gameID string) (string, bool)
+	// ReleaseGame forgets the owner of gameID
+	ReleaseGame(gameID string)
+
+	// SetPresence creates or updates a player's presence entry
+	SetPresence(p Presence)
+	// RemovePresence deletes the presence entry if it belongs to instanceID
+	RemovePresence(username, instanceID string)
+	// ListPresence returns every online player across all instances
+	ListPresence() []Presence
+
+	// EnqueueWaiting adds a player to the shared matchmaking queue
+	EnqueueWaiting(entry WaitingEntry)
+	// RemoveWaiting removes a player from the queue. It returns false if the
+	// player was no longer queued, e.g. because another instance took it.
+	RemoveWaiting(username, instanceID string) bool
+	// TakeWaiting atomically pops the oldest player queued on an instance
+	// other than excludeInstance that accept agrees to
+	TakeWaiting(excludeInstance string, accept func(WaitingEntry) bool) (WaitingEntry, bool)
+	// PairWaiting atomically removes a player queued on instanceID together
+	// with the oldest player queued elsewhere that accept agrees to. It
+	// returns false, removing nobody, if either is missing.
+	PairWaiting(username, instanceID string, accept func(WaitingEntry) bool) (WaitingEntry, bool)
+}
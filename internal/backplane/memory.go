@@ -0,0 +1,208 @@
+package backplane
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// subscriberBuffer is the number of envelopes queued per instance
+const subscriberBuffer = 1024
+
+// Memory is an in-process Backplane. It lets several hubs in one process
+// (typically tests) behave like separate server instances.
+type Memory struct {
+	mu          sync.Mutex
+	subscribers map[string]chan Envelope
+	owners      map[string]string
+	presence    map[string]Presence
+	queue       []WaitingEntry
+}
+
+// NewMemory creates an empty in-memory backplane
+func NewMemory() *Memory {
+	return &Memory{
+		subscribers: make(map[string]chan Envelope),
+		owners:      make(map[string]string),
+		presence:    make(map[string]Presence),
+	}
+}
+
+// Subscribe registers the handler for envelopes addressed to instanceID
+func (m *Memory) Subscribe(instanceID string, handler func(Envelope)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.subscribers[instanceID]; exists {
+		return fmt.Errorf("instance %s is already subscribed", instanceID)
+	}
+	ch := make(chan Envelope, subscriberBuffer)
+	m.subscribers[instanceID] = ch
+
+	// A single goroutine per instance keeps delivery ordered
+	go func() {
+		for env := range ch {
+			handler(env)
+		}
+	}()
+	return nil
+}
+
+// Publish sends an envelope to a single instance
+func (m *Memory) Publish(instanceID string, env Envelope) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch, exists := m.subscribers[instanceID]
+	if !exists {
+		return fmt.Errorf("unknown instance %s", instanceID)
+	}
+	select {
+	case ch <- env:
+		return nil
+	default:
+		return fmt.Errorf("instance %s is not keeping up", instanceID)
+	}
+}
+
+// PublishAll sends an envelope to every instance except the sender
+func (m *Memory) PublishAll(env Envelope) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for instanceID, ch := range m.subscribers {
+		if instanceID == env.From {
+			continue
+		}
+		select {
+		case ch <- env:
+		default:
+			log.Printf("[BACKPLANE] Dropping broadcast for slow instance %s", instanceID)
+		}
+	}
+	return nil
+}
+
+// ClaimGame records instanceID as the owner of gameID
+func (m *Memory) ClaimGame(gameID, instanceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if owner, exists := m.owners[gameID]; exists && owner != instanceID {
+		return fmt.Errorf("game %s is owned by %s", gameID, owner)
+	}
+	m.owners[gameID] = instanceID
+	return nil
+}
+
+// GameOwner returns the instance that owns gameID
+func (m *Memory) GameOwner(gameID string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner, exists := m.owners[gameID]
+	return owner, exists
+}
+
+// ReleaseGame forgets the owner of gameID
+func (m *Memory) ReleaseGame(gameID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.owners, gameID)
+}
+
+// SetPresence creates or updates a player's presence entry
+func (m *Memory) SetPresence(p Presence) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.presence[p.Username] = p
+}
+
+// RemovePresence deletes the presence entry if it belongs to instanceID
+func (m *Memory) RemovePresence(username, instanceID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if p, exists := m.presence[username]; exists && p.InstanceID == instanceID {
+		delete(m.presence, username)
+	}
+}
+
+// ListPresence returns every online player across all instances
+func (m *Memory) ListPresence() []Presence {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Presence, 0, len(m.presence))
+	for _, p := range m.presence {
+		list = append(list, p)
+	}
+	return list
+}
+
+// EnqueueWaiting adds a player to the shared matchmaking queue
+func (m *Memory) EnqueueWaiting(entry WaitingEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, entry)
+}
+
+// RemoveWaiting removes a player from the queue
+func (m *Memory) RemoveWaiting(username, instanceID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, entry := range m.queue {
+		if entry.Username == username && entry.InstanceID == instanceID {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// TakeWaiting atomically pops the oldest acceptable player queued on
+// another instance
+func (m *Memory) TakeWaiting(excludeInstance string, accept func(WaitingEntry) bool) (WaitingEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, entry := range m.queue {
+		if entry.InstanceID != excludeInstance && accept(entry) {
+			m.queue = append(m.queue[:i], m.queue[i+1:]...)
+			return entry, true
+		}
+	}
+	return WaitingEntry{}, false
+}
+
+// PairWaiting atomically removes a queued player and an acceptable partner
+// queued on another instance
+func (m *Memory) PairWaiting(username, instanceID string, accept func(WaitingEntry) bool) (WaitingEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	own := -1
+	for i, entry := range m.queue {
+		if entry.Username == username && entry.InstanceID == instanceID {
+			own = i
+			break
+		}
+	}
+	if own < 0 {
+		return WaitingEntry{}, false
+	}
+	for i, entry := range m.queue {
+		if entry.InstanceID == instanceID || !accept(entry) {
+			continue
+		}
+		first, second := own, i
+		if first > second {
+			first, second = second, first
+		}
+		m.queue = append(m.queue[:second], m.queue[second+1:]...)
+		m.queue = append(m.queue[:first], m.queue[first+1:]...)
+		return entry, true
+	}
+	return WaitingEntry{}, false
+}
@@ -61,6 +61,24 @@ func (b *Bot) CalculateNextMove(board [][]int) int {
 	return bestMove
 }
 
+// CalculateMoveAs determines the best move for whichever side is to move.
+// The search always plays as player 2, so for player 1 the colors are
+// swapped before searching.
+func (b *Bot) CalculateMoveAs(board [][]int, player int) int {
+	if player == botPlayer {
+		return b.CalculateNextMove(board)
+	}
+	swapped := copyBoard(board)
+	for _, row := range swapped {
+		for col, cell := range row {
+			if cell != 0 {
+				row[col] = 3 - cell
+			}
+		}
+	}
+	return b.CalculateNextMove(swapped)
+}
+
 // minimax implements the minimax algorithm with alpha-beta pruning
 func minimax(board [][]int, depth int, alpha, beta float64, maximizing bool) float64 {
 	// Check terminal conditions
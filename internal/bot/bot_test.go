@@ -0,0 +1,56 @@
+package bot
+
+import (
+	"reflect"
+	"testing"
+)
+
+// boardWith returns an empty board with discs placed at {row, col, player}
+func boardWith(discs ...[3]int) [][]int {
+	board := make([][]int, 6)
+	for i := range board {
+		board[i] = make([]int, 7)
+	}
+	for _, d := range discs {
+		board[d[0]][d[1]] = d[2]
+	}
+	return board
+}
+
+func TestCalculateMoveAs(t *testing.T) {
+	tests := []struct {
+		name   string
+		board  [][]int
+		player int
+		want   int
+	}{
+		{"player 1 wins", boardWith([3]int{5, 0, 1}, [3]int{5, 1, 1}, [3]int{5, 2, 1}, [3]int{4, 0, 2}, [3]int{4, 1, 2}, [3]int{3, 0, 2}), 1, 3},
+		{"player 2 blocks", boardWith([3]int{5, 0, 1}, [3]int{5, 1, 1}, [3]int{5, 2, 1}, [3]int{4, 0, 2}, [3]int{4, 1, 2}, [3]int{3, 0, 2}), 2, 3},
+		{"player 1 blocks", boardWith([3]int{5, 4, 2}, [3]int{5, 5, 2}, [3]int{5, 6, 2}, [3]int{4, 4, 1}, [3]int{4, 5, 1}, [3]int{3, 4, 1}), 1, 3},
+		{"player 2 wins", boardWith([3]int{5, 4, 2}, [3]int{5, 5, 2}, [3]int{5, 6, 2}, [3]int{4, 4, 1}, [3]int{4, 5, 1}, [3]int{3, 4, 1}), 2, 3},
+		// Both sides can win; each takes its own win over blocking
+		{"player 1 wins before player 2", boardWith([3]int{5, 0, 1}, [3]int{5, 1, 1}, [3]int{5, 2, 1}, [3]int{5, 6, 2}, [3]int{4, 6, 2}, [3]int{3, 6, 2}), 1, 3},
+		{"player 2 wins before player 1", boardWith([3]int{5, 0, 1}, [3]int{5, 1, 1}, [3]int{5, 2, 1}, [3]int{5, 6, 2}, [3]int{4, 6, 2}, [3]int{3, 6, 2}), 2, 6},
+	}
+	b := NewBot()
+	for _, tt := range tests {
+		before := copyBoard(tt.board)
+		if got := b.CalculateMoveAs(tt.board, tt.player); got != tt.want {
+			t.Errorf("%s: column %d, want %d", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(tt.board, before) {
+			t.Errorf("%s: the board was changed", tt.name)
+		}
+	}
+}
+
+// Playing as player 1 is playing as player 2 on the board with the colors
+// swapped
+func TestCalculateMoveAsSwapsColors(t *testing.T) {
+	board := boardWith([3]int{5, 3, 1}, [3]int{4, 3, 2}, [3]int{5, 2, 1}, [3]int{5, 4, 2}, [3]int{3, 3, 1})
+	swapped := boardWith([3]int{5, 3, 2}, [3]int{4, 3, 1}, [3]int{5, 2, 2}, [3]int{5, 4, 1}, [3]int{3, 3, 2})
+	b := NewBot()
+	if as1, as2 := b.CalculateMoveAs(board, 1), b.CalculateMoveAs(swapped, 2); as1 != as2 {
+		t.Errorf("player 1 plays column %d, player 2 on the swapped board plays %d", as1, as2)
+	}
+}
@@ -11,6 +11,7 @@ type Config struct {
 	Database DatabaseConfig
 	Kafka    KafkaConfig
 	Security SecurityConfig
+	Policies PolicyConfig
 }
 
 type ServerConfig struct {
@@ -53,6 +54,7 @@ func LoadConfig() (*Config, error) {
 		Security: SecurityConfig{
 			AllowedOrigins: strings.Split(getEnv("ALLOWED_ORIGINS", "http://localhost:3000"), ","),
 		},
+		Policies: LoadPolicies(),
 	}, nil
 }
 
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Game modes that can carry their own policy
+const (
+	ModeFriend     = "friend"     // random matchmaking against another player
+	ModeComputer   = "computer"   // immediate game against the bot
+	ModeChallenge  = "challenge"  // direct challenge between two online players
+	ModeTournament = "tournament" // game of a tournament round
+)
+
+// What happens to a game when a disconnected player's grace period runs out
+const (
+	AbandonCancel  = "cancel"  // the game is dropped without a result
+	AbandonForfeit = "forfeit" // the absent player loses
+)
+
+// How sides are assigned when players agree to a rematch
+const (
+	RematchSwap   = "swap"   // the other player moves first
+	RematchKeep   = "keep"   // the same player moves first again
+	RematchRandom = "random" // a coin flip decides who moves first
+)
+
+// GamePolicy holds the matchmaking and reconnect timings for one game mode
+type GamePolicy struct {
+	BotFallback      bool          // pair a waiting player with the bot when nobody shows up
+	BotFallbackDelay time.Duration // how long to wait for an opponent first
+	BotTakeover      bool          // let the bot play on for a player who disconnects
+	BotTakeoverDelay time.Duration // how long to wait for the player before the bot steps in
+	ReconnectGrace   time.Duration // how long a disconnected player keeps their seat
+	Abandonment      string        // AbandonCancel or AbandonForfeit
+	BotThinkDelay    time.Duration // pause before the bot plays its move
+	RematchSides     string        // RematchSwap, RematchKeep or RematchRandom
+	BotRating        float64       // rating the bot plays at; 0 leaves bot games unrated
+	// Matchmaking pairs players whose ratings are at most RatingWindow
+	// apart, widening by RatingWindowGrowth for every second they wait.
+	// A window of 0 pairs anyone.
+	RatingWindow       int
+	RatingWindowGrowth int
+}
+
+// PolicyConfig holds the policy of every game mode
+type PolicyConfig struct {
+	Modes map[string]GamePolicy
+}
+
+// DefaultGamePolicy is the policy used when nothing is configured
+func DefaultGamePolicy() GamePolicy {
+	return GamePolicy{
+		BotFallback:        true,
+		BotFallbackDelay:   10 * time.Second,
+		BotTakeover:        true,
+		BotTakeoverDelay:   10 * time.Second,
+		ReconnectGrace:     30 * time.Second,
+		Abandonment:        AbandonCancel,
+		BotThinkDelay:      500 * time.Millisecond,
+		RematchSides:       RematchSwap,
+		BotRating:          1500,
+		RatingWindow:       100,
+		RatingWindowGrowth: 10,
+	}
+}
+
+// DefaultPolicies returns the default policy for every mode
+func DefaultPolicies() PolicyConfig {
+	modes := make(map[string]GamePolicy)
+	for _, mode := range []string{ModeFriend, ModeComputer, ModeChallenge} {
+		modes[mode] = DefaultGamePolicy()
+	}
+	// Tournament games are decided by the players themselves
+	tournament := DefaultGamePolicy()
+	tournament.BotFallback = false
+	tournament.BotTakeover = false
+	tournament.Abandonment = AbandonForfeit
+	modes[ModeTournament] = tournament
+	return PolicyConfig{Modes: modes}
+}
+
+// For returns the policy of a mode, falling back to the default policy
+func (p PolicyConfig) For(mode string) GamePolicy {
+	if policy, ok := p.Modes[mode]; ok {
+		return policy
+	}
+	return DefaultGamePolicy()
+}
+
+// LoadPolicies reads the policy of every mode from the environment. Each
+// setting can be given for all modes (e.g. POLICY_RECONNECT_GRACE=45s) and
+// overridden per mode (e.g. POLICY_COMPUTER_BOT_THINK_DELAY=1s).
+func LoadPolicies() PolicyConfig {
+	policies := DefaultPolicies()
+	for mode, policy := range policies.Modes {
+		prefix := "POLICY_" + strings.ToUpper(mode) + "_"
+		lookup := func(name string) string {
+			if value := os.Getenv(prefix + name); value != "" {
+				return value
+			}
+			return os.Getenv("POLICY_" + name)
+		}
+
+		policy.BotFallback = parseBool(lookup("BOT_FALLBACK"), policy.BotFallback)
+		policy.BotFallbackDelay = parseDuration(lookup("BOT_FALLBACK_DELAY"), policy.BotFallbackDelay)
+		policy.BotTakeover = parseBool(lookup("BOT_TAKEOVER"), policy.BotTakeover)
+		policy.BotTakeoverDelay = parsePositiveDuration(lookup("BOT_TAKEOVER_DELAY"), policy.BotTakeoverDelay)
+		policy.ReconnectGrace = parsePositiveDuration(lookup("RECONNECT_GRACE"), policy.ReconnectGrace)
+		policy.BotThinkDelay = parseDuration(lookup("BOT_THINK_DELAY"), policy.BotThinkDelay)
+		policy.BotRating = parseRating(lookup("BOT_RATING"), policy.BotRating)
+		policy.RatingWindow = parseCount(lookup("RATING_WINDOW"), policy.RatingWindow)
+		policy.RatingWindowGrowth = parseCount(lookup("RATING_WINDOW_GROWTH"), policy.RatingWindowGrowth)
+		switch value := lookup("ABANDONMENT"); value {
+		case AbandonCancel, AbandonForfeit:
+			policy.Abandonment = value
+		}
+		// A cancelled tournament game never reports a result and would
+		// hold up its round for good
+		if mode == ModeTournament {
+			policy.Abandonment = AbandonForfeit
+		}
+		switch value := lookup("REMATCH_SIDES"); value {
+		case RematchSwap, RematchKeep, RematchRandom:
+			policy.RematchSides = value
+		}
+		policies.Modes[mode] = policy
+	}
+	return policies
+}
+
+func parseBool(value string, defaultValue bool) bool {
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return defaultValue
+}
+
+// parseCount reads a non-negative number
+func parseCount(value string, defaultValue int) int {
+	if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+		return n
+	}
+	return defaultValue
+}
+
+// parseRating reads a rating; zero or less turns rating off
+func parseRating(value string, defaultValue float64) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	if f <= 0 {
+		return 0
+	}
+	return f
+}
+
+// parseDuration accepts Go durations ("1m30s") or plain seconds ("90")
+func parseDuration(value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return defaultValue
+}
+
+// parsePositiveDuration is parseDuration for the reconnect timers, which are
+// re-armed when they fire: a zero duration would spin them in a loop
+func parsePositiveDuration(value string, defaultValue time.Duration) time.Duration {
+	if d := parseDuration(value, defaultValue); d > 0 {
+		return d
+	}
+	return defaultValue
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadPoliciesOverrides(t *testing.T) {
+	t.Setenv("POLICY_RECONNECT_GRACE", "45s")
+	t.Setenv("POLICY_COMPUTER_BOT_THINK_DELAY", "2")
+	t.Setenv("POLICY_FRIEND_ABANDONMENT", AbandonForfeit)
+
+	policies := LoadPolicies()
+	for mode, policy := range policies.Modes {
+		if policy.ReconnectGrace != 45*time.Second {
+			t.Errorf("%s: reconnect grace = %v, want 45s", mode, policy.ReconnectGrace)
+		}
+	}
+	if got := policies.For(ModeComputer).BotThinkDelay; got != 2*time.Second {
+		t.Errorf("computer bot think delay = %v, want 2s", got)
+	}
+	if got := policies.For(ModeChallenge).BotThinkDelay; got != DefaultGamePolicy().BotThinkDelay {
+		t.Errorf("challenge bot think delay = %v, want the default", got)
+	}
+	if got := policies.For(ModeFriend).Abandonment; got != AbandonForfeit {
+		t.Errorf("friend abandonment = %q, want %q", got, AbandonForfeit)
+	}
+}
+
+// Tournament rounds only end when every game has a result, so no setting
+// may let a tournament game be cancelled
+func TestLoadPoliciesTournamentAlwaysForfeits(t *testing.T) {
+	for _, env := range []string{"POLICY_ABANDONMENT", "POLICY_TOURNAMENT_ABANDONMENT"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, AbandonCancel)
+			policies := LoadPolicies()
+			if got := policies.For(ModeTournament).Abandonment; got != AbandonForfeit {
+				t.Errorf("tournament abandonment = %q, want %q", got, AbandonForfeit)
+			}
+			if env == "POLICY_ABANDONMENT" {
+				if got := policies.For(ModeFriend).Abandonment; got != AbandonCancel {
+					t.Errorf("friend abandonment = %q, want %q", got, AbandonCancel)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", time.Minute},
+		{"90", 90 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"250ms", 250 * time.Millisecond},
+		{"-5s", time.Minute},
+		{"soon", time.Minute},
+	}
+	for _, tt := range tests {
+		if got := parseDuration(tt.value, time.Minute); got != tt.want {
+			t.Errorf("parseDuration(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	if got := parseDuration("0", time.Minute); got != 0 {
+		t.Errorf("parseDuration(\"0\") = %v, want 0", got)
+	}
+}
+
+// The reconnect timers re-arm when they fire, so a zero or negative setting
+// keeps the default instead of spinning them
+func TestReconnectTimersRejectZero(t *testing.T) {
+	defaults := DefaultGamePolicy()
+	for _, value := range []string{"0", "0s", "0ms", "-1", "-30s"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv("POLICY_RECONNECT_GRACE", value)
+			t.Setenv("POLICY_BOT_TAKEOVER_DELAY", value)
+			t.Setenv("POLICY_BOT_FALLBACK_DELAY", value)
+			for mode, policy := range LoadPolicies().Modes {
+				if policy.ReconnectGrace != defaults.ReconnectGrace {
+					t.Errorf("%s: reconnect grace = %v, want %v", mode, policy.ReconnectGrace, defaults.ReconnectGrace)
+				}
+				if policy.BotTakeoverDelay != defaults.BotTakeoverDelay {
+					t.Errorf("%s: bot takeover delay = %v, want %v", mode, policy.BotTakeoverDelay, defaults.BotTakeoverDelay)
+				}
+				// Falling back to the bot at once is a valid choice
+				if value == "0" && policy.BotFallbackDelay != 0 {
+					t.Errorf("%s: bot fallback delay = %v, want 0", mode, policy.BotFallbackDelay)
+				}
+			}
+		})
+	}
+
+	t.Setenv("POLICY_RECONNECT_GRACE", "1ms")
+	if got := LoadPolicies().For(ModeFriend).ReconnectGrace; got != time.Millisecond {
+		t.Errorf("reconnect grace of 1ms = %v", got)
+	}
+}
+
+func TestLoadPoliciesRematchSides(t *testing.T) {
+	t.Setenv("POLICY_REMATCH_SIDES", RematchKeep)
+	t.Setenv("POLICY_COMPUTER_REMATCH_SIDES", RematchRandom)
+
+	policies := LoadPolicies()
+	for mode, want := range map[string]string{ModeFriend: RematchKeep, ModeComputer: RematchRandom, ModeChallenge: RematchKeep} {
+		if got := policies.For(mode).RematchSides; got != want {
+			t.Errorf("%s rematch sides = %q, want %q", mode, got, want)
+		}
+	}
+
+	// Unknown policies are ignored
+	t.Setenv("POLICY_REMATCH_SIDES", "alternate")
+	if got := LoadPolicies().For(ModeFriend).RematchSides; got != RematchSwap {
+		t.Errorf("rematch sides = %q, want the default %q", got, RematchSwap)
+	}
+}
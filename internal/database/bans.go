@@ -0,0 +1,55 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Ban is a username that may not join
+type Ban struct {
+	Username string    `json:"username"`
+	Reason   string    `json:"reason"`
+	BannedAt time.Time `json:"bannedAt"`
+}
+
+// BanUser creates or updates a ban
+func (db *DB) BanUser(ctx context.Context, username, reason string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO banned_users (username, reason, banned_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (username) DO UPDATE
+		SET reason = EXCLUDED.reason, banned_at = EXCLUDED.banned_at`,
+		username, reason)
+	if err != nil {
+		return fmt.Errorf("failed to ban user: %w", err)
+	}
+	return nil
+}
+
+// UnbanUser lifts a ban
+func (db *DB) UnbanUser(ctx context.Context, username string) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM banned_users WHERE username = $1`, username); err != nil {
+		return fmt.Errorf("failed to unban user: %w", err)
+	}
+	return nil
+}
+
+// ListBans returns every ban
+func (db *DB) ListBans(ctx context.Context) ([]Ban, error) {
+	rows, err := db.QueryContext(ctx, `SELECT username, reason, banned_at FROM banned_users ORDER BY banned_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bans: %w", err)
+	}
+	defer rows.Close()
+
+	var bans []Ban
+	for rows.Next() {
+		var b Ban
+		if err := rows.Scan(&b.Username, &b.Reason, &b.BannedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ban: %w", err)
+		}
+		bans = append(bans, b)
+	}
+	return bans, rows.Err()
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUsernameInUse is returned when a bot is registered under the name of
+// a person who has already played
+var ErrUsernameInUse = errors.New("username belongs to a human player")
+
+// BotAccount is a registered third-party bot. Only a hash of its API key is
+// stored.
+type BotAccount struct {
+	Username   string    `json:"username"`
+	APIKeyHash string    `json:"-"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SaveBotAccount registers a bot or replaces its API key, and marks its
+// player record as a bot
+func (db *DB) SaveBotAccount(ctx context.Context, username, apiKeyHash string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO players (username, is_bot)
+		VALUES ($1, TRUE)
+		ON CONFLICT (username) DO UPDATE
+		SET is_bot = TRUE
+		WHERE players.is_bot OR players.games_played = 0`,
+		username)
+	if err != nil {
+		return fmt.Errorf("failed to create bot player: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrUsernameInUse
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO bot_accounts (username, api_key_hash, created_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (username) DO UPDATE
+		SET api_key_hash = EXCLUDED.api_key_hash`,
+		username, apiKeyHash)
+	if err != nil {
+		return fmt.Errorf("failed to save bot account: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteBotAccount revokes a bot's API key. Its games stay on the bot
+// leaderboard.
+func (db *DB) DeleteBotAccount(ctx context.Context, username string) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM bot_accounts WHERE username = $1`, username); err != nil {
+		return fmt.Errorf("failed to delete bot account: %w", err)
+	}
+	return nil
+}
+
+// ListBotAccounts returns every registered bot
+func (db *DB) ListBotAccounts(ctx context.Context) ([]BotAccount, error) {
+	rows, err := db.QueryContext(ctx, `SELECT username, api_key_hash, created_at FROM bot_accounts ORDER BY username`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list bot accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var bots []BotAccount
+	for rows.Next() {
+		var b BotAccount
+		if err := rows.Scan(&b.Username, &b.APIKeyHash, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan bot account: %w", err)
+		}
+		bots = append(bots, b)
+	}
+	return bots, rows.Err()
+}
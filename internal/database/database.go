@@ -36,11 +36,13 @@ func getenv(key, fallback string) string {
 
 // Player represents a player in the database
 type Player struct {
-	ID          int       `json:"id"`
-	Username    string    `json:"username"`
-	GamesPlayed int       `json:"gamesPlayed"`
-	GamesWon    int       `json:"gamesWon"`
-	CreatedAt   time.Time `json:"createdAt"`
+	ID              int       `json:"id"`
+	Username        string    `json:"username"`
+	GamesPlayed     int       `json:"gamesPlayed"`
+	GamesWon        int       `json:"gamesWon"`
+	Rating          int       `json:"rating"` // Glicko-2, rounded for display
+	RatingDeviation int       `json:"ratingDeviation"`
+	CreatedAt       time.Time `json:"createdAt"`
 }
 
 // Game represents a game record in the database
@@ -98,7 +100,7 @@ func (db *DB) CreatePlayer(ctx context.Context, username string) (*Player, error
 	query := `
 		INSERT INTO players (username)
 		VALUES ($1)
-		RETURNING id, username, games_played, games_won, created_at`
+		RETURNING id, username, games_played, games_won, ROUND(rating)::int, ROUND(rating_deviation)::int, created_at`
 
 	var player Player
 	err := db.QueryRowContext(ctx, query, username).Scan(
@@ -106,6 +108,8 @@ func (db *DB) CreatePlayer(ctx context.Context, username string) (*Player, error
 		&player.Username,
 		&player.GamesPlayed,
 		&player.GamesWon,
+		&player.Rating,
+		&player.RatingDeviation,
 		&player.CreatedAt,
 	)
 	if err != nil {
@@ -117,7 +121,7 @@ func (db *DB) CreatePlayer(ctx context.Context, username string) (*Player, error
 // GetPlayer retrieves a player by username
 func (db *DB) GetPlayer(ctx context.Context, username string) (*Player, error) {
 	query := `
-		SELECT id, username, games_played, games_won, created_at
+		SELECT id, username, games_played, games_won, ROUND(rating)::int, ROUND(rating_deviation)::int, created_at
 		FROM players
 		WHERE username = $1`
 
@@ -127,6 +131,8 @@ func (db *DB) GetPlayer(ctx context.Context, username string) (*Player, error) {
 		&player.Username,
 		&player.GamesPlayed,
 		&player.GamesWon,
+		&player.Rating,
+		&player.RatingDeviation,
 		&player.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -166,8 +172,11 @@ func (db *DB) CreateGame(ctx context.Context, player1ID int, player2ID *int, isB
 	return &game, nil
 }
 
-// UpdateGameResult updates the game result
-func (db *DB) UpdateGameResult(ctx context.Context, gameID, winnerID int, gameState map[string]interface{}) error {
+// UpdateGameResult updates the game result, the players' statistics and
+// their ratings. winnerID is 0 for draws and games the built-in bot won.
+// Bot games are rated as if the bot were rated botRating, or left unrated
+// when botRating is 0.
+func (db *DB) UpdateGameResult(ctx context.Context, gameID, winnerID int, isDraw bool, gameState map[string]interface{}, botRating float64) error {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("error starting transaction: %v", err)
@@ -188,7 +197,7 @@ func (db *DB) UpdateGameResult(ctx context.Context, gameID, winnerID int, gameSt
 
 	_, err = tx.ExecContext(ctx, `
 		UPDATE games 
-		SET winner_id = $1, end_time = CURRENT_TIMESTAMP, game_state = $2
+		SET winner_id = $1, end_time = CURRENT_TIMESTAMP, game_state = $2, counted = TRUE
 		WHERE id = $3`,
 		winnerParam, gameStateJSON, gameID,
 	)
@@ -212,42 +221,45 @@ func (db *DB) UpdateGameResult(ctx context.Context, gameID, winnerID int, gameSt
 		return fmt.Errorf("error updating player statistics: %v", err)
 	}
 
+	if err := updateRatings(ctx, tx, gameID, winnerID, isDraw, botRating); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("error committing transaction: %v", err)
 	}
 	return nil
 }
 
-// GetLeaderboard retrieves the top players
-func (db *DB) GetLeaderboard(ctx context.Context, limit int) ([]Player, error) {
-	query := `
-		SELECT username, games_played, games_won, win_percentage
-		FROM leaderboard
-		ORDER BY games_won DESC, win_percentage DESC
-		LIMIT $1`
-
-	rows, err := db.QueryContext(ctx, query, limit)
+// CloseGame records the end of a game without touching player statistics,
+// for games whose result does not count
+func (db *DB) CloseGame(ctx context.Context, gameID, winnerID int, gameState map[string]interface{}) error {
+	gameStateJSON, err := json.Marshal(gameState)
 	if err != nil {
-		return nil, fmt.Errorf("error getting leaderboard: %v", err)
+		return fmt.Errorf("error marshaling game state: %v", err)
 	}
-	defer rows.Close()
-
-	var players []Player
-	for rows.Next() {
-		var p Player
-		var winPct float64
-		if err := rows.Scan(&p.Username, &p.GamesPlayed, &p.GamesWon, &winPct); err != nil {
-			return nil, fmt.Errorf("error scanning leaderboard row: %v", err)
-		}
-		players = append(players, p)
+
+	var winnerParam interface{}
+	if winnerID != 0 {
+		winnerParam = winnerID
 	}
-	return players, nil
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE games
+		SET winner_id = $1, end_time = CURRENT_TIMESTAMP, game_state = $2
+		WHERE id = $3`,
+		winnerParam, gameStateJSON, gameID,
+	)
+	if err != nil {
+		return fmt.Errorf("error closing game: %v", err)
+	}
+	return nil
 }
 
 // GetPlayerStats retrieves statistics for a specific player
 func (db *DB) GetPlayerStats(ctx context.Context, username string) (*Player, error) {
 	query := `
-		SELECT username, games_played, games_won
+		SELECT username, games_played, games_won, ROUND(rating)::int, ROUND(rating_deviation)::int
 		FROM leaderboard
 		WHERE username = $1`
 
@@ -256,6 +268,8 @@ func (db *DB) GetPlayerStats(ctx context.Context, username string) (*Player, err
 		&player.Username,
 		&player.GamesPlayed,
 		&player.GamesWon,
+		&player.Rating,
+		&player.RatingDeviation,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
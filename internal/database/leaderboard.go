@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Leaderboard orders
+const (
+	SortRating  = "rating"
+	SortWins    = "wins"
+	SortWinRate = "winRate"
+)
+
+// Leaderboard time windows
+const (
+	WindowToday = "today"
+	WindowWeek  = "week"
+	WindowMonth = "month"
+	WindowAll   = "all"
+)
+
+// MaxLeaderboardLimit is the largest page a leaderboard query returns
+const MaxLeaderboardLimit = 100
+
+// Errors returned by Leaderboard for queries it cannot run
+var (
+	ErrInvalidSort   = errors.New("unknown leaderboard sort")
+	ErrInvalidWindow = errors.New("unknown leaderboard window")
+	ErrInvalidCursor = errors.New("invalid leaderboard cursor")
+)
+
+// sortKeys are the expressions each order ranks by, highest first
+var sortKeys = map[string]string{
+	SortRating:  "rating",
+	SortWins:    "games_won::float8",
+	SortWinRate: "games_won::float8 / games_played",
+}
+
+// windowStarts are the start of each time window; the all-time window has
+// none
+var windowStarts = map[string]string{
+	WindowToday: "date_trunc('day', LOCALTIMESTAMP)",
+	WindowWeek:  "LOCALTIMESTAMP - INTERVAL '7 days'",
+	WindowMonth: "LOCALTIMESTAMP - INTERVAL '30 days'",
+	WindowAll:   "",
+}
+
+// LeaderboardQuery selects one page of a leaderboard. The zero value is the
+// first page of the all-time human leaderboard ranked by rating.
+type LeaderboardQuery struct {
+	Bots      bool   // rank registered bots instead of people
+	Sort      string // SortRating, SortWins or SortWinRate
+	Window    string // WindowToday, WindowWeek, WindowMonth or WindowAll
+	MinGames  int    // leave out players with fewer games in the window
+	HumanOnly bool   // count only games between two people
+	Cursor    string // NextCursor of the previous page
+	Limit     int    // at most MaxLeaderboardLimit
+}
+
+// LeaderboardPage is one page of a leaderboard. Games played and won count
+// the games in the query's window; ratings are always current.
+type LeaderboardPage struct {
+	Players    []Player
+	NextCursor string // empty on the last page
+}
+
+// Leaderboard returns a page of players ranked by q.Sort, ties broken by
+// username
+func (db *DB) Leaderboard(ctx context.Context, q LeaderboardQuery) (*LeaderboardPage, error) {
+	if q.Sort == "" {
+		q.Sort = SortRating
+	}
+	if q.Window == "" {
+		q.Window = WindowAll
+	}
+	if q.Limit <= 0 || q.Limit > MaxLeaderboardLimit {
+		q.Limit = MaxLeaderboardLimit
+	}
+	key, ok := sortKeys[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSort, q.Sort)
+	}
+	start, ok := windowStarts[q.Window]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidWindow, q.Window)
+	}
+
+	var after interface{}
+	var afterKey float64
+	if q.Cursor != "" {
+		username, k, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return nil, err
+		}
+		after, afterKey = username, k
+	}
+
+	since := ""
+	if start != "" {
+		since = " AND g.end_time >= " + start
+	}
+	// Every counted game gives one row per seated player, with the opponent
+	// if there was one
+	query := `
+		WITH results AS (
+			SELECT g.player1_id AS player_id, g.player2_id AS opponent_id, g.winner_id, g.is_bot_game
+			FROM games g
+			WHERE g.counted` + since + `
+			UNION ALL
+			SELECT g.player2_id, g.player1_id, g.winner_id, g.is_bot_game
+			FROM games g
+			WHERE g.counted AND g.player2_id IS NOT NULL` + since + `
+		), standings AS (
+			SELECT p.username, p.rating, p.rating_deviation,
+				COUNT(*) AS games_played,
+				COUNT(*) FILTER (WHERE r.winner_id = p.id) AS games_won
+			FROM results r
+			JOIN players p ON p.id = r.player_id
+			LEFT JOIN players o ON o.id = r.opponent_id
+			WHERE p.is_bot = $1
+			  AND NOT ($2 AND (r.is_bot_game OR COALESCE(o.is_bot, FALSE)))
+			GROUP BY p.id
+		), ranked AS (
+			SELECT *, ` + key + ` AS sort_key
+			FROM standings
+			WHERE games_played >= $3
+		)
+		SELECT username, games_played, games_won, ROUND(rating)::int, ROUND(rating_deviation)::int, sort_key
+		FROM ranked
+		WHERE $4::text IS NULL OR sort_key < $5 OR (sort_key = $5 AND username > $4)
+		ORDER BY sort_key DESC, username
+		LIMIT $6`
+
+	// One row more than asked for tells whether there is a next page
+	rows, err := db.QueryContext(ctx, query, q.Bots, q.HumanOnly, q.MinGames, after, afterKey, q.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("error getting leaderboard: %v", err)
+	}
+	defer rows.Close()
+
+	page := &LeaderboardPage{Players: []Player{}}
+	var lastKey float64
+	for rows.Next() {
+		if len(page.Players) == q.Limit {
+			last := page.Players[len(page.Players)-1]
+			page.NextCursor = encodeCursor(q.Sort, lastKey, last.Username)
+			break
+		}
+		var p Player
+		if err := rows.Scan(&p.Username, &p.GamesPlayed, &p.GamesWon, &p.Rating, &p.RatingDeviation, &lastKey); err != nil {
+			return nil, fmt.Errorf("error scanning leaderboard row: %v", err)
+		}
+		page.Players = append(page.Players, p)
+	}
+	return page, rows.Err()
+}
+
+// encodeCursor names the last row of a page. The sort is part of the
+// cursor so it cannot be replayed against another order.
+func encodeCursor(sort string, key float64, username string) string {
+	raw := sort + ":" + strconv.FormatFloat(key, 'g', -1, 64) + ":" + username
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor returns the username and sort key a cursor names. A tampered
+// cursor that Postgres would refuse or misorder is rejected here, so it is a
+// bad request rather than a failed query.
+func decodeCursor(cursor, sort string) (string, float64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || parts[0] != sort || parts[2] == "" {
+		return "", 0, ErrInvalidCursor
+	}
+	if !utf8.ValidString(parts[2]) || strings.ContainsRune(parts[2], 0) {
+		return "", 0, ErrInvalidCursor
+	}
+	key, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || math.IsNaN(key) || math.IsInf(key, 0) {
+		return "", 0, ErrInvalidCursor
+	}
+	return parts[2], key, nil
+}
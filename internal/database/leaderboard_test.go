@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		sort     string
+		key      float64
+		username string
+	}{
+		{SortRating, 1500, "alice"},
+		{SortRating, 1234.5678, "bob"},
+		{SortWins, 0, "carol"},
+		{SortWinRate, 2.0 / 3, "dave"},
+		{SortWinRate, 1e-9, "eve"},
+		{SortRating, -12, "name:with:colons"},
+		{SortWins, 7, "émilie ✓"},
+	}
+	for _, tt := range tests {
+		cursor := encodeCursor(tt.sort, tt.key, tt.username)
+		username, key, err := decodeCursor(cursor, tt.sort)
+		if err != nil {
+			t.Errorf("%s %v %q: %v", tt.sort, tt.key, tt.username, err)
+			continue
+		}
+		// The key must come back exactly, or the tie on it is lost
+		if username != tt.username || key != tt.key {
+			t.Errorf("%s: decoded %q %v, want %q %v", tt.sort, username, key, tt.username, tt.key)
+		}
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("rating:1500:alice"))},
+		{"another sort", encodeCursor(SortWins, 3, "alice")},
+		{"no username", raw("rating:1500:")},
+		{"missing parts", raw("rating:1500")},
+		{"no key", raw("rating::alice")},
+		{"key not a number", raw("rating:abc:alice")},
+		{"NaN key", raw("rating:NaN:alice")},
+		{"infinite key", raw("rating:+Inf:alice")},
+		{"key out of range", raw("rating:1e999:alice")},
+		{"NUL in username", raw("rating:1500:al\x00ice")},
+		{"invalid UTF-8 in username", raw("rating:1500:al\xffice")},
+	}
+	for _, tt := range tests {
+		if username, key, err := decodeCursor(tt.cursor, SortRating); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: decoded %q %v, %v; want ErrInvalidCursor", tt.name, username, key, err)
+		}
+	}
+
+	// A bad cursor is refused before any query runs: this DB has no connection
+	for _, tt := range tests {
+		_, err := (&DB{}).Leaderboard(context.Background(), LeaderboardQuery{Cursor: tt.cursor})
+		if !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: Leaderboard returned %v, want ErrInvalidCursor", tt.name, err)
+		}
+	}
+}
+
+// rankedRow is a row of the leaderboard query's ranked table
+type rankedRow struct {
+	username string
+	key      float64
+}
+
+// pageAfter returns the rows the leaderboard query selects after cursor:
+// the same WHERE and ORDER BY, applied in Go
+func pageAfter(t *testing.T, rows []rankedRow, sortBy, cursor string, limit int) []rankedRow {
+	var page []rankedRow
+	for _, r := range rows {
+		if cursor != "" {
+			username, key, err := decodeCursor(cursor, sortBy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !(r.key < key || (r.key == key && r.username > username)) {
+				continue
+			}
+		}
+		page = append(page, r)
+	}
+	sort.Slice(page, func(i, j int) bool {
+		if page[i].key != page[j].key {
+			return page[i].key > page[j].key
+		}
+		return page[i].username < page[j].username
+	})
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page
+}
+
+// TestCursorTieBreaking pages through leaderboards where several players
+// share a sort key. Each player must show up once, ties in username order.
+func TestCursorTieBreaking(t *testing.T) {
+	type stats struct {
+		username    string
+		rating      float64
+		played, won int
+	}
+	players := []stats{
+		{"zoe", 1500, 3, 2},
+		{"amy", 1500, 6, 4},
+		{"max", 1500, 9, 6},
+		{"bea:1", 1620.25, 3, 3},
+		{"bea", 1620.25, 5, 2},
+		{"kim", 1400, 3, 0},
+		{"ann", 1400, 7, 0},
+		{"lee", 1500, 4, 2},
+	}
+	keys := map[string]func(stats) float64{
+		SortRating:  func(p stats) float64 { return p.rating },
+		SortWins:    func(p stats) float64 { return float64(p.won) },
+		SortWinRate: func(p stats) float64 { return float64(p.won) / float64(p.played) },
+	}
+	want := map[string][]string{
+		SortRating:  {"bea", "bea:1", "amy", "lee", "max", "zoe", "ann", "kim"},
+		SortWins:    {"max", "amy", "bea:1", "bea", "lee", "zoe", "ann", "kim"},
+		SortWinRate: {"bea:1", "amy", "max", "zoe", "lee", "bea", "ann", "kim"},
+	}
+
+	for sortBy, key := range keys {
+		rows := make([]rankedRow, len(players))
+		for i, p := range players {
+			rows[i] = rankedRow{p.username, key(p)}
+		}
+		for _, limit := range []int{1, 2, 3} {
+			var got []string
+			cursor := ""
+			for pages := 0; ; pages++ {
+				if pages > len(rows) {
+					t.Fatalf("%s, pages of %d: paging does not end", sortBy, limit)
+				}
+				// One row more than asked for tells whether there is a next
+				// page, as in Leaderboard
+				page := pageAfter(t, rows, sortBy, cursor, limit+1)
+				if len(page) <= limit {
+					for _, r := range page {
+						got = append(got, r.username)
+					}
+					break
+				}
+				for _, r := range page[:limit] {
+					got = append(got, r.username)
+				}
+				last := page[limit-1]
+				cursor = encodeCursor(sortBy, last.key, last.username)
+			}
+			if !reflect.DeepEqual(got, want[sortBy]) {
+				t.Errorf("%s, pages of %d:\n got %s\nwant %s", sortBy, limit,
+					strings.Join(got, " "), strings.Join(want[sortBy], " "))
+			}
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var migrationName = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// TestEmbeddedMigrations checks the migrations built into the server: every
+// version from 1 up has an up and a down script, in order and without gaps
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d (%s) follows %d, want version %d", m.Version, m.Name, i, i+1)
+		}
+		if strings.TrimSpace(m.up) == "" || strings.TrimSpace(m.down) == "" {
+			t.Errorf("migration %04d_%s has an empty script", m.Version, m.Name)
+		}
+	}
+
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2*len(migrations) {
+		t.Errorf("%d files for %d migrations", len(entries), len(migrations))
+	}
+	for _, e := range entries {
+		if !migrationName.MatchString(e.Name()) {
+			t.Errorf("%s is not named NNNN_description.up.sql or .down.sql", e.Name())
+		}
+	}
+}
+
+func TestReadMigrations(t *testing.T) {
+	script := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	tests := []struct {
+		name  string
+		files []string
+		want  []string // version_name of each migration, or nil for an error
+	}{
+		{
+			name:  "sorted by version",
+			files: []string{"0010_c.up.sql", "0010_c.down.sql", "0002_b.down.sql", "0001_a.up.sql", "0002_b.up.sql", "0001_a.down.sql"},
+			want:  []string{"1_a", "2_b", "10_c"},
+		},
+		{name: "missing down", files: []string{"0001_a.up.sql", "0001_a.down.sql", "0002_b.up.sql"}},
+		{name: "missing up", files: []string{"0001_a.down.sql"}},
+		{name: "two names for a version", files: []string{"0001_a.up.sql", "0001_b.down.sql"}},
+		{name: "no description", files: []string{"0001.up.sql", "0001.down.sql"}},
+		{name: "no direction", files: []string{"0001_a.sql"}},
+		{name: "unknown direction", files: []string{"0001_a.up.sql", "0001_a.sideways.sql"}},
+		{name: "not a number", files: []string{"one_a.up.sql", "one_a.down.sql"}},
+		{name: "version 0", files: []string{"0000_a.up.sql", "0000_a.down.sql"}},
+	}
+	for _, tt := range tests {
+		fsys := fstest.MapFS{}
+		for _, name := range tt.files {
+			fsys["migrations/"+name] = script
+		}
+		migrations, err := readMigrations(fsys)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: read %d migrations, want an error", tt.name, len(migrations))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		got := make([]string, len(migrations))
+		for i, m := range migrations {
+			got[i] = fmt.Sprintf("%d_%s", m.Version, m.Name)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: migrations %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
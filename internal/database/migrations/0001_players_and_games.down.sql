@@ -0,0 +1,3 @@
+DROP VIEW IF EXISTS leaderboard;
+DROP TABLE IF EXISTS games;
+DROP TABLE IF EXISTS players;
@@ -0,0 +1,6 @@
+-- Snapshots of in-progress games, restored after a restart
+CREATE TABLE IF NOT EXISTS active_games (
+	game_id TEXT PRIMARY KEY,
+	snapshot JSONB NOT NULL,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
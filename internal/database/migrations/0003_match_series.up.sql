@@ -0,0 +1,13 @@
+-- Finished best-of-N match series
+CREATE TABLE IF NOT EXISTS match_series (
+	id SERIAL PRIMARY KEY,
+	series_id TEXT UNIQUE NOT NULL,
+	best_of INTEGER NOT NULL,
+	player1 VARCHAR(50) NOT NULL,
+	player2 VARCHAR(50) NOT NULL,
+	player1_wins INTEGER NOT NULL DEFAULT 0,
+	player2_wins INTEGER NOT NULL DEFAULT 0,
+	draws INTEGER NOT NULL DEFAULT 0,
+	winner VARCHAR(50),
+	finished_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,6 @@
+-- Usernames banned by operators
+CREATE TABLE IF NOT EXISTS banned_users (
+	username VARCHAR(50) PRIMARY KEY,
+	reason TEXT NOT NULL DEFAULT '',
+	banned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,17 @@
+DROP VIEW IF EXISTS bot_leaderboard;
+DROP TABLE IF EXISTS bot_accounts;
+
+CREATE OR REPLACE VIEW leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage
+FROM players
+WHERE games_played > 0
+ORDER BY games_won DESC, win_percentage DESC;
+
+ALTER TABLE players DROP COLUMN IF EXISTS is_bot;
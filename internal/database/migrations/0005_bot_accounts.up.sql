@@ -0,0 +1,35 @@
+-- Registered third-party bots. Bot players are flagged so the human
+-- leaderboard leaves them out; they are ranked on their own.
+ALTER TABLE players ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS bot_accounts (
+	username VARCHAR(50) PRIMARY KEY,
+	api_key_hash TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE VIEW leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage
+FROM players
+WHERE games_played > 0 AND NOT is_bot
+ORDER BY games_won DESC, win_percentage DESC;
+
+CREATE OR REPLACE VIEW bot_leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage
+FROM players
+WHERE games_played > 0 AND is_bot
+ORDER BY games_won DESC, win_percentage DESC;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS failed_events;
+DROP TABLE IF EXISTS game_analytics;
@@ -0,0 +1,21 @@
+-- Events read from Kafka by the analytics consumer, and the ones it could
+-- not process
+CREATE TABLE IF NOT EXISTS game_analytics (
+	game_id TEXT,
+	event_type TEXT,
+	event_time TIMESTAMP,
+	player TEXT,
+	duration FLOAT,
+	is_bot_game BOOLEAN,
+	additional_data JSONB
+);
+
+CREATE TABLE IF NOT EXISTS failed_events (
+	id SERIAL PRIMARY KEY,
+	topic TEXT NOT NULL,
+	partition INTEGER NOT NULL,
+	"offset" BIGINT NOT NULL,
+	message TEXT NOT NULL,
+	error TEXT NOT NULL,
+	timestamp TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,11 @@
+-- Every move of recorded games, for replays and move analytics. Moves
+-- belong to the games row they were played in and are deleted with it.
+CREATE TABLE IF NOT EXISTS game_moves (
+	game_id INTEGER NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+	ply INTEGER NOT NULL,
+	player VARCHAR(50) NOT NULL,
+	column_index SMALLINT NOT NULL,
+	row_index SMALLINT NOT NULL,
+	played_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (game_id, ply)
+);
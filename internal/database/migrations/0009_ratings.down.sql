@@ -0,0 +1,35 @@
+-- Views cannot lose columns through CREATE OR REPLACE
+DROP VIEW IF EXISTS leaderboard;
+DROP VIEW IF EXISTS bot_leaderboard;
+
+CREATE VIEW leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage
+FROM players
+WHERE games_played > 0 AND NOT is_bot
+ORDER BY games_won DESC, win_percentage DESC;
+
+CREATE VIEW bot_leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage
+FROM players
+WHERE games_played > 0 AND is_bot
+ORDER BY games_won DESC, win_percentage DESC;
+
+DROP TABLE IF EXISTS rating_history;
+DROP INDEX IF EXISTS idx_players_rating;
+ALTER TABLE players DROP COLUMN IF EXISTS rating_volatility;
+ALTER TABLE players DROP COLUMN IF EXISTS rating_deviation;
+ALTER TABLE players DROP COLUMN IF EXISTS rating;
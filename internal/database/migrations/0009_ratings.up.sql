@@ -0,0 +1,54 @@
+-- Glicko-2 ratings. Every change is logged with the game that caused it,
+-- and the leaderboards rank by rating instead of wins.
+ALTER TABLE players ADD COLUMN IF NOT EXISTS rating DOUBLE PRECISION NOT NULL DEFAULT 1500;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS rating_deviation DOUBLE PRECISION NOT NULL DEFAULT 350;
+ALTER TABLE players ADD COLUMN IF NOT EXISTS rating_volatility DOUBLE PRECISION NOT NULL DEFAULT 0.06;
+
+CREATE TABLE IF NOT EXISTS rating_history (
+	id SERIAL PRIMARY KEY,
+	player_id INTEGER NOT NULL REFERENCES players(id) ON DELETE CASCADE,
+	game_id INTEGER NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+	opponent VARCHAR(50) NOT NULL,
+	opponent_rating DOUBLE PRECISION NOT NULL,
+	score REAL NOT NULL,
+	rating_before DOUBLE PRECISION NOT NULL,
+	rating_after DOUBLE PRECISION NOT NULL,
+	deviation_before DOUBLE PRECISION NOT NULL,
+	deviation_after DOUBLE PRECISION NOT NULL,
+	volatility_before DOUBLE PRECISION NOT NULL,
+	volatility_after DOUBLE PRECISION NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_rating_history_player ON rating_history(player_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_players_rating ON players(rating DESC);
+
+CREATE OR REPLACE VIEW leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage,
+	rating,
+	rating_deviation
+FROM players
+WHERE games_played > 0 AND NOT is_bot
+ORDER BY rating DESC, games_won DESC;
+
+CREATE OR REPLACE VIEW bot_leaderboard AS
+SELECT
+	username,
+	games_played,
+	games_won,
+	CASE
+		WHEN games_played > 0 THEN ROUND((games_won::numeric / games_played::numeric) * 100, 2)
+		ELSE 0
+	END AS win_percentage,
+	rating,
+	rating_deviation
+FROM players
+WHERE games_played > 0 AND is_bot
+ORDER BY rating DESC, games_won DESC;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_games_counted_end_time;
+ALTER TABLE games DROP COLUMN IF EXISTS counted;
@@ -0,0 +1,7 @@
+-- Games whose result counts toward player statistics, so leaderboards over
+-- a time window can be computed from the games table. Older finished games
+-- cannot be told apart from aborted ones and are all counted.
+ALTER TABLE games ADD COLUMN IF NOT EXISTS counted BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE games SET counted = TRUE WHERE end_time IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_games_counted_end_time ON games(end_time) WHERE counted;
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Move is one disc dropped in a recorded game. Ply counts the moves of the
+// game from 1. GameID is the ID of the game record, set on moves read back.
+type Move struct {
+	GameID   int       `json:"gameId"`
+	Ply      int       `json:"ply"`
+	Player   string    `json:"player"`
+	Column   int       `json:"column"`
+	Row      int       `json:"row"`
+	PlayedAt time.Time `json:"playedAt"`
+}
+
+// SaveMove records a move of the game record gameID. Recording the same ply
+// twice keeps the first, so moves can be written again after a restart.
+func (db *DB) SaveMove(ctx context.Context, gameID int, m Move) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO game_moves (game_id, ply, player, column_index, row_index, played_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (game_id, ply) DO NOTHING`,
+		gameID, m.Ply, m.Player, m.Column, m.Row, m.PlayedAt)
+	if err != nil {
+		return fmt.Errorf("error saving move: %v", err)
+	}
+	return nil
+}
+
+// SaveMoves records several moves of the game record gameID in one
+// statement, for a record created after the moves were played. Plies
+// already recorded are kept.
+func (db *DB) SaveMoves(ctx context.Context, gameID int, moves []Move) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	rows := make([]string, 0, len(moves))
+	args := make([]interface{}, 0, 1+5*len(moves))
+	args = append(args, gameID)
+	for _, m := range moves {
+		n := len(args)
+		rows = append(rows, fmt.Sprintf("($1, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5))
+		args = append(args, m.Ply, m.Player, m.Column, m.Row, m.PlayedAt)
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO game_moves (game_id, ply, player, column_index, row_index, played_at)
+		VALUES `+strings.Join(rows, ", ")+`
+		ON CONFLICT (game_id, ply) DO NOTHING`,
+		args...)
+	if err != nil {
+		return fmt.Errorf("error saving moves: %v", err)
+	}
+	return nil
+}
+
+// GetGameMoves returns the moves of a game record in the order they were
+// played. The list is empty for games that were not recorded.
+func (db *DB) GetGameMoves(ctx context.Context, gameID int) ([]Move, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT game_id, ply, player, column_index, row_index, played_at
+		FROM game_moves
+		WHERE game_id = $1
+		ORDER BY ply`,
+		gameID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading moves: %v", err)
+	}
+	defer rows.Close()
+
+	moves := []Move{}
+	for rows.Next() {
+		var m Move
+		if err := rows.Scan(&m.GameID, &m.Ply, &m.Player, &m.Column, &m.Row, &m.PlayedAt); err != nil {
+			return nil, fmt.Errorf("error scanning move: %v", err)
+		}
+		moves = append(moves, m)
+	}
+	return moves, rows.Err()
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/connect4/backend/internal/rating"
+)
+
+// botOpponentName is recorded as the opponent of games against the
+// built-in bot, which has no player record
+const botOpponentName = "AI Bot"
+
+// RatingChange is one entry of a player's rating history
+type RatingChange struct {
+	GameID         int       `json:"gameId"`
+	Opponent       string    `json:"opponent"`
+	OpponentRating float64   `json:"opponentRating"`
+	Score          float64   `json:"score"` // 1 win, 0.5 draw, 0 loss
+	Before         float64   `json:"before"`
+	After          float64   `json:"after"`
+	DeviationAfter float64   `json:"deviationAfter"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// ratedPlayer is a player's rating as read at the start of a rated game
+type ratedPlayer struct {
+	id       int
+	username string
+	rating   rating.Rating
+}
+
+// updateRatings rates the players of a finished game and logs the changes.
+// Both players are rated against each other's rating before the game.
+func updateRatings(ctx context.Context, tx *sql.Tx, gameID, winnerID int, isDraw bool, botRating float64) error {
+	var player1ID int
+	var player2ID sql.NullInt64
+	var isBotGame bool
+	err := tx.QueryRowContext(ctx, `SELECT player1_id, player2_id, is_bot_game FROM games WHERE id = $1`, gameID).
+		Scan(&player1ID, &player2ID, &isBotGame)
+	if err != nil {
+		return fmt.Errorf("error reading game for rating: %v", err)
+	}
+	if isBotGame && botRating <= 0 {
+		return nil
+	}
+	if !isBotGame && !player2ID.Valid {
+		return nil
+	}
+
+	// Rows are locked in ID order so concurrent results cannot deadlock
+	ids := []int{player1ID}
+	if !isBotGame {
+		ids = append(ids, int(player2ID.Int64))
+		if ids[1] < ids[0] {
+			ids[0], ids[1] = ids[1], ids[0]
+		}
+	}
+	players := make(map[int]*ratedPlayer, len(ids))
+	for _, id := range ids {
+		p := &ratedPlayer{id: id}
+		var idle int
+		err := tx.QueryRowContext(ctx, `
+			SELECT username, rating, rating_deviation, rating_volatility,
+				COALESCE((
+					SELECT FLOOR(EXTRACT(EPOCH FROM LOCALTIMESTAMP - MAX(created_at)) / $2)::int
+					FROM rating_history WHERE player_id = players.id
+				), 0)
+			FROM players WHERE id = $1 FOR UPDATE`, id, rating.IdlePeriod.Seconds()).
+			Scan(&p.username, &p.rating.Rating, &p.rating.Deviation, &p.rating.Volatility, &idle)
+		if err != nil {
+			return fmt.Errorf("error reading rating: %v", err)
+		}
+		// Time away from rated games makes the rating less certain
+		p.rating = rating.Idle(p.rating, idle)
+		players[id] = p
+	}
+
+	score := 0.0
+	switch {
+	case isDraw:
+		score = 0.5
+	case winnerID == player1ID:
+		score = 1
+	}
+
+	p1 := players[player1ID]
+	if isBotGame {
+		bot := ratedPlayer{username: botOpponentName, rating: rating.Fixed(botRating)}
+		return saveRating(ctx, tx, gameID, p1, &bot, score)
+	}
+	p2 := players[int(player2ID.Int64)]
+	// Both are rated against the opponent's rating from before the game
+	opponent1, opponent2 := *p2, *p1
+	if err := saveRating(ctx, tx, gameID, p1, &opponent1, score); err != nil {
+		return err
+	}
+	return saveRating(ctx, tx, gameID, p2, &opponent2, 1-score)
+}
+
+// saveRating applies one game's result to p's rating and logs the change
+func saveRating(ctx context.Context, tx *sql.Tx, gameID int, p, opponent *ratedPlayer, score float64) error {
+	after := rating.Update(p.rating, opponent.rating, score)
+	_, err := tx.ExecContext(ctx, `
+		UPDATE players
+		SET rating = $1, rating_deviation = $2, rating_volatility = $3
+		WHERE id = $4`,
+		after.Rating, after.Deviation, after.Volatility, p.id)
+	if err != nil {
+		return fmt.Errorf("error updating rating: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO rating_history (
+			player_id, game_id, opponent, opponent_rating, score,
+			rating_before, rating_after, deviation_before, deviation_after,
+			volatility_before, volatility_after
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		p.id, gameID, opponent.username, opponent.rating.Rating, score,
+		p.rating.Rating, after.Rating, p.rating.Deviation, after.Deviation,
+		p.rating.Volatility, after.Volatility)
+	if err != nil {
+		return fmt.Errorf("error logging rating change: %v", err)
+	}
+	return nil
+}
+
+// GetRatingHistory returns the latest limit rating changes of a player,
+// newest first
+func (db *DB) GetRatingHistory(ctx context.Context, username string, limit int) ([]RatingChange, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT h.game_id, h.opponent, h.opponent_rating, h.score,
+			h.rating_before, h.rating_after, h.deviation_after, h.created_at
+		FROM rating_history h
+		JOIN players p ON p.id = h.player_id
+		WHERE p.username = $1
+		ORDER BY h.created_at DESC, h.id DESC
+		LIMIT $2`,
+		username, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting rating history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []RatingChange{}
+	for rows.Next() {
+		var c RatingChange
+		if err := rows.Scan(&c.GameID, &c.Opponent, &c.OpponentRating, &c.Score, &c.Before, &c.After, &c.DeviationAfter, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning rating change: %v", err)
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// SeriesResult is the final score of a best-of-N match series
+type SeriesResult struct {
+	SeriesID    string
+	BestOf      int
+	Player1     string
+	Player2     string
+	Player1Wins int
+	Player2Wins int
+	Draws       int
+	Winner      string // empty when the series is drawn
+}
+
+// SaveSeriesResult records the result of a finished match series
+func (db *DB) SaveSeriesResult(ctx context.Context, r SeriesResult) error {
+	var winner interface{}
+	if r.Winner != "" {
+		winner = r.Winner
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO match_series (series_id, best_of, player1, player2, player1_wins, player2_wins, draws, winner)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (series_id) DO NOTHING`,
+		r.SeriesID, r.BestOf, r.Player1, r.Player2, r.Player1Wins, r.Player2Wins, r.Draws, winner)
+	if err != nil {
+		return fmt.Errorf("failed to save series result: %w", err)
+	}
+	return nil
+}
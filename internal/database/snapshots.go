@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// SaveGameSnapshot creates or replaces the snapshot of an in-progress game
+func (db *DB) SaveGameSnapshot(ctx context.Context, gameID string, snapshot []byte) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO active_games (game_id, snapshot, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (game_id) DO UPDATE
+		SET snapshot = EXCLUDED.snapshot, updated_at = EXCLUDED.updated_at`,
+		gameID, snapshot,
+	)
+	if err != nil {
+		return fmt.Errorf("error saving game snapshot: %v", err)
+	}
+	return nil
+}
+
+// DeleteGameSnapshot removes the snapshot of a game that is no longer in progress
+func (db *DB) DeleteGameSnapshot(ctx context.Context, gameID string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM active_games WHERE game_id = $1`, gameID)
+	if err != nil {
+		return fmt.Errorf("error deleting game snapshot: %v", err)
+	}
+	return nil
+}
+
+// LoadGameSnapshots returns every stored snapshot keyed by game ID
+func (db *DB) LoadGameSnapshots(ctx context.Context) (map[string][]byte, error) {
+	rows, err := db.QueryContext(ctx, `SELECT game_id, snapshot FROM active_games`)
+	if err != nil {
+		return nil, fmt.Errorf("error loading game snapshots: %v", err)
+	}
+	defer rows.Close()
+
+	snapshots := make(map[string][]byte)
+	for rows.Next() {
+		var gameID string
+		var snapshot []byte
+		if err := rows.Scan(&gameID, &snapshot); err != nil {
+			return nil, fmt.Errorf("error scanning game snapshot: %v", err)
+		}
+		snapshots[gameID] = snapshot
+	}
+	return snapshots, rows.Err()
+}
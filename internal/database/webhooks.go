@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Webhook is a URL that receives signed event notifications. An empty
+// Events list subscribes to every event.
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID          int       `json:"id"`
+	WebhookID   int       `json:"webhookId"`
+	EventID     string    `json:"eventId"`
+	EventType   string    `json:"eventType"`
+	Attempt     int       `json:"attempt"`
+	StatusCode  int       `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Succeeded   bool      `json:"succeeded"`
+	DurationMs  int       `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// CreateWebhook stores a new webhook and fills in its ID and creation time
+func (db *DB) CreateWebhook(ctx context.Context, w *Webhook) error {
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO webhooks (url, secret, events)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		w.URL, w.Secret, strings.Join(w.Events, ",")).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return nil
+}
+
+// DeleteWebhook removes a webhook along with its delivery log
+func (db *DB) DeleteWebhook(ctx context.Context, id int) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return nil
+}
+
+// ListWebhooks returns every webhook, secrets included
+func (db *DB) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, url, secret, events, created_at FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []Webhook
+	for rows.Next() {
+		var w Webhook
+		var events string
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		if events != "" {
+			w.Events = strings.Split(events, ",")
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+// SaveWebhookDelivery appends an attempt to the delivery log
+func (db *DB) SaveWebhookDelivery(ctx context.Context, d *WebhookDelivery) error {
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries
+			(webhook_id, event_id, event_type, attempt, status_code, error, succeeded, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		d.WebhookID, d.EventID, d.EventType, d.Attempt, d.StatusCode, d.Error, d.Succeeded, d.DurationMs, d.AttemptedAt).Scan(&d.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookDeliveries returns the latest limit attempts for a webhook,
+// newest first
+func (db *DB) ListWebhookDeliveries(ctx context.Context, webhookID, limit int) ([]WebhookDelivery, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, webhook_id, event_id, event_type, attempt, status_code, error, succeeded, duration_ms, attempted_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY attempted_at DESC, id DESC
+		LIMIT $2`,
+		webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Attempt, &d.StatusCode, &d.Error, &d.Succeeded, &d.DurationMs, &d.AttemptedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,114 @@
+package game
+
+import (
+	"fmt"
+	"time"
+)
+
+// Variant constants
+const (
+	VariantStandard = "standard"
+)
+
+// TimeControl describes a game clock as initial time plus per-move increment
+type TimeControl struct {
+	InitialSeconds   int `json:"initialSeconds"`
+	IncrementSeconds int `json:"incrementSeconds"`
+}
+
+// Settings holds the options a game was created with
+type Settings struct {
+	Variant     string       `json:"variant"`
+	TimeControl *TimeControl `json:"clock,omitempty"`
+	Rated       bool         `json:"rated"`
+	BestOf      int          `json:"bestOf,omitempty"` // match series length, 0 or 1 for a single game
+}
+
+// DefaultSettings returns the settings used for matchmaking games
+func DefaultSettings() Settings {
+	return Settings{
+		Variant: VariantStandard,
+		Rated:   true,
+	}
+}
+
+// Validate checks that the settings describe a game this server can run
+func (s *Settings) Validate() error {
+	if s.Variant == "" {
+		s.Variant = VariantStandard
+	}
+	if s.Variant != VariantStandard {
+		return fmt.Errorf("unsupported variant: %s", s.Variant)
+	}
+	if tc := s.TimeControl; tc != nil {
+		if tc.InitialSeconds <= 0 || tc.InitialSeconds > 3600 {
+			return fmt.Errorf("clock initial time must be between 1 and 3600 seconds")
+		}
+		if tc.IncrementSeconds < 0 || tc.IncrementSeconds > 60 {
+			return fmt.Errorf("clock increment must be between 0 and 60 seconds")
+		}
+	}
+	switch s.BestOf {
+	case 0, 1, 3, 5, 7:
+	default:
+		return fmt.Errorf("series must be best of 1, 3, 5 or 7")
+	}
+	return nil
+}
+
+// Clock tracks the remaining thinking time of both players
+type Clock struct {
+	Increment time.Duration
+	Remaining [2]time.Duration
+	TurnStart time.Time
+}
+
+// ClockState is the client-facing view of a clock
+type ClockState struct {
+	Player1Ms int64 `json:"player1Ms"`
+	Player2Ms int64 `json:"player2Ms"`
+}
+
+// NewClock creates a clock for the given time control
+func NewClock(tc TimeControl) *Clock {
+	initial := time.Duration(tc.InitialSeconds) * time.Second
+	return &Clock{
+		Increment: time.Duration(tc.IncrementSeconds) * time.Second,
+		Remaining: [2]time.Duration{initial, initial},
+		TurnStart: time.Now(),
+	}
+}
+
+// RemainingFor returns the time left for player (1 or 2), counting the
+// running turn if it is that player's move
+func (c *Clock) RemainingFor(player, currentTurn int, now time.Time) time.Duration {
+	remaining := c.Remaining[player-1]
+	if player == currentTurn {
+		remaining -= now.Sub(c.TurnStart)
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Punch stops the clock of player after a move and starts the opponent's.
+// It returns false if the player had already run out of time.
+func (c *Clock) Punch(player int, now time.Time) bool {
+	remaining := c.Remaining[player-1] - now.Sub(c.TurnStart)
+	c.TurnStart = now
+	if remaining <= 0 {
+		c.Remaining[player-1] = 0
+		return false
+	}
+	c.Remaining[player-1] = remaining + c.Increment
+	return true
+}
+
+// State returns the client-facing view of the clock
+func (c *Clock) State(currentTurn int, now time.Time) *ClockState {
+	return &ClockState{
+		Player1Ms: c.RemainingFor(1, currentTurn, now).Milliseconds(),
+		Player2Ms: c.RemainingFor(2, currentTurn, now).Milliseconds(),
+	}
+}
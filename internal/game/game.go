@@ -2,19 +2,22 @@ package game
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/connect4/backend/internal/database"
+	"github.com/google/uuid"
 )
 
 // Player represents a player in the game
 type Player struct {
-	ID       string
-	Username string
-	IsBot    bool
+	ID         string
+	Username   string
+	IsBot      bool
+	BotAccount bool // a registered third-party bot, rated like a person but ranked separately
+	Rating     int  `json:",omitempty"` // rating when the game started, 0 when unknown
 }
 
 // Board represents the game board
@@ -36,13 +39,52 @@ type Game struct {
 	Player1      Player
 	Player2      Player
 	CurrentTurn  int
+	FirstTurn    int // seat that moved first and plays red
 	IsActive     bool
 	StartTime    int64
 	LastMoveTime int64
 	DB           *database.DB
 	DBGameID     int // ID of this game record in DB
+	Settings     Settings
+	Clock        *Clock // nil for untimed games
+	Winner       int    // set when the game ends by other means than four in a row
+	Drawn        bool   // declared drawn before the board was full
+	EndReason    string
+	Substituted  bool    // a bot played for an absent player; the result is unrated
+	BotRating    float64 // rating the bot is rated at; 0 leaves bot games unrated
+	// Moves played so far. Games with a record write each one as it is played.
+	Moves []database.Move
+
+	moveStore  moveStore     // nil without a database
+	moveWrites chan struct{} // closed once the latest move is written
+}
+
+// moveStore writes the moves of game records; *database.DB is one
+type moveStore interface {
+	SaveMove(ctx context.Context, gameID int, m database.Move) error
 }
 
+// Disc colors; the player with red moves first
+const (
+	ColorRed    = "red"
+	ColorYellow = "yellow"
+)
+
+// Errors returned by MakeMove
+var (
+	ErrInvalidColumn = errors.New("column out of bounds")
+	ErrColumnFull    = errors.New("column is full")
+	ErrOutOfTime     = errors.New("player has run out of time")
+)
+
+// End reasons for games that do not finish on the board
+const (
+	EndReasonTimeout     = "timeout"
+	EndReasonAbandoned   = "abandoned"
+	EndReasonAdjudicated = "adjudicated" // result set by an operator
+	EndReasonAborted     = "aborted"     // stopped by an operator without a result
+)
+
 // NewGame creates a new game instance and inserts it into the database
 func NewGame(db *database.DB, player1, player2 Player) (*Game, error) {
 	g := &Game{
@@ -54,9 +96,11 @@ func NewGame(db *database.DB, player1, player2 Player) (*Game, error) {
 		Player1:     player1,
 		Player2:     player2,
 		CurrentTurn: 1,
+		FirstTurn:   1,
 		IsActive:    true,
 		StartTime:   time.Now().Unix(),
 		DB:          db,
+		Settings:    DefaultSettings(),
 	}
 
 	// Create DB record if DB is provided
@@ -92,6 +136,10 @@ func NewGame(db *database.DB, player1, player2 Player) (*Game, error) {
 		var p2ID *int
 		if player2Entity != nil {
 			p2ID = &player2Entity.ID
+			g.Player2.Rating = player2Entity.Rating
+		}
+		if player1Entity != nil {
+			g.Player1.Rating = player1Entity.Rating
 		}
 
 		gameRecord, err := db.CreateGame(ctx, player1Entity.ID, p2ID, player2.IsBot)
@@ -99,6 +147,7 @@ func NewGame(db *database.DB, player1, player2 Player) (*Game, error) {
 			log.Printf("DB error creating game record: %v", err)
 		} else {
 			g.DBGameID = gameRecord.ID
+			g.moveStore = db
 			log.Printf("[DB] Game record created with ID=%d (isBotGame=%v)", g.DBGameID, player2.IsBot)
 		}
 	}
@@ -108,8 +157,15 @@ func NewGame(db *database.DB, player1, player2 Player) (*Game, error) {
 
 // MakeMove attempts to make a move in the specified column
 func (g *Game) MakeMove(column int) error {
+	if column < 0 || column >= 7 {
+		return fmt.Errorf("invalid move: %w (column %d)", ErrInvalidColumn, column)
+	}
 	if !g.Board.IsValidMove(column) {
-		return fmt.Errorf("invalid move: column %d is full or out of bounds", column)
+		return fmt.Errorf("invalid move: %w (column %d)", ErrColumnFull, column)
+	}
+
+	if g.Clock != nil && !g.Clock.Punch(g.CurrentTurn, time.Now()) {
+		return fmt.Errorf("invalid move: %w (player %d)", ErrOutOfTime, g.CurrentTurn)
 	}
 
 	row := 5 // Start from bottom
@@ -121,6 +177,7 @@ func (g *Game) MakeMove(column int) error {
 	g.Board.LastMove.Row = row
 	g.Board.LastMove.Column = column
 	g.Board.LastMove.Player = g.CurrentTurn
+	g.recordMove(row, column, g.CurrentTurn)
 
 	g.LastMoveTime = time.Now().Unix()
 	g.CurrentTurn = 3 - g.CurrentTurn // Switch between 1 and 2
@@ -131,6 +188,46 @@ func (g *Game) MakeMove(column int) error {
 	return nil
 }
 
+// recordMove keeps a move and writes it to the game record
+func (g *Game) recordMove(row, column, seat int) {
+	player := g.Player1.Username
+	if seat == 2 {
+		player = g.Player2.Username
+	}
+	move := database.Move{
+		Ply:      g.Board.MoveCount(),
+		Player:   player,
+		Column:   column,
+		Row:      row,
+		PlayedAt: time.Now(),
+	}
+	g.Moves = append(g.Moves, move)
+	g.writeMoves(move)
+}
+
+// writeMoves writes moves to the game record in the background. Writes
+// happen in the order they were asked for, each waiting for the one before.
+// Games without a record write nothing.
+func (g *Game) writeMoves(moves ...database.Move) {
+	if g.moveStore == nil || g.DBGameID == 0 || len(moves) == 0 {
+		return
+	}
+	store, gameID, previous := g.moveStore, g.DBGameID, g.moveWrites
+	done := make(chan struct{})
+	g.moveWrites = done
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		for _, m := range moves {
+			if err := store.SaveMove(context.Background(), gameID, m); err != nil {
+				log.Printf("[DB] ❌ Error saving move %d (GameID=%d): %v", m.Ply, gameID, err)
+			}
+		}
+	}()
+}
+
 // CheckGameCompletion checks if game ended (win or draw) and saves to DB
 func (g *Game) CheckGameCompletion() {
 	if g.CheckWin() {
@@ -145,6 +242,83 @@ func (g *Game) CheckGameCompletion() {
 	}
 }
 
+// SetSettings applies game settings and starts the clock for timed games
+func (g *Game) SetSettings(settings Settings) {
+	g.Settings = settings
+	g.Clock = nil
+	if settings.TimeControl != nil {
+		g.Clock = NewClock(*settings.TimeControl)
+	}
+}
+
+// SetFirstTurn gives the first move, and with it the red discs, to seat 1
+// or 2. It must be called before any move is made.
+func (g *Game) SetFirstTurn(seat int) {
+	if seat != 1 && seat != 2 {
+		return
+	}
+	g.FirstTurn = seat
+	g.CurrentTurn = seat
+}
+
+// ColorOf returns the disc color of a seat. Red always moves first.
+func (g *Game) ColorOf(seat int) string {
+	if seat == g.FirstTurn {
+		return ColorRed
+	}
+	return ColorYellow
+}
+
+// EndByTimeout finishes the game with the player to move losing on time
+func (g *Game) EndByTimeout() {
+	if !g.IsActive {
+		return
+	}
+	g.IsActive = false
+	g.Winner = 3 - g.CurrentTurn
+	g.EndReason = EndReasonTimeout
+	log.Printf("[GAME] Player %d ran out of time (GameID=%s)", g.CurrentTurn, g.ID)
+	g.saveGameResult(g.Winner)
+}
+
+// EndByAbandonment finishes the game with the given player losing because
+// they left and did not come back
+func (g *Game) EndByAbandonment(player int) {
+	if !g.IsActive {
+		return
+	}
+	g.IsActive = false
+	g.Winner = 3 - player
+	g.EndReason = EndReasonAbandoned
+	log.Printf("[GAME] Player %d abandoned the game (GameID=%s)", player, g.ID)
+	g.saveGameResult(g.Winner)
+}
+
+// Adjudicate ends the game with the given winner, or as a draw when winner
+// is 0
+func (g *Game) Adjudicate(winner int) {
+	if !g.IsActive || winner < 0 || winner > 2 {
+		return
+	}
+	g.IsActive = false
+	g.Winner = winner
+	g.Drawn = winner == 0
+	g.EndReason = EndReasonAdjudicated
+	log.Printf("[GAME] Game adjudicated, winner=%d (GameID=%s)", winner, g.ID)
+	g.saveGameResult(winner)
+}
+
+// Abort ends the game without a result. Player statistics are not touched.
+func (g *Game) Abort() {
+	if !g.IsActive {
+		return
+	}
+	g.IsActive = false
+	g.EndReason = EndReasonAborted
+	log.Printf("[GAME] Game aborted (GameID=%s)", g.ID)
+	g.saveGameResult(0)
+}
+
 // saveGameResult writes the result of the game into the database
 func (g *Game) saveGameResult(winner int) {
 	if g.DB == nil || g.DBGameID == 0 {
@@ -173,12 +347,25 @@ func (g *Game) saveGameResult(winner int) {
 		"startTime": g.StartTime,
 	}
 
+	// The game may change once the caller lets go of it
+	db, gameID, botRating := g.DB, g.DBGameID, g.BotRating
+	counted := !g.Substituted && g.EndReason != EndReasonAborted
+	movesWritten := g.moveWrites
 	go func() {
-		err := g.DB.UpdateGameResult(ctx, g.DBGameID, winnerID, gameState)
+		// The result follows the last move
+		if movesWritten != nil {
+			<-movesWritten
+		}
+		var err error
+		if counted {
+			err = db.UpdateGameResult(ctx, gameID, winnerID, winner == 0, gameState, botRating)
+		} else {
+			err = db.CloseGame(ctx, gameID, winnerID, gameState)
+		}
 		if err != nil {
-			log.Printf("[DB] ❌ Error saving game result (GameID=%d): %v", g.DBGameID, err)
+			log.Printf("[DB] ❌ Error saving game result (GameID=%d): %v", gameID, err)
 		} else {
-			log.Printf("[DB] ✅ Game result saved successfully (GameID=%d, WinnerID=%v)", g.DBGameID, winnerID)
+			log.Printf("[DB] ✅ Game result saved successfully (GameID=%d, WinnerID=%v)", gameID, winnerID)
 		}
 	}()
 }
@@ -258,6 +445,19 @@ func (b *Board) IsBoardFull() bool {
 	return true
 }
 
+// MoveCount returns the number of discs dropped so far
+func (b *Board) MoveCount() int {
+	count := 0
+	for _, row := range b.Grid {
+		for _, cell := range row {
+			if cell != 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
 // IsValidMove checks if a move can be made in the specified column
 func (b *Board) IsValidMove(column int) bool {
 	if column < 0 || column >= 7 {
@@ -0,0 +1,217 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/database"
+)
+
+// TestMovesSurviveSnapshot records moves in play order and keeps them across
+// a restart, so the restored game can write them again
+func TestMovesSurviveSnapshot(t *testing.T) {
+	g, err := NewGame(nil, Player{Username: "red"}, Player{Username: "yellow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []int{3, 3, 4} {
+		if err := g.MakeMove(column); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restored := RestoreGame(nil, g.Snapshot())
+	if err := restored.MakeMove(0); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		player      string
+		column, row int
+	}{
+		{"red", 3, 5},
+		{"yellow", 3, 4},
+		{"red", 4, 5},
+		{"yellow", 0, 5},
+	}
+	if len(restored.Moves) != len(want) {
+		t.Fatalf("%d moves recorded, want %d", len(restored.Moves), len(want))
+	}
+	for i, w := range want {
+		m := restored.Moves[i]
+		if m.Ply != i+1 || m.Player != w.player || m.Column != w.column || m.Row != w.row {
+			t.Errorf("move %d = %+v, want ply %d by %s at column %d row %d", i, m, i+1, w.player, w.column, w.row)
+		}
+	}
+	if len(g.Moves) != 3 {
+		t.Errorf("the original game has %d moves after the restored one moved, want 3", len(g.Moves))
+	}
+}
+
+// fakeMoveStore records the moves written, slowly enough that writes would
+// overtake each other if they were not ordered
+type fakeMoveStore struct {
+	mu    sync.Mutex
+	saved []database.Move
+	games []int
+}
+
+func (s *fakeMoveStore) SaveMove(ctx context.Context, gameID int, m database.Move) error {
+	time.Sleep(time.Duration(7-m.Ply%7) * time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, m)
+	s.games = append(s.games, gameID)
+	return nil
+}
+
+// TestMovesWrittenAsPlayed writes each move of a recorded game when it is
+// played, in play order and keyed by the game record
+func TestMovesWrittenAsPlayed(t *testing.T) {
+	g, err := NewGame(nil, Player{Username: "red"}, Player{Username: "yellow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeMoveStore{}
+	g.DBGameID, g.moveStore = 42, store
+	columns := []int{3, 3, 4, 4, 5, 2, 0}
+	for _, column := range columns {
+		if err := g.MakeMove(column); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case <-g.moveWrites:
+	case <-time.After(5 * time.Second):
+		t.Fatal("moves not written")
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if len(store.saved) != len(columns) {
+		t.Fatalf("%d moves written, want %d", len(store.saved), len(columns))
+	}
+	for i, m := range store.saved {
+		if m.Ply != i+1 || m.Column != columns[i] || store.games[i] != 42 {
+			t.Errorf("write %d: move %+v of game %d, want ply %d at column %d of game 42", i, m, store.games[i], i+1, columns[i])
+		}
+	}
+}
+
+// TestUnrecordedMovesNotWritten keeps the moves of a game without a record
+// in memory only
+func TestUnrecordedMovesNotWritten(t *testing.T) {
+	g, err := NewGame(nil, Player{Username: "red"}, Player{Username: "yellow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &fakeMoveStore{}
+	g.moveStore = store
+	if err := g.MakeMove(3); err != nil {
+		t.Fatal(err)
+	}
+	if g.moveWrites != nil || len(g.Moves) != 1 {
+		t.Errorf("game without a record: write started %v, %d moves kept", g.moveWrites != nil, len(g.Moves))
+	}
+}
+
+// TestSnapshotRoundTrip stores a game as JSON and restores it. The position,
+// turn, settings and clocks come back; the clock of the player to move
+// restarts so downtime is not charged.
+func TestSnapshotRoundTrip(t *testing.T) {
+	g, err := NewGame(nil, Player{Username: "red"}, Player{Username: "yellow", IsBot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.SetSettings(Settings{Variant: VariantStandard, TimeControl: &TimeControl{InitialSeconds: 60, IncrementSeconds: 1}, Rated: true, BestOf: 3})
+	g.SetFirstTurn(2)
+	for _, column := range []int{3, 4, 3} {
+		if err := g.MakeMove(column); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.Substituted, g.BotRating = true, 1720
+	// Time the player to move has already used
+	g.Clock.TurnStart = time.Now().Add(-10 * time.Second)
+
+	data, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	restored := RestoreGame(nil, snap)
+
+	if restored.Board.Grid != g.Board.Grid || restored.Board.LastMove != g.Board.LastMove || restored.Board.MoveCount() != 3 {
+		t.Errorf("board %v (last move %+v), want %v (%+v)", restored.Board.Grid, restored.Board.LastMove, g.Board.Grid, g.Board.LastMove)
+	}
+	if restored.CurrentTurn != g.CurrentTurn || restored.FirstTurn != 2 || !restored.IsActive {
+		t.Errorf("turn %d, first turn %d, active %v; want %d, 2, true", restored.CurrentTurn, restored.FirstTurn, restored.IsActive, g.CurrentTurn)
+	}
+	if restored.Player1 != g.Player1 || restored.Player2 != g.Player2 || !restored.Substituted || restored.BotRating != 1720 {
+		t.Errorf("players %+v %+v, substituted %v, bot rating %v", restored.Player1, restored.Player2, restored.Substituted, restored.BotRating)
+	}
+	if restored.Settings.BestOf != 3 || !restored.Settings.Rated || restored.Clock == nil {
+		t.Fatalf("settings %+v, clock %v", restored.Settings, restored.Clock)
+	}
+
+	now := time.Now()
+	waiting := 3 - g.CurrentTurn
+	if got, want := restored.Clock.RemainingFor(waiting, restored.CurrentTurn, now), g.Clock.Remaining[waiting-1]; got != want.Truncate(time.Millisecond) {
+		t.Errorf("waiting player's clock %v, want %v", got, want)
+	}
+	toMove := restored.Clock.RemainingFor(restored.CurrentTurn, restored.CurrentTurn, now)
+	if used := g.Clock.Remaining[g.CurrentTurn-1] - 10*time.Second; toMove > used || toMove < used-time.Second {
+		t.Errorf("clock of the player to move %v, want about %v", toMove, used)
+	}
+	if err := restored.MakeMove(0); err != nil {
+		t.Errorf("the restored game cannot go on: %v", err)
+	}
+}
+
+// Snapshots written before the first move rotated give it to seat 1
+func TestRestoreOldSnapshot(t *testing.T) {
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(`{"id": "g1", "currentTurn": 1, "isActive": true, "settings": {"variant": "standard"}}`), &snap); err != nil {
+		t.Fatal(err)
+	}
+	g := RestoreGame(nil, snap)
+	if g.FirstTurn != 1 || g.Clock != nil || g.ID != "g1" {
+		t.Errorf("restored %s with first turn %d, clock %v", g.ID, g.FirstTurn, g.Clock)
+	}
+}
+
+// The seat that moves first plays red
+func TestSetFirstTurn(t *testing.T) {
+	tests := []struct {
+		seat      int
+		wantFirst int
+	}{
+		{1, 1},
+		{2, 2},
+		// Not a seat; the default stands
+		{0, 1},
+		{3, 1},
+	}
+	for _, tt := range tests {
+		g, err := NewGame(nil, Player{Username: "alice"}, Player{Username: "bob"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.SetFirstTurn(tt.seat)
+		if g.FirstTurn != tt.wantFirst || g.CurrentTurn != tt.wantFirst {
+			t.Errorf("SetFirstTurn(%d): first turn %d, current turn %d; want %d", tt.seat, g.FirstTurn, g.CurrentTurn, tt.wantFirst)
+		}
+		other := 3 - tt.wantFirst
+		if g.ColorOf(tt.wantFirst) != ColorRed || g.ColorOf(other) != ColorYellow {
+			t.Errorf("SetFirstTurn(%d): seat %d is %s, seat %d is %s", tt.seat, tt.wantFirst, g.ColorOf(tt.wantFirst), other, g.ColorOf(other))
+		}
+		if state := g.GetState(); state.FirstTurn != tt.wantFirst {
+			t.Errorf("SetFirstTurn(%d): state reports first turn %d", tt.seat, state.FirstTurn)
+		}
+	}
+}
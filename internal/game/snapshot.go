@@ -0,0 +1,108 @@
+package game
+
+import (
+	"time"
+
+	"github.com/connect4/backend/internal/database"
+)
+
+// Snapshot is a serializable copy of a game's progress, used to carry
+// in-progress games across server restarts
+type Snapshot struct {
+	ID       string    `json:"id"`
+	Grid     [6][7]int `json:"grid"`
+	LastMove struct {
+		Row    int `json:"row"`
+		Column int `json:"column"`
+		Player int `json:"player"`
+	} `json:"lastMove"`
+	Player1      Player   `json:"player1"`
+	Player2      Player   `json:"player2"`
+	CurrentTurn  int      `json:"currentTurn"`
+	FirstTurn    int      `json:"firstTurn,omitempty"`
+	IsActive     bool     `json:"isActive"`
+	StartTime    int64    `json:"startTime"`
+	LastMoveTime int64    `json:"lastMoveTime"`
+	DBGameID     int      `json:"dbGameId"`
+	Settings     Settings `json:"settings"`
+	Substituted  bool     `json:"substituted,omitempty"`
+	BotRating    float64  `json:"botRating,omitempty"`
+	// Moves played so far, written again on restore in case the last ones
+	// were lost
+	Moves []database.Move `json:"moves,omitempty"`
+	// Remaining clock time per player at the moment of the snapshot
+	ClockRemainingMs *[2]int64 `json:"clockRemainingMs,omitempty"`
+}
+
+// Snapshot captures the current game progress
+func (g *Game) Snapshot() Snapshot {
+	s := Snapshot{
+		ID:           g.ID,
+		Grid:         g.Board.Grid,
+		Player1:      g.Player1,
+		Player2:      g.Player2,
+		CurrentTurn:  g.CurrentTurn,
+		FirstTurn:    g.FirstTurn,
+		IsActive:     g.IsActive,
+		StartTime:    g.StartTime,
+		LastMoveTime: g.LastMoveTime,
+		DBGameID:     g.DBGameID,
+		Settings:     g.Settings,
+		Substituted:  g.Substituted,
+		BotRating:    g.BotRating,
+		Moves:        g.Moves,
+	}
+	s.LastMove = g.Board.LastMove
+
+	if g.Clock != nil {
+		now := time.Now()
+		s.ClockRemainingMs = &[2]int64{
+			g.Clock.RemainingFor(1, g.CurrentTurn, now).Milliseconds(),
+			g.Clock.RemainingFor(2, g.CurrentTurn, now).Milliseconds(),
+		}
+	}
+	return s
+}
+
+// RestoreGame rebuilds a game from a snapshot. The clock of the player to
+// move restarts now, so time spent while the server was down is not charged.
+// Moves that may not have reached the game record are written again.
+func RestoreGame(db *database.DB, s Snapshot) *Game {
+	g := &Game{
+		ID: s.ID,
+		Board: Board{
+			Grid:    s.Grid,
+			Columns: 7,
+			Rows:    6,
+		},
+		Player1:      s.Player1,
+		Player2:      s.Player2,
+		CurrentTurn:  s.CurrentTurn,
+		FirstTurn:    s.FirstTurn,
+		IsActive:     s.IsActive,
+		StartTime:    s.StartTime,
+		LastMoveTime: s.LastMoveTime,
+		DB:           db,
+		DBGameID:     s.DBGameID,
+		Substituted:  s.Substituted,
+		BotRating:    s.BotRating,
+		Moves:        s.Moves,
+	}
+	g.Board.LastMove = s.LastMove
+	if g.FirstTurn == 0 {
+		// Snapshots from before first-move rotation
+		g.FirstTurn = 1
+	}
+	g.SetSettings(s.Settings)
+	if db != nil {
+		g.moveStore = db
+		g.writeMoves(g.Moves...)
+	}
+
+	if g.Clock != nil && s.ClockRemainingMs != nil {
+		g.Clock.Remaining[0] = time.Duration(s.ClockRemainingMs[0]) * time.Millisecond
+		g.Clock.Remaining[1] = time.Duration(s.ClockRemainingMs[1]) * time.Millisecond
+		g.Clock.TurnStart = time.Now()
+	}
+	return g
+}
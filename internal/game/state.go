@@ -2,6 +2,7 @@ package game
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // GameStatus represents the current state of the game
@@ -12,17 +13,24 @@ const (
 	StatusInProgress GameStatus = "in_progress"
 	StatusCompleted  GameStatus = "completed"
 	StatusDraw       GameStatus = "draw"
+	StatusAborted    GameStatus = "aborted"
 )
 
 // GameState represents the current state of the game for client updates
 type GameState struct {
-	ID          string     `json:"id"`
-	Board       [][]int    `json:"board"`
-	CurrentTurn int        `json:"currentTurn"`
-	Status      GameStatus `json:"status"`
-	Player1     *Player    `json:"player1,omitempty"`
-	Player2     *Player    `json:"player2,omitempty"`
-	Winner      *Player    `json:"winner,omitempty"`
+	ID          string      `json:"id"`
+	Board       [][]int     `json:"board"`
+	CurrentTurn int         `json:"currentTurn"`
+	MoveNumber  int         `json:"moveNumber"` // moves played so far
+	FirstTurn   int         `json:"firstTurn"`  // seat that moved first, playing red
+	Status      GameStatus  `json:"status"`
+	Player1     *Player     `json:"player1,omitempty"`
+	Player2     *Player     `json:"player2,omitempty"`
+	Winner      *Player     `json:"winner,omitempty"`
+	EndReason   string      `json:"endReason,omitempty"`
+	Settings    *Settings   `json:"settings,omitempty"`
+	Clock       *ClockState `json:"clock,omitempty"`
+	Substituted bool        `json:"substituted,omitempty"`
 	LastMove    *struct {
 		Row    int `json:"row"`
 		Column int `json:"column"`
@@ -44,6 +52,8 @@ func (g *Game) GetState() *GameState {
 		ID:          g.ID,
 		Board:       boardCopy,
 		CurrentTurn: g.CurrentTurn,
+		MoveNumber:  g.Board.MoveCount(),
+		FirstTurn:   g.FirstTurn,
 		Player1:     &g.Player1,
 		Player2:     &g.Player2,
 		LastMove: &struct {
@@ -57,8 +67,29 @@ func (g *Game) GetState() *GameState {
 		},
 	}
 
+	settings := g.Settings
+	state.Settings = &settings
+	state.Substituted = g.Substituted
+	if g.Clock != nil {
+		state.Clock = g.Clock.State(g.CurrentTurn, time.Now())
+	}
+
 	if g.IsActive {
 		state.Status = StatusInProgress
+	} else if g.EndReason == EndReasonAborted {
+		state.Status = StatusAborted
+		state.EndReason = g.EndReason
+	} else if g.Drawn {
+		state.Status = StatusDraw
+		state.EndReason = g.EndReason
+	} else if g.Winner != 0 {
+		state.Status = StatusCompleted
+		state.EndReason = g.EndReason
+		if g.Winner == 1 {
+			state.Winner = &g.Player1
+		} else {
+			state.Winner = &g.Player2
+		}
 	} else {
 		if g.Board.IsBoardFull() {
 			state.Status = StatusDraw
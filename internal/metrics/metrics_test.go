@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewCounterReturnsRegistered(t *testing.T) {
+	c := NewCounter("test_registered_total", "Registered once")
+	c.Add(2)
+	again := NewCounter("test_registered_total", "Registered twice")
+	again.Inc()
+	if again != c || c.Value() != 3 {
+		t.Errorf("registering twice gave a new counter or lost counts: %d", c.Value())
+	}
+}
+
+// The handler writes every counter in the Prometheus text format, sorted by
+// name
+func TestHandler(t *testing.T) {
+	NewCounter("test_handler_b_total", "Second counter").Add(5)
+	NewCounter("test_handler_a_total", "First counter")
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4" {
+		t.Errorf("content type %q", ct)
+	}
+	body := rec.Body.String()
+	want := "# HELP test_handler_a_total First counter\n# TYPE test_handler_a_total counter\ntest_handler_a_total 0\n" +
+		"# HELP test_handler_b_total Second counter\n# TYPE test_handler_b_total counter\ntest_handler_b_total 5\n"
+	if !strings.Contains(body, want) {
+		t.Errorf("metrics output:\n%s\nwant it to contain:\n%s", body, want)
+	}
+}
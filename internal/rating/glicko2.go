@@ -0,0 +1,138 @@
+package rating
+
+import (
+	"math"
+	"time"
+)
+
+// Ratings new players start with
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+)
+
+const (
+	tau         = 0.5      // how much volatility may change per game
+	scale       = 173.7178 // between the Glicko and Glicko-2 scales
+	convergence = 0.000001 // precision of the volatility iteration
+	// fixedDeviation is the deviation of fixed ratings, such as the bot's
+	fixedDeviation = 50.0
+)
+
+// IdlePeriod is how long a player must go without rated games for their
+// rating to count as one period less certain
+const IdlePeriod = 7 * 24 * time.Hour
+
+// Rating is a Glicko-2 rating: the estimated strength, how uncertain it is
+// and how erratic the player's results are
+type Rating struct {
+	Rating     float64 `json:"rating"`
+	Deviation  float64 `json:"deviation"`
+	Volatility float64 `json:"volatility"`
+}
+
+// Default returns the rating of a new player
+func Default() Rating {
+	return Rating{Rating: DefaultRating, Deviation: DefaultDeviation, Volatility: DefaultVolatility}
+}
+
+// Fixed returns a well-established rating of r, for opponents whose own
+// rating never changes
+func Fixed(r float64) Rating {
+	return Rating{Rating: r, Deviation: fixedDeviation, Volatility: DefaultVolatility}
+}
+
+// Result is one game of a rating period: the opponent's rating before the
+// game and the score, 1 for a win, 0.5 for a draw and 0 for a loss
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Update returns r after a single game against opponent. Every game is
+// rated as a rating period of its own.
+func Update(r, opponent Rating, score float64) Rating {
+	return UpdatePeriod(r, []Result{{Opponent: opponent, Score: score}})
+}
+
+// Idle returns r after periods rating periods without games, in which only
+// its deviation grows
+func Idle(r Rating, periods int) Rating {
+	for i := 0; i < periods && r.Deviation < DefaultDeviation; i++ {
+		r = UpdatePeriod(r, nil)
+	}
+	return r
+}
+
+// UpdatePeriod returns r after a rating period with the given results,
+// following Glickman's description of Glicko-2. Without results only the
+// deviation changes.
+func UpdatePeriod(r Rating, results []Result) Rating {
+	mu := (r.Rating - DefaultRating) / scale
+	phi := r.Deviation / scale
+
+	if len(results) == 0 {
+		phiStar := math.Sqrt(phi*phi + r.Volatility*r.Volatility)
+		r.Deviation = math.Min(phiStar*scale, DefaultDeviation)
+		return r
+	}
+
+	var vInv, sum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - DefaultRating) / scale
+		phiJ := res.Opponent.Deviation / scale
+		g := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		vInv += g * g * e * (1 - e)
+		sum += g * (res.Score - e)
+	}
+	v := 1 / vInv
+	delta := v * sum
+
+	sigma := newVolatility(phi, r.Volatility, v, delta)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*sum
+
+	return Rating{
+		Rating:     newMu*scale + DefaultRating,
+		Deviation:  math.Min(newPhi*scale, DefaultDeviation),
+		Volatility: sigma,
+	}
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm
+func newVolatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > convergence {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
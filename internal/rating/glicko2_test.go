@@ -0,0 +1,104 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+// The worked example of Glickman's "Example of the Glicko-2 system": a
+// player rated 1500 (RD 200) plays three opponents in one period
+func TestUpdatePeriodGlickmanExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	results := []Result{
+		{Opponent: Rating{Rating: 1400, Deviation: 30, Volatility: DefaultVolatility}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100, Volatility: DefaultVolatility}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300, Volatility: DefaultVolatility}, Score: 0},
+	}
+
+	got := UpdatePeriod(player, results)
+	if !near(got.Rating, 1464.06, 0.01) {
+		t.Errorf("rating = %.4f, want 1464.06", got.Rating)
+	}
+	if !near(got.Deviation, 151.52, 0.01) {
+		t.Errorf("deviation = %.4f, want 151.52", got.Deviation)
+	}
+	if !near(got.Volatility, 0.05999, 0.00001) {
+		t.Errorf("volatility = %.6f, want 0.05999", got.Volatility)
+	}
+}
+
+// A period without games leaves the rating alone and widens the deviation
+// to sqrt(phi^2 + sigma^2) on the Glicko-2 scale
+func TestUpdatePeriodWithoutGames(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Rating
+		want float64
+	}{
+		{"established", Rating{Rating: 1700, Deviation: 200, Volatility: 0.06}, 200.2714},
+		{"settled", Rating{Rating: 1400, Deviation: 50, Volatility: 0.06}, 51.0749},
+		{"capped", Rating{Rating: 1500, Deviation: 349.9, Volatility: 0.06}, DefaultDeviation},
+	}
+	for _, tt := range tests {
+		got := UpdatePeriod(tt.in, nil)
+		if got.Rating != tt.in.Rating || got.Volatility != tt.in.Volatility {
+			t.Errorf("%s: rating or volatility changed: %+v", tt.name, got)
+		}
+		if !near(got.Deviation, tt.want, 0.001) {
+			t.Errorf("%s: deviation = %.4f, want %.4f", tt.name, got.Deviation, tt.want)
+		}
+	}
+}
+
+func TestIdle(t *testing.T) {
+	r := Rating{Rating: 1600, Deviation: 60, Volatility: 0.06}
+	if got := Idle(r, 0); got != r {
+		t.Errorf("no idle periods changed the rating: %+v", got)
+	}
+	once := Idle(r, 1)
+	if once != UpdatePeriod(r, nil) {
+		t.Errorf("one idle period = %+v, want %+v", once, UpdatePeriod(r, nil))
+	}
+	if twice := Idle(r, 2); twice.Deviation <= once.Deviation {
+		t.Errorf("deviation after two periods %.4f is not above one period's %.4f", twice.Deviation, once.Deviation)
+	}
+	if long := Idle(r, 100000); long.Deviation != DefaultDeviation {
+		t.Errorf("deviation after a long break = %.4f, want %v", long.Deviation, DefaultDeviation)
+	}
+}
+
+func TestUpdateSingleGame(t *testing.T) {
+	player := Default()
+	equal := Default()
+
+	win := Update(player, equal, 1)
+	loss := Update(player, equal, 0)
+	draw := Update(player, equal, 0.5)
+	if win.Rating <= player.Rating || loss.Rating >= player.Rating {
+		t.Errorf("win %.2f and loss %.2f do not straddle %.2f", win.Rating, loss.Rating, player.Rating)
+	}
+	if !near(win.Rating-player.Rating, player.Rating-loss.Rating, 1e-9) {
+		t.Errorf("win gains %.4f but loss costs %.4f against an equal opponent", win.Rating-player.Rating, player.Rating-loss.Rating)
+	}
+	if !near(draw.Rating, player.Rating, 1e-9) {
+		t.Errorf("draw against an equal opponent moved the rating to %.4f", draw.Rating)
+	}
+	for _, r := range []Rating{win, loss, draw} {
+		if r.Deviation >= player.Deviation {
+			t.Errorf("deviation %.2f did not shrink after a game", r.Deviation)
+		}
+	}
+
+	// Beating a much weaker, well-known opponent gains little
+	weak := Fixed(1000)
+	if gain := Update(player, weak, 1).Rating - player.Rating; gain <= 0 || gain >= win.Rating-player.Rating {
+		t.Errorf("beating a 1000 gains %.2f, want less than beating an equal's %.2f", gain, win.Rating-player.Rating)
+	}
+	if got := Update(player, equal, 1); got != UpdatePeriod(player, []Result{{Opponent: equal, Score: 1}}) {
+		t.Errorf("Update and a one-game UpdatePeriod differ: %+v", got)
+	}
+}
@@ -0,0 +1,118 @@
+package tournament
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/connect4/backend/internal/game"
+)
+
+// createRequest is the body of POST /tournaments
+type createRequest struct {
+	Name     string         `json:"name"`
+	Format   Format         `json:"format"`
+	Rounds   int            `json:"rounds"`
+	Settings *game.Settings `json:"settings"`
+}
+
+// registerRequest is the body of POST /tournaments/{id}/register
+type registerRequest struct {
+	Username string `json:"username"`
+}
+
+// HandleTournaments serves the tournament API:
+//
+//	GET  /tournaments               list tournaments
+//	POST /tournaments               create a tournament
+//	GET  /tournaments/{id}          tournament with pairings and standings
+//	POST /tournaments/{id}/register register a player
+//	POST /tournaments/{id}/start    close registration and start round one
+func (m *Manager) HandleTournaments(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/tournaments"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(m.List())
+
+	case path == "" && r.Method == http.MethodPost:
+		var req createRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		settings := game.DefaultSettings()
+		if req.Settings != nil {
+			settings = *req.Settings
+		}
+		t, err := m.Create(req.Name, req.Format, settings, req.Rounds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(t)
+
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		t, err := m.Get(parts[0])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(t)
+
+	case len(parts) == 2 && parts[1] == "register" && r.Method == http.MethodPost:
+		var req registerRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if err := m.Register(parts[0], req.Username); err != nil {
+			writeError(w, err)
+			return
+		}
+		m.writeTournament(w, parts[0])
+
+	case len(parts) == 2 && parts[1] == "start" && r.Method == http.MethodPost:
+		if err := m.Start(parts[0]); err != nil {
+			writeError(w, err)
+			return
+		}
+		m.writeTournament(w, parts[0])
+
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *Manager) writeTournament(w http.ResponseWriter, id string) {
+	t, err := m.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(t)
+}
+
+// writeError maps manager errors to HTTP status codes
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, ErrNotRegistering), errors.Is(err, ErrAlreadyRegistered):
+		status = http.StatusConflict
+	}
+	if status >= http.StatusInternalServerError {
+		log.Printf("[TOURNAMENT] Request failed: %v", err)
+	}
+	http.Error(w, err.Error(), status)
+}
@@ -0,0 +1,236 @@
+package tournament
+
+import "sort"
+
+// swissSearchBudget bounds the backtracking search for rematch-free Swiss
+// pairings; past it, rematches are allowed
+const swissSearchBudget = 100000
+
+// roundRobinSchedule builds every round with the circle method. With an odd
+// number of players one player sits out each round with a bye.
+func roundRobinSchedule(players []string) [][]*Pairing {
+	ring := append([]string{}, players...)
+	if len(ring)%2 == 1 {
+		ring = append(ring, "")
+	}
+	n := len(ring)
+
+	// The rotation keeps moving players between the two sides of the
+	// circle, so who moves first is balanced by count instead
+	firsts := make(map[string]int, n)
+	rounds := make([][]*Pairing, 0, n-1)
+	for r := 0; r < n-1; r++ {
+		var pairings []*Pairing
+		for i := 0; i < n/2; i++ {
+			a, b := ring[i], ring[n-1-i]
+			if firsts[a] > firsts[b] || (firsts[a] == firsts[b] && (r+i)%2 == 1) {
+				a, b = b, a
+			}
+			if a == "" {
+				a, b = b, a
+			} else if b != "" {
+				firsts[a]++
+			}
+			pairings = append(pairings, &Pairing{Player1: a, Player2: b})
+		}
+		sortByes(pairings)
+		rounds = append(rounds, pairings)
+
+		// Keep the first player fixed and rotate the rest
+		last := ring[n-1]
+		copy(ring[2:], ring[1:n-1])
+		ring[1] = last
+	}
+	return rounds
+}
+
+// defaultSwissRounds is enough rounds to separate a single winner
+func defaultSwissRounds(players int) int {
+	rounds := 1
+	for 1<<rounds < players {
+		rounds++
+	}
+	if rounds > players-1 {
+		rounds = players - 1
+	}
+	return rounds
+}
+
+// pairSwiss pairs players with equal or close scores who have not met yet.
+// The lowest ranked player without a bye gets one when the field is odd.
+func pairSwiss(t *Tournament) []*Pairing {
+	table := computeStandings(t)
+	ranked := make([]string, len(table))
+	for i, s := range table {
+		ranked[i] = s.Username
+	}
+
+	met := make(map[string]map[string]bool)
+	firsts := make(map[string]int)
+	hadBye := make(map[string]bool)
+	for _, round := range t.Rounds {
+		for _, p := range round {
+			if p.Player2 == "" {
+				hadBye[p.Player1] = true
+				continue
+			}
+			if met[p.Player1] == nil {
+				met[p.Player1] = make(map[string]bool)
+			}
+			if met[p.Player2] == nil {
+				met[p.Player2] = make(map[string]bool)
+			}
+			met[p.Player1][p.Player2] = true
+			met[p.Player2][p.Player1] = true
+			firsts[p.Player1]++
+		}
+	}
+
+	var pairings []*Pairing
+	if len(ranked)%2 == 1 {
+		byeAt := len(ranked) - 1
+		for i := len(ranked) - 1; i >= 0; i-- {
+			if !hadBye[ranked[i]] {
+				byeAt = i
+				break
+			}
+		}
+		pairings = append(pairings, &Pairing{Player1: ranked[byeAt]})
+		ranked = append(ranked[:byeAt:byeAt], ranked[byeAt+1:]...)
+	}
+
+	budget := swissSearchBudget
+	pairs, ok := pairWithoutRematches(ranked, met, &budget)
+	if !ok {
+		pairs = nil
+		for i := 0; i+1 < len(ranked); i += 2 {
+			pairs = append(pairs, [2]string{ranked[i], ranked[i+1]})
+		}
+	}
+
+	games := make([]*Pairing, 0, len(pairs))
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		// The player who has started fewer games moves first
+		if firsts[b] < firsts[a] {
+			a, b = b, a
+		}
+		games = append(games, &Pairing{Player1: a, Player2: b})
+	}
+	return append(games, pairings...)
+}
+
+// pairWithoutRematches pairs the top player with the best ranked opponent
+// they have not met, backtracking when the rest cannot be paired
+func pairWithoutRematches(ranked []string, met map[string]map[string]bool, budget *int) ([][2]string, bool) {
+	if len(ranked) == 0 {
+		return nil, true
+	}
+	top := ranked[0]
+	for i := 1; i < len(ranked); i++ {
+		*budget--
+		if *budget < 0 {
+			return nil, false
+		}
+		opponent := ranked[i]
+		if met[top][opponent] {
+			continue
+		}
+		rest := make([]string, 0, len(ranked)-2)
+		rest = append(rest, ranked[1:i]...)
+		rest = append(rest, ranked[i+1:]...)
+		if pairs, ok := pairWithoutRematches(rest, met, budget); ok {
+			return append([][2]string{{top, opponent}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// knockoutRounds is the number of rounds of a single-elimination bracket
+func knockoutRounds(players int) int {
+	rounds := 0
+	for 1<<rounds < players {
+		rounds++
+	}
+	return rounds
+}
+
+// bracketOrder returns seed numbers in bracket position order so the top
+// seeds can only meet in the late rounds, e.g. 1 8 4 5 2 7 3 6
+func bracketOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		n := len(order) * 2
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// pairKnockout builds the first round from the seeded bracket, and later
+// rounds from the winners of neighbouring matches. Byes go to the top seeds.
+func pairKnockout(t *Tournament) []*Pairing {
+	if len(t.Rounds) == 0 {
+		order := bracketOrder(1 << knockoutRounds(len(t.Players)))
+		var pairings []*Pairing
+		for i := 0; i < len(order); i += 2 {
+			a, b := seedName(t, order[i]), seedName(t, order[i+1])
+			if a == "" {
+				a, b = b, a
+			}
+			pairings = append(pairings, &Pairing{Player1: a, Player2: b})
+		}
+		return pairings
+	}
+
+	previous := t.Rounds[len(t.Rounds)-1]
+	var pairings []*Pairing
+	for i := 0; i+1 < len(previous); i += 2 {
+		pairings = append(pairings, &Pairing{
+			Player1: advancer(t, previous[i]),
+			Player2: advancer(t, previous[i+1]),
+		})
+	}
+	return pairings
+}
+
+// advancer returns who goes through from a knockout pairing. A draw sends
+// the better seed through.
+func advancer(t *Tournament, p *Pairing) string {
+	switch p.Result {
+	case ResultWin, ResultBye:
+		return p.Player1
+	case ResultLoss:
+		return p.Player2
+	}
+	if seedOf(t, p.Player2) < seedOf(t, p.Player1) {
+		return p.Player2
+	}
+	return p.Player1
+}
+
+func seedName(t *Tournament, seed int) string {
+	if seed > len(t.Players) {
+		return ""
+	}
+	return t.Players[seed-1]
+}
+
+func seedOf(t *Tournament, username string) int {
+	for i, p := range t.Players {
+		if p == username {
+			return i + 1
+		}
+	}
+	return len(t.Players) + 1
+}
+
+// sortByes moves byes to the last boards
+func sortByes(pairings []*Pairing) {
+	sort.SliceStable(pairings, func(i, j int) bool {
+		return pairings[i].Player2 != "" && pairings[j].Player2 == ""
+	})
+}
@@ -0,0 +1,251 @@
+package tournament
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func players(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("p%d", i+1)
+	}
+	return names
+}
+
+// pairs lists the pairings of a round as "player1-player2", byes as "player1-"
+func pairs(round []*Pairing) []string {
+	out := make([]string, len(round))
+	for i, p := range round {
+		out[i] = p.Player1 + "-" + p.Player2
+	}
+	return out
+}
+
+// decide sets the result of every game of a round from the point of view of
+// Player1 and numbers the round, the way the manager would
+func decide(number int, round []*Pairing, result func(p *Pairing) Result) []*Pairing {
+	for i, p := range round {
+		p.Round = number
+		p.Board = i + 1
+		if p.Player2 == "" {
+			p.Result = ResultBye
+			continue
+		}
+		p.Result = result(p)
+	}
+	return round
+}
+
+func seedWins(t *Tournament) func(p *Pairing) Result {
+	return func(p *Pairing) Result {
+		if seedOf(t, p.Player1) < seedOf(t, p.Player2) {
+			return ResultWin
+		}
+		return ResultLoss
+	}
+}
+
+func TestRoundRobinSchedule(t *testing.T) {
+	tests := []struct {
+		players int
+		rounds  int
+		games   int // per round, byes included
+	}{
+		{2, 1, 1},
+		{3, 3, 2},
+		{4, 3, 2},
+		{5, 5, 3},
+		{6, 5, 3},
+		{9, 9, 5},
+	}
+	for _, tt := range tests {
+		names := players(tt.players)
+		schedule := roundRobinSchedule(names)
+		if len(schedule) != tt.rounds {
+			t.Errorf("%d players: %d rounds, want %d", tt.players, len(schedule), tt.rounds)
+			continue
+		}
+
+		met := make(map[[2]string]int)
+		byes := make(map[string]int)
+		firsts := make(map[string]int)
+		games := make(map[string]int)
+		for r, round := range schedule {
+			if len(round) != tt.games {
+				t.Errorf("%d players, round %d: %d pairings, want %d", tt.players, r+1, len(round), tt.games)
+			}
+			seated := make(map[string]bool)
+			for i, p := range round {
+				for _, name := range []string{p.Player1, p.Player2} {
+					if name != "" && seated[name] {
+						t.Errorf("%d players, round %d: %s plays twice", tt.players, r+1, name)
+					}
+					seated[name] = true
+				}
+				if p.Player1 == "" {
+					t.Errorf("%d players, round %d: empty first seat", tt.players, r+1)
+				}
+				if p.Player2 == "" {
+					byes[p.Player1]++
+					if i != len(round)-1 {
+						t.Errorf("%d players, round %d: bye on board %d, want the last board", tt.players, r+1, i+1)
+					}
+					continue
+				}
+				a, b := p.Player1, p.Player2
+				if b < a {
+					a, b = b, a
+				}
+				met[[2]string{a, b}]++
+				firsts[p.Player1]++
+				games[p.Player1]++
+				games[p.Player2]++
+			}
+		}
+
+		for i, a := range names {
+			for _, b := range names[i+1:] {
+				if n := met[[2]string{a, b}]; n != 1 {
+					t.Errorf("%d players: %s and %s meet %d times, want once", tt.players, a, b, n)
+				}
+			}
+			wantByes := tt.players % 2
+			if byes[a] != wantByes {
+				t.Errorf("%d players: %s has %d byes, want %d", tt.players, a, byes[a], wantByes)
+			}
+			if diff := 2*firsts[a] - games[a]; diff < -1 || diff > 1 {
+				t.Errorf("%d players: %s moves first in %d of %d games", tt.players, a, firsts[a], games[a])
+			}
+		}
+	}
+}
+
+func TestDefaultSwissRounds(t *testing.T) {
+	tests := []struct{ players, rounds int }{
+		{2, 1},
+		{3, 2},
+		{4, 2},
+		{5, 3},
+		{8, 3},
+		{9, 4},
+		{64, 6},
+	}
+	for _, tt := range tests {
+		if got := defaultSwissRounds(tt.players); got != tt.rounds {
+			t.Errorf("defaultSwissRounds(%d) = %d, want %d", tt.players, got, tt.rounds)
+		}
+	}
+}
+
+func TestPairSwiss(t *testing.T) {
+	tests := []struct {
+		name    string
+		players int
+		// Results of the rounds played so far: the better seed wins
+		played int
+		want   []string
+	}{
+		{"first round pairs by seed", 4, 0, []string{"p1-p2", "p3-p4"}},
+		{"winners meet winners", 4, 1, []string{"p1-p3", "p2-p4"}},
+		{"no rematch in the last round", 4, 2, []string{"p4-p1", "p2-p3"}},
+		{"lowest seed gets the first bye", 5, 0, []string{"p1-p2", "p3-p4", "p5-"}},
+		{"nobody gets a second bye", 5, 1, []string{"p1-p3", "p5-p2", "p4-"}},
+	}
+	for _, tt := range tests {
+		tour := &Tournament{Format: FormatSwiss, Players: players(tt.players)}
+		for r := 1; r <= tt.played; r++ {
+			tour.Rounds = append(tour.Rounds, decide(r, pairSwiss(tour), seedWins(tour)))
+		}
+		if got := pairs(pairSwiss(tour)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: pairings %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestSwissWithoutRematches plays as many Swiss rounds as there are
+// opponents. Every round must pair players who have not met yet.
+func TestSwissWithoutRematches(t *testing.T) {
+	for _, n := range []int{4, 6, 7, 8} {
+		tour := &Tournament{Format: FormatSwiss, Players: players(n)}
+		met := make(map[string]bool)
+		byes := make(map[string]bool)
+		rounds := n - 1 + n%2
+		for r := 1; r <= rounds; r++ {
+			round := pairSwiss(tour)
+			for _, p := range round {
+				if p.Player2 == "" {
+					if byes[p.Player1] {
+						t.Errorf("%d players, round %d: %s gets a second bye", n, r, p.Player1)
+					}
+					byes[p.Player1] = true
+					continue
+				}
+				key := p.Player1 + "-" + p.Player2
+				if p.Player2 < p.Player1 {
+					key = p.Player2 + "-" + p.Player1
+				}
+				if met[key] {
+					t.Errorf("%d players, round %d: rematch %s", n, r, key)
+				}
+				met[key] = true
+			}
+			tour.Rounds = append(tour.Rounds, decide(r, round, func(p *Pairing) Result { return ResultDraw }))
+		}
+	}
+}
+
+func TestKnockoutBracket(t *testing.T) {
+	rounds := []struct{ players, rounds int }{
+		{2, 1},
+		{3, 2},
+		{4, 2},
+		{5, 3},
+		{8, 3},
+		{16, 4},
+	}
+	for _, tt := range rounds {
+		if got := knockoutRounds(tt.players); got != tt.rounds {
+			t.Errorf("knockoutRounds(%d) = %d, want %d", tt.players, got, tt.rounds)
+		}
+	}
+
+	orders := map[int][]int{
+		2: {1, 2},
+		4: {1, 4, 2, 3},
+		8: {1, 8, 4, 5, 2, 7, 3, 6},
+	}
+	for size, want := range orders {
+		if got := bracketOrder(size); !reflect.DeepEqual(got, want) {
+			t.Errorf("bracketOrder(%d) = %v, want %v", size, got, want)
+		}
+	}
+}
+
+func TestPairKnockout(t *testing.T) {
+	tour := &Tournament{Format: FormatKnockout, Players: players(6)}
+
+	// The top seeds get the byes of a bracket of 8
+	first := pairKnockout(tour)
+	if got, want := pairs(first), []string{"p1-", "p4-p5", "p2-", "p3-p6"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("first round %v, want %v", got, want)
+	}
+	tour.Rounds = append(tour.Rounds, decide(1, first, func(p *Pairing) Result {
+		if p.Player1 == "p4" {
+			return ResultLoss
+		}
+		// A draw sends the better seed through
+		return ResultDraw
+	}))
+
+	second := pairKnockout(tour)
+	if got, want := pairs(second), []string{"p1-p5", "p2-p3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("second round %v, want %v", got, want)
+	}
+	tour.Rounds = append(tour.Rounds, decide(2, second, seedWins(tour)))
+
+	if got, want := pairs(pairKnockout(tour)), []string{"p1-p2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("final %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package tournament
+
+import "sort"
+
+// Points awarded per game
+const (
+	pointsWin  = 1.0
+	pointsDraw = 0.5
+	pointsBye  = 1.0
+)
+
+// Standing is a player's line in the tournament table. Ties on points are
+// broken by Buchholz (sum of the opponents' points), then Sonneborn-Berger
+// (points of beaten opponents plus half the points of drawn ones), then wins
+// and finally seed. Knockout tables rank by the round a player reached first.
+type Standing struct {
+	Rank            int     `json:"rank"`
+	Username        string  `json:"username"`
+	Seed            int     `json:"seed"`
+	Points          float64 `json:"points"`
+	Wins            int     `json:"wins"`
+	Draws           int     `json:"draws"`
+	Losses          int     `json:"losses"`
+	Byes            int     `json:"byes"`
+	Buchholz        float64 `json:"buchholz"`
+	SonnebornBerger float64 `json:"sonnebornBerger"`
+	Eliminated      int     `json:"eliminatedInRound,omitempty"` // knockout only
+}
+
+// computeStandings ranks the players of t by the results so far
+func computeStandings(t *Tournament) []Standing {
+	byName := make(map[string]*Standing, len(t.Players))
+	table := make([]*Standing, len(t.Players))
+	for i, p := range t.Players {
+		table[i] = &Standing{Username: p, Seed: i + 1}
+		byName[p] = table[i]
+	}
+
+	type outcome struct {
+		opponent string
+		score    float64
+	}
+	games := make(map[string][]outcome)
+
+	for _, round := range t.Rounds {
+		for _, p := range round {
+			s1 := byName[p.Player1]
+			if p.Result == ResultBye {
+				s1.Points += pointsBye
+				s1.Byes++
+				continue
+			}
+			s2 := byName[p.Player2]
+			var score1 float64
+			switch p.Result {
+			case ResultWin:
+				score1 = pointsWin
+				s1.Wins++
+				s2.Losses++
+			case ResultLoss:
+				s1.Losses++
+				s2.Wins++
+			case ResultDraw:
+				score1 = pointsDraw
+				s1.Draws++
+				s2.Draws++
+			default:
+				continue
+			}
+			s1.Points += score1
+			s2.Points += pointsWin - score1
+			games[p.Player1] = append(games[p.Player1], outcome{p.Player2, score1})
+			games[p.Player2] = append(games[p.Player2], outcome{p.Player1, pointsWin - score1})
+
+			if t.Format == FormatKnockout && p.Result != ResultPending {
+				loser := p.Player2
+				if advancer(t, p) == p.Player2 {
+					loser = p.Player1
+				}
+				byName[loser].Eliminated = p.Round
+			}
+		}
+	}
+
+	for _, s := range table {
+		for _, g := range games[s.Username] {
+			opponentPoints := byName[g.opponent].Points
+			s.Buchholz += opponentPoints
+			s.SonnebornBerger += g.score * opponentPoints
+		}
+	}
+
+	sort.SliceStable(table, func(i, j int) bool {
+		a, b := table[i], table[j]
+		if t.Format == FormatKnockout && reached(a) != reached(b) {
+			return reached(a) > reached(b)
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.Buchholz != b.Buchholz {
+			return a.Buchholz > b.Buchholz
+		}
+		if a.SonnebornBerger != b.SonnebornBerger {
+			return a.SonnebornBerger > b.SonnebornBerger
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.Seed < b.Seed
+	})
+
+	standings := make([]Standing, len(table))
+	for i, s := range table {
+		s.Rank = i + 1
+		standings[i] = *s
+	}
+	return standings
+}
+
+// reached orders knockout players by how far they got; players still in the
+// bracket sort above everyone who was knocked out
+func reached(s *Standing) int {
+	if s.Eliminated == 0 {
+		return 1 << 30
+	}
+	return s.Eliminated
+}
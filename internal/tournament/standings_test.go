@@ -0,0 +1,148 @@
+package tournament
+
+import (
+	"reflect"
+	"testing"
+)
+
+func played(round int, player1, player2 string, result Result) *Pairing {
+	return &Pairing{Round: round, Player1: player1, Player2: player2, Result: result}
+}
+
+func TestStandingsOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		players []string // in seed order
+		rounds  [][]*Pairing
+		want    []string
+	}{
+		{
+			name:    "seed before any game",
+			format:  FormatRoundRobin,
+			players: []string{"a", "b", "c"},
+			want:    []string{"a", "b", "c"},
+		},
+		{
+			name:    "pending games do not count",
+			format:  FormatSwiss,
+			players: []string{"a", "b"},
+			rounds:  [][]*Pairing{{played(1, "a", "b", ResultPending)}},
+			want:    []string{"a", "b"},
+		},
+		{
+			name:    "points",
+			format:  FormatRoundRobin,
+			players: []string{"a", "b", "c", "d"},
+			rounds: [][]*Pairing{
+				{played(1, "a", "d", ResultLoss), played(1, "b", "c", ResultDraw)},
+			},
+			want: []string{"d", "b", "c", "a"},
+		},
+		{
+			// a and c have 1.5 points; a's opponents scored more
+			name:    "Buchholz breaks a tie on points",
+			format:  FormatSwiss,
+			players: []string{"c", "a", "b", "d"},
+			rounds: [][]*Pairing{
+				{played(1, "a", "b", ResultWin), played(1, "c", "d", ResultWin)},
+				{played(2, "a", "c", ResultDraw), played(2, "b", "d", ResultWin)},
+			},
+			want: []string{"a", "c", "b", "d"},
+		},
+		{
+			// x and y tie on points and Buchholz; x beat y. p and q tie on
+			// points, Buchholz and Sonneborn-Berger; p won a game, q drew two.
+			name:    "Sonneborn-Berger, then wins, break ties before seed",
+			format:  FormatRoundRobin,
+			players: []string{"q", "p", "y", "x"},
+			rounds: [][]*Pairing{
+				{played(1, "p", "x", ResultWin), played(1, "q", "y", ResultDraw)},
+				{played(2, "p", "y", ResultLoss), played(2, "q", "x", ResultDraw)},
+				{played(3, "x", "y", ResultWin)},
+			},
+			want: []string{"x", "y", "p", "q"},
+		},
+		{
+			// A bye is worth a win in points but not in the tie-breaks
+			name:    "bye",
+			format:  FormatSwiss,
+			players: []string{"a", "b", "c"},
+			rounds: [][]*Pairing{
+				{played(1, "b", "c", ResultWin), played(1, "a", "", ResultBye)},
+			},
+			want: []string{"b", "a", "c"},
+		},
+		{
+			// The champion, the finalist, then the semi-finalists by points
+			// and Buchholz: b lost to the champion, d to the finalist
+			name:    "knockout by round reached",
+			format:  FormatKnockout,
+			players: []string{"a", "b", "c", "d"},
+			rounds: [][]*Pairing{
+				{played(1, "a", "d", ResultWin), played(1, "b", "c", ResultLoss)},
+				{played(2, "a", "c", ResultLoss)},
+			},
+			want: []string{"c", "a", "b", "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		tour := &Tournament{Format: tt.format, Players: tt.players, Rounds: tt.rounds}
+		table := computeStandings(tour)
+		got := make([]string, len(table))
+		for i, s := range table {
+			got[i] = s.Username
+			if s.Rank != i+1 {
+				t.Errorf("%s: %s is ranked %d on line %d", tt.name, s.Username, s.Rank, i+1)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: order %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStandingsTally(t *testing.T) {
+	tour := &Tournament{
+		Format:  FormatSwiss,
+		Players: []string{"a", "b", "c", "d"},
+		Rounds: [][]*Pairing{
+			{played(1, "a", "b", ResultWin), played(1, "c", "d", ResultWin)},
+			{played(2, "a", "c", ResultDraw), played(2, "b", "d", ResultWin)},
+		},
+	}
+	byName := make(map[string]Standing)
+	for _, s := range computeStandings(tour) {
+		byName[s.Username] = s
+	}
+
+	want := map[string]Standing{
+		"a": {Rank: 1, Username: "a", Seed: 1, Points: 1.5, Wins: 1, Draws: 1, Buchholz: 2.5, SonnebornBerger: 1.75},
+		"c": {Rank: 2, Username: "c", Seed: 3, Points: 1.5, Wins: 1, Draws: 1, Buchholz: 1.5, SonnebornBerger: 0.75},
+		"b": {Rank: 3, Username: "b", Seed: 2, Points: 1, Wins: 1, Losses: 1, Buchholz: 1.5},
+		"d": {Rank: 4, Username: "d", Seed: 4, Losses: 2, Buchholz: 2.5},
+	}
+	for name, w := range want {
+		if got := byName[name]; got != w {
+			t.Errorf("%s: %+v, want %+v", name, got, w)
+		}
+	}
+
+	knockout := &Tournament{
+		Format:  FormatKnockout,
+		Players: []string{"a", "b", "c", "d"},
+		Rounds: [][]*Pairing{
+			{played(1, "a", "d", ResultWin), played(1, "b", "c", ResultDraw)},
+			{played(2, "a", "b", ResultPending)},
+		},
+	}
+	eliminated := make(map[string]int)
+	for _, s := range computeStandings(knockout) {
+		eliminated[s.Username] = s.Eliminated
+	}
+	// The drawn game sends the better seed through
+	if want := map[string]int{"a": 0, "b": 0, "c": 1, "d": 1}; !reflect.DeepEqual(eliminated, want) {
+		t.Errorf("eliminated in rounds %v, want %v", eliminated, want)
+	}
+}
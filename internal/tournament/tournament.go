@@ -0,0 +1,472 @@
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/connect4/backend/internal/game"
+	"github.com/google/uuid"
+)
+
+// Format is the pairing system of a tournament
+type Format string
+
+const (
+	FormatRoundRobin Format = "round_robin"
+	FormatSwiss      Format = "swiss"
+	FormatKnockout   Format = "knockout"
+)
+
+// Status is the stage a tournament is in
+type Status string
+
+const (
+	StatusRegistration Status = "registration"
+	StatusRunning      Status = "running"
+	StatusFinished     Status = "finished"
+)
+
+// Result of a single pairing, from the point of view of Player1
+type Result string
+
+const (
+	ResultPending Result = ""
+	ResultWin     Result = "1-0"
+	ResultLoss    Result = "0-1"
+	ResultDraw    Result = "1/2-1/2"
+	ResultBye     Result = "bye"
+)
+
+// Errors returned by the Manager
+var (
+	ErrNotFound          = errors.New("tournament not found")
+	ErrNotRegistering    = errors.New("tournament is not open for registration")
+	ErrAlreadyRegistered = errors.New("player is already registered")
+	ErrTooFewPlayers     = errors.New("at least two players are needed")
+)
+
+// UnavailableError is returned by a GameCreator when players cannot be
+// seated because they are offline or busy. They forfeit the game.
+type UnavailableError struct {
+	Usernames []string
+}
+
+func (e *UnavailableError) Error() string {
+	return "players unavailable: " + strings.Join(e.Usernames, ", ")
+}
+
+// GameCreator starts tournament games. The hub implements it.
+type GameCreator interface {
+	StartTournamentGame(player1, player2 string, settings game.Settings) (gameID string, err error)
+}
+
+// Notifier tells players about their next round. The hub implements it.
+type Notifier interface {
+	NotifyRoundStart(username string, notice RoundNotice)
+}
+
+// RoundListener is told when every game of a round has a result. It is
+// called with the manager locked and must not call back into it. The hub
+// implements it.
+type RoundListener interface {
+	RoundFinished(summary RoundSummary)
+}
+
+// RoundSummary describes a round that has just been decided
+type RoundSummary struct {
+	TournamentID string    `json:"tournamentId"`
+	Name         string    `json:"name"`
+	Round        int       `json:"round"`
+	TotalRounds  int       `json:"totalRounds"`
+	Pairings     []Pairing `json:"pairings"`
+	Final        bool      `json:"final"` // the tournament is over
+}
+
+// RoundNotice is sent to a player when one of their rounds starts
+type RoundNotice struct {
+	TournamentID string `json:"tournamentId"`
+	Name         string `json:"name"`
+	Round        int    `json:"round"`
+	Opponent     string `json:"opponent,omitempty"`
+	GameID       string `json:"gameId,omitempty"`
+	MovesFirst   bool   `json:"movesFirst"`
+	Bye          bool   `json:"bye,omitempty"`
+	Forfeit      bool   `json:"forfeit,omitempty"`
+}
+
+// Pairing is one game of a round. Player2 is empty for a bye.
+type Pairing struct {
+	Round   int    `json:"round"`
+	Board   int    `json:"board"`
+	Player1 string `json:"player1"`
+	Player2 string `json:"player2,omitempty"`
+	GameID  string `json:"gameId,omitempty"`
+	Result  Result `json:"result"`
+	Forfeit bool   `json:"forfeit,omitempty"`
+}
+
+// Tournament is a community event played over several rounds
+type Tournament struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Format       Format        `json:"format"`
+	Settings     game.Settings `json:"settings"`
+	TotalRounds  int           `json:"totalRounds"`
+	Players      []string      `json:"players"` // in seed order
+	Status       Status        `json:"status"`
+	CurrentRound int           `json:"currentRound"`
+	Rounds       [][]*Pairing  `json:"rounds"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	StartedAt    *time.Time    `json:"startedAt,omitempty"`
+	FinishedAt   *time.Time    `json:"finishedAt,omitempty"`
+
+	schedule [][]*Pairing // round-robin rounds, built when the tournament starts
+}
+
+// Summary is the short form of a tournament used in listings
+type Summary struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Format       Format    `json:"format"`
+	Status       Status    `json:"status"`
+	Players      int       `json:"players"`
+	CurrentRound int       `json:"currentRound"`
+	TotalRounds  int       `json:"totalRounds"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Manager holds all tournaments of this server
+type Manager struct {
+	mu          sync.Mutex
+	tournaments map[string]*Tournament
+	games       map[string]*Pairing // running games by game ID
+	gameOwners  map[string]*Tournament
+	creator     GameCreator
+	notifier    Notifier
+	rounds      RoundListener
+}
+
+// NewManager creates a tournament manager that starts games through creator
+// and notifies players through notifier
+func NewManager(creator GameCreator, notifier Notifier) *Manager {
+	return &Manager{
+		tournaments: make(map[string]*Tournament),
+		games:       make(map[string]*Pairing),
+		gameOwners:  make(map[string]*Tournament),
+		creator:     creator,
+		notifier:    notifier,
+	}
+}
+
+// SetRoundListener registers l to hear about finished rounds (optional)
+func (m *Manager) SetRoundListener(l RoundListener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rounds = l
+}
+
+// Create opens a new tournament for registration. rounds is only used by
+// Swiss tournaments; zero picks a number that fits the field.
+func (m *Manager) Create(name string, format Format, settings game.Settings, rounds int) (*View, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	switch format {
+	case FormatRoundRobin, FormatSwiss, FormatKnockout:
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	if rounds < 0 {
+		return nil, fmt.Errorf("rounds must not be negative")
+	}
+
+	t := &Tournament{
+		ID:          uuid.New().String(),
+		Name:        name,
+		Format:      format,
+		Settings:    settings,
+		TotalRounds: rounds,
+		Players:     []string{},
+		Status:      StatusRegistration,
+		Rounds:      [][]*Pairing{},
+		CreatedAt:   time.Now(),
+	}
+
+	m.mu.Lock()
+	m.tournaments[t.ID] = t
+	m.mu.Unlock()
+	log.Printf("[TOURNAMENT] Created %s tournament %q (%s)", format, name, t.ID)
+	return m.Get(t.ID)
+}
+
+// Register adds a player to a tournament that has not started yet
+func (m *Manager) Register(id, username string) error {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.Status != StatusRegistration {
+		return ErrNotRegistering
+	}
+	for _, p := range t.Players {
+		if p == username {
+			return ErrAlreadyRegistered
+		}
+	}
+	t.Players = append(t.Players, username)
+	log.Printf("[TOURNAMENT] %s registered for %s", username, t.ID)
+	return nil
+}
+
+// Start closes registration and starts the first round
+func (m *Manager) Start(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if t.Status != StatusRegistration {
+		return ErrNotRegistering
+	}
+	if len(t.Players) < 2 {
+		return ErrTooFewPlayers
+	}
+
+	switch t.Format {
+	case FormatRoundRobin:
+		t.schedule = roundRobinSchedule(t.Players)
+		t.TotalRounds = len(t.schedule)
+	case FormatSwiss:
+		if t.TotalRounds == 0 {
+			t.TotalRounds = defaultSwissRounds(len(t.Players))
+		}
+	case FormatKnockout:
+		t.TotalRounds = knockoutRounds(len(t.Players))
+	}
+
+	now := time.Now()
+	t.StartedAt = &now
+	t.Status = StatusRunning
+	log.Printf("[TOURNAMENT] Starting %s with %d players over %d rounds", t.ID, len(t.Players), t.TotalRounds)
+	m.startRoundLocked(t)
+	return nil
+}
+
+// View is a copy of a tournament together with its current standings
+type View struct {
+	Tournament
+	Standings []Standing `json:"standings"`
+}
+
+// Get returns a copy of a tournament and its standings
+func (m *Manager) Get(id string) (*View, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tournaments[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	v := &View{Tournament: *t, Standings: computeStandings(t)}
+	v.Players = append([]string{}, t.Players...)
+	v.Rounds = make([][]*Pairing, len(t.Rounds))
+	for i, round := range t.Rounds {
+		v.Rounds[i] = make([]*Pairing, len(round))
+		for j, p := range round {
+			copied := *p
+			v.Rounds[i][j] = &copied
+		}
+	}
+	v.schedule = nil
+	return v, nil
+}
+
+// List returns a summary of every tournament, newest first
+func (m *Manager) List() []Summary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]Summary, 0, len(m.tournaments))
+	for _, t := range m.tournaments {
+		list = append(list, Summary{
+			ID:           t.ID,
+			Name:         t.Name,
+			Format:       t.Format,
+			Status:       t.Status,
+			Players:      len(t.Players),
+			CurrentRound: t.CurrentRound,
+			TotalRounds:  t.TotalRounds,
+			CreatedAt:    t.CreatedAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+// GameFinished records the result of a tournament game. Games that do not
+// belong to a tournament are ignored.
+func (m *Manager) GameFinished(gameID, winner string, isDraw bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.games[gameID]
+	if !ok {
+		return
+	}
+	t := m.gameOwners[gameID]
+	delete(m.games, gameID)
+	delete(m.gameOwners, gameID)
+
+	switch {
+	case isDraw:
+		p.Result = ResultDraw
+	case winner == p.Player1:
+		p.Result = ResultWin
+	case winner == p.Player2:
+		p.Result = ResultLoss
+	default:
+		// Neither player won (e.g. a bot finished the game); count it as drawn
+		p.Result = ResultDraw
+	}
+	log.Printf("[TOURNAMENT] %s round %d: %s %s %s", t.ID, p.Round, p.Player1, p.Result, p.Player2)
+	m.advanceLocked(t)
+}
+
+// startRoundLocked pairs the next round and starts its games.
+// Must be called with m.mu held.
+func (m *Manager) startRoundLocked(t *Tournament) {
+	t.CurrentRound++
+	round := t.CurrentRound
+
+	var pairings []*Pairing
+	switch t.Format {
+	case FormatRoundRobin:
+		pairings = t.schedule[round-1]
+	case FormatSwiss:
+		pairings = pairSwiss(t)
+	case FormatKnockout:
+		pairings = pairKnockout(t)
+	}
+	for i, p := range pairings {
+		p.Round = round
+		p.Board = i + 1
+	}
+	t.Rounds = append(t.Rounds, pairings)
+
+	for _, p := range pairings {
+		if p.Player2 == "" {
+			p.Result = ResultBye
+			m.notify(p.Player1, RoundNotice{TournamentID: t.ID, Name: t.Name, Round: round, Bye: true})
+			continue
+		}
+
+		gameID, err := m.creator.StartTournamentGame(p.Player1, p.Player2, t.Settings)
+		if err != nil {
+			m.forfeitLocked(t, p, err)
+			continue
+		}
+		p.GameID = gameID
+		m.games[gameID] = p
+		m.gameOwners[gameID] = t
+		m.notify(p.Player1, RoundNotice{TournamentID: t.ID, Name: t.Name, Round: round, Opponent: p.Player2, GameID: gameID, MovesFirst: true})
+		m.notify(p.Player2, RoundNotice{TournamentID: t.ID, Name: t.Name, Round: round, Opponent: p.Player1, GameID: gameID})
+	}
+	m.advanceLocked(t)
+}
+
+// forfeitLocked decides a pairing whose game could not be started. A player
+// who is present wins; when both are missing the game counts as lost for
+// Player2, so knockout brackets still have someone to advance.
+func (m *Manager) forfeitLocked(t *Tournament, p *Pairing, err error) {
+	log.Printf("[TOURNAMENT] %s round %d: could not start %s vs %s: %v", t.ID, p.Round, p.Player1, p.Player2, err)
+	p.Forfeit = true
+	p.Result = ResultWin
+
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		missing1, missing2 := false, false
+		for _, u := range unavailable.Usernames {
+			missing1 = missing1 || u == p.Player1
+			missing2 = missing2 || u == p.Player2
+		}
+		if missing1 && !missing2 {
+			p.Result = ResultLoss
+		}
+	}
+
+	notice := RoundNotice{TournamentID: t.ID, Name: t.Name, Round: p.Round, Forfeit: true}
+	notice.Opponent = p.Player2
+	m.notify(p.Player1, notice)
+	notice.Opponent = p.Player1
+	m.notify(p.Player2, notice)
+}
+
+// advanceLocked starts the next round, or finishes the tournament, once
+// every game of the current round has a result. Must be called with m.mu held.
+func (m *Manager) advanceLocked(t *Tournament) {
+	if t.Status != StatusRunning || t.CurrentRound == 0 {
+		return
+	}
+	for _, p := range t.Rounds[t.CurrentRound-1] {
+		if p.Result == ResultPending {
+			return
+		}
+	}
+
+	final := t.CurrentRound >= t.TotalRounds
+	m.roundFinished(t, final)
+	if final {
+		now := time.Now()
+		t.FinishedAt = &now
+		t.Status = StatusFinished
+		log.Printf("[TOURNAMENT] %s finished", t.ID)
+		return
+	}
+	m.startRoundLocked(t)
+}
+
+// roundFinished reports the current round of t to the round listener.
+// Must be called with m.mu held.
+func (m *Manager) roundFinished(t *Tournament, final bool) {
+	if m.rounds == nil {
+		return
+	}
+	summary := RoundSummary{
+		TournamentID: t.ID,
+		Name:         t.Name,
+		Round:        t.CurrentRound,
+		TotalRounds:  t.TotalRounds,
+		Final:        final,
+	}
+	for _, p := range t.Rounds[t.CurrentRound-1] {
+		summary.Pairings = append(summary.Pairings, *p)
+	}
+	m.rounds.RoundFinished(summary)
+}
+
+func (m *Manager) notify(username string, notice RoundNotice) {
+	if m.notifier != nil && username != "" {
+		m.notifier.NotifyRoundStart(username, notice)
+	}
+}
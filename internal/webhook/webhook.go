@@ -0,0 +1,430 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/connect4/backend/internal/database"
+	"github.com/google/uuid"
+)
+
+// Event types a webhook can subscribe to
+const (
+	EventGameStarted   = "game.started"
+	EventGameFinished  = "game.finished"
+	EventGameAbandoned = "game.abandoned"
+	EventRoundFinished = "tournament.round_finished"
+)
+
+// EventTypes lists every event type
+var EventTypes = []string{EventGameStarted, EventGameFinished, EventGameAbandoned, EventRoundFinished}
+
+// Headers sent with every delivery. The signature is "sha256=" followed by
+// the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's
+// secret; receivers should also reject stale timestamps.
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderID        = "X-Webhook-Id"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	maxAttempts      = 6                // attempts per event before it is given up
+	firstRetryDelay  = 2 * time.Second  // doubled after every failed attempt
+	maxRetryDelay    = 5 * time.Minute  // cap on the delay between attempts
+	deliveryTimeout  = 10 * time.Second // per attempt, including reading the response
+	queueSize        = 256              // deliveries waiting for a worker
+	workers          = 4                // deliveries in flight at once
+	recentDeliveries = 100              // attempts kept in memory per webhook
+	secretPrefix     = "whsec_"
+)
+
+// Errors returned by the Dispatcher
+var (
+	ErrNotFound     = errors.New("webhook not found")
+	ErrInvalidURL   = errors.New("webhook URL must be an absolute http or https URL")
+	ErrUnknownEvent = errors.New("unknown event type")
+)
+
+// Event is the JSON body POSTed to webhooks
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// delivery is one event on its way to one webhook
+type delivery struct {
+	hook    database.Webhook
+	event   Event
+	body    []byte
+	attempt int
+}
+
+// Dispatcher delivers events to the registered webhooks. Webhooks are kept
+// in memory and written through to the database when one is set. Delivery
+// happens in the background and never blocks the caller of Emit.
+type Dispatcher struct {
+	mu     sync.Mutex
+	db     *database.DB
+	hooks  map[int]*database.Webhook
+	recent map[int][]database.WebhookDelivery // newest last
+	nextID int                                // IDs handed out without a database
+
+	queue      chan *delivery
+	stop       chan struct{}
+	stopOnce   sync.Once
+	client     *http.Client
+	retryDelay time.Duration // before the second attempt, doubled after that
+}
+
+// NewDispatcher creates a dispatcher and starts its delivery workers
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		hooks:  make(map[int]*database.Webhook),
+		recent: make(map[int][]database.WebhookDelivery),
+		queue:  make(chan *delivery, queueSize),
+		stop:   make(chan struct{}),
+		client: &http.Client{Timeout: deliveryTimeout},
+
+		retryDelay: firstRetryDelay,
+	}
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// SetDB stores webhooks and their delivery log in db (optional)
+func (d *Dispatcher) SetDB(db *database.DB) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.db = db
+}
+
+// Load reads the registered webhooks from the database
+func (d *Dispatcher) Load(ctx context.Context) error {
+	d.mu.Lock()
+	db := d.db
+	d.mu.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	hooks, err := db.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i := range hooks {
+		d.hooks[hooks[i].ID] = &hooks[i]
+	}
+	log.Printf("[WEBHOOK] Loaded %d webhooks", len(hooks))
+	return nil
+}
+
+// Close stops the workers. Deliveries still queued or waiting for a retry
+// are dropped. Closing twice is harmless.
+func (d *Dispatcher) Close() error {
+	d.stopOnce.Do(func() { close(d.stop) })
+	return nil
+}
+
+// Register adds a webhook for rawURL receiving events, or every event when
+// events is empty. The returned webhook carries the signing secret; it is
+// not shown again.
+func (d *Dispatcher) Register(ctx context.Context, rawURL string, events []string) (*database.Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	for _, e := range events {
+		if !knownEvent(e) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownEvent, e)
+		}
+	}
+
+	hook := &database.Webhook{
+		URL:       u.String(),
+		Secret:    newSecret(),
+		Events:    append([]string(nil), events...),
+		CreatedAt: time.Now(),
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.db != nil {
+		if err := d.db.CreateWebhook(ctx, hook); err != nil {
+			return nil, err
+		}
+	} else {
+		d.nextID++
+		hook.ID = d.nextID
+	}
+	d.hooks[hook.ID] = hook
+	log.Printf("[WEBHOOK] Registered webhook %d for %s", hook.ID, hook.URL)
+
+	registered := *hook
+	return &registered, nil
+}
+
+// Delete removes a webhook. Its pending retries are dropped.
+func (d *Dispatcher) Delete(ctx context.Context, id int) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.hooks[id]; !ok {
+		return ErrNotFound
+	}
+	if d.db != nil {
+		if err := d.db.DeleteWebhook(ctx, id); err != nil {
+			return err
+		}
+	}
+	delete(d.hooks, id)
+	delete(d.recent, id)
+	log.Printf("[WEBHOOK] Deleted webhook %d", id)
+	return nil
+}
+
+// List returns the registered webhooks without their secrets
+func (d *Dispatcher) List() []database.Webhook {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	hooks := make([]database.Webhook, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		hook := *h
+		hook.Secret = ""
+		if hook.Events == nil {
+			hook.Events = []string{}
+		}
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks
+}
+
+// Deliveries returns the latest delivery attempts for a webhook, newest
+// first
+func (d *Dispatcher) Deliveries(ctx context.Context, id, limit int) ([]database.WebhookDelivery, error) {
+	d.mu.Lock()
+	_, ok := d.hooks[id]
+	db := d.db
+	d.mu.Unlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// The database also has the attempts made before a restart
+	if db != nil {
+		return db.ListWebhookDeliveries(ctx, id, limit)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	recent := d.recent[id]
+	deliveries := []database.WebhookDelivery{}
+	for i := len(recent) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, recent[i])
+	}
+	return deliveries, nil
+}
+
+// Emit sends an event to every webhook subscribed to eventType
+func (d *Dispatcher) Emit(eventType string, data interface{}) {
+	event := Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[WEBHOOK] Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	d.mu.Lock()
+	var targets []database.Webhook
+	for _, h := range d.hooks {
+		if subscribed(h, eventType) {
+			targets = append(targets, *h)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, hook := range targets {
+		d.enqueue(&delivery{hook: hook, event: event, body: body, attempt: 1})
+	}
+}
+
+// enqueue hands a delivery to the workers. A full queue drops it rather
+// than holding up the game that emitted it.
+func (d *Dispatcher) enqueue(job *delivery) {
+	select {
+	case d.queue <- job:
+	case <-d.stop:
+	default:
+		log.Printf("[WEBHOOK] Queue full, dropping %s event %s for webhook %d", job.event.Type, job.event.ID, job.hook.ID)
+	}
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case job := <-d.queue:
+			d.deliver(job)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// deliver makes one attempt, logs it and schedules the next one on failure
+func (d *Dispatcher) deliver(job *delivery) {
+	d.mu.Lock()
+	_, live := d.hooks[job.hook.ID]
+	d.mu.Unlock()
+	if !live {
+		return
+	}
+
+	started := time.Now()
+	status, err := d.post(job)
+	record := database.WebhookDelivery{
+		WebhookID:   job.hook.ID,
+		EventID:     job.event.ID,
+		EventType:   job.event.Type,
+		Attempt:     job.attempt,
+		StatusCode:  status,
+		Succeeded:   err == nil,
+		DurationMs:  int(time.Since(started) / time.Millisecond),
+		AttemptedAt: started.UTC(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	d.logDelivery(record)
+
+	if err == nil {
+		return
+	}
+	if job.attempt >= maxAttempts {
+		log.Printf("[WEBHOOK] Giving up on %s event %s for webhook %d after %d attempts: %v", job.event.Type, job.event.ID, job.hook.ID, job.attempt, err)
+		return
+	}
+	delay := d.retryDelay << (job.attempt - 1)
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	log.Printf("[WEBHOOK] Delivery of %s event %s to webhook %d failed (attempt %d), retrying in %v: %v", job.event.Type, job.event.ID, job.hook.ID, job.attempt, delay, err)
+	next := *job
+	next.attempt++
+	time.AfterFunc(delay, func() { d.enqueue(&next) })
+}
+
+// post sends a delivery and returns the response status. Anything but a
+// 2xx response is an error.
+func (d *Dispatcher) post(job *delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, job.hook.URL, bytes.NewReader(job.body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, job.event.Type)
+	req.Header.Set(HeaderID, job.event.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(job.hook.Secret, timestamp, job.body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// logDelivery keeps an attempt in memory and in the database
+func (d *Dispatcher) logDelivery(record database.WebhookDelivery) {
+	d.mu.Lock()
+	db := d.db
+	if _, live := d.hooks[record.WebhookID]; live {
+		recent := append(d.recent[record.WebhookID], record)
+		if len(recent) > recentDeliveries {
+			recent = recent[len(recent)-recentDeliveries:]
+		}
+		d.recent[record.WebhookID] = recent
+	}
+	d.mu.Unlock()
+
+	if db != nil {
+		if err := db.SaveWebhookDelivery(context.Background(), &record); err != nil {
+			log.Printf("[WEBHOOK] Failed to log delivery: %v", err)
+		}
+	}
+}
+
+// Sign returns the signature header value for body sent at timestamp
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is valid for body sent at timestamp.
+// Receivers written in Go can use it.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func subscribed(h *database.Webhook, eventType string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func knownEvent(eventType string) bool {
+	for _, e := range EventTypes {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newSecret() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[WEBHOOK] crypto/rand failed, falling back to uuid: %v", err)
+		return secretPrefix + uuid.New().String()
+	}
+	return secretPrefix + hex.EncodeToString(b)
+}
@@ -0,0 +1,263 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a webhook endpoint that records every request and answers
+// with the statuses it is given, the last one repeating
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+	srv      *httptest.Server
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		status := r.statuses[0]
+		if len(r.statuses) > 1 {
+			r.statuses = r.statuses[1:]
+		}
+		r.requests = append(r.requests, receivedRequest{req.Header.Clone(), body})
+		r.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// newTestDispatcher creates a dispatcher that retries after a millisecond
+func newTestDispatcher(t *testing.T) *Dispatcher {
+	d := NewDispatcher()
+	d.retryDelay = time.Millisecond
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func register(t *testing.T, d *Dispatcher, url string, events ...string) int {
+	hook, err := d.Register(context.Background(), url, events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.Secret == "" {
+		t.Fatal("registered webhook has no secret")
+	}
+	return hook.ID
+}
+
+func TestSignature(t *testing.T) {
+	d := newTestDispatcher(t)
+	r := newReceiver(t, http.StatusOK)
+	hook, err := d.Register(context.Background(), r.srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.Emit(EventGameStarted, map[string]string{"gameId": "g1"})
+	waitFor(t, "the delivery", func() bool { return len(r.received()) == 1 })
+
+	req := r.received()[0]
+	timestamp, err := strconv.ParseInt(req.header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		t.Fatalf("timestamp header %q: %v", req.header.Get(HeaderTimestamp), err)
+	}
+	signature := req.header.Get(HeaderSignature)
+	if want := Sign(hook.Secret, timestamp, req.body); signature != want {
+		t.Errorf("signature %s, want %s", signature, want)
+	}
+	if !Verify(hook.Secret, timestamp, req.body, signature) {
+		t.Error("the delivered signature does not verify")
+	}
+	if Verify(hook.Secret, timestamp+1, req.body, signature) {
+		t.Error("the signature verifies with another timestamp")
+	}
+	if Verify(hook.Secret, timestamp, append(req.body, ' '), signature) {
+		t.Error("the signature verifies with another body")
+	}
+	if Verify("whsec_other", timestamp, req.body, signature) {
+		t.Error("the signature verifies with another secret")
+	}
+
+	var event Event
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != EventGameStarted || req.header.Get(HeaderEvent) != EventGameStarted || req.header.Get(HeaderID) != event.ID {
+		t.Errorf("event %+v delivered with headers %v", event, req.header)
+	}
+}
+
+func TestRetryAndDeliveryLog(t *testing.T) {
+	d := newTestDispatcher(t)
+	r := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusNoContent)
+	id := register(t, d, r.srv.URL)
+
+	d.Emit(EventGameFinished, nil)
+	waitFor(t, "three attempts", func() bool {
+		deliveries, err := d.Deliveries(context.Background(), id, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(deliveries) == 3
+	})
+
+	deliveries, _ := d.Deliveries(context.Background(), id, 10)
+	// Newest first
+	for i, want := range []struct {
+		attempt, status int
+		ok              bool
+	}{{3, http.StatusNoContent, true}, {2, http.StatusBadGateway, false}, {1, http.StatusInternalServerError, false}} {
+		del := deliveries[i]
+		if del.Attempt != want.attempt || del.StatusCode != want.status || del.Succeeded != want.ok {
+			t.Errorf("delivery %d: attempt %d status %d succeeded %v, want %d %d %v",
+				i, del.Attempt, del.StatusCode, del.Succeeded, want.attempt, want.status, want.ok)
+		}
+		if del.EventID != deliveries[0].EventID || del.EventType != EventGameFinished {
+			t.Errorf("delivery %d is for %s event %s", i, del.EventType, del.EventID)
+		}
+		if !del.Succeeded && del.Error == "" {
+			t.Errorf("delivery %d failed without an error", i)
+		}
+	}
+	if got := len(r.received()); got != 3 {
+		t.Errorf("%d requests, want 3", got)
+	}
+
+	limited, _ := d.Deliveries(context.Background(), id, 1)
+	if len(limited) != 1 || limited[0].Attempt != 3 {
+		t.Errorf("limited to 1: %+v, want the last attempt", limited)
+	}
+	if _, err := d.Deliveries(context.Background(), id+1, 10); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deliveries of an unknown webhook: %v", err)
+	}
+}
+
+func TestGiveUpAfterMaxAttempts(t *testing.T) {
+	d := newTestDispatcher(t)
+	r := newReceiver(t, http.StatusServiceUnavailable)
+	id := register(t, d, r.srv.URL)
+
+	d.Emit(EventGameAbandoned, nil)
+	waitFor(t, "every attempt", func() bool { return len(r.received()) >= maxAttempts })
+	// The next attempt would come after 2^maxAttempts milliseconds
+	time.Sleep(4 * (time.Millisecond << maxAttempts))
+
+	if got := len(r.received()); got != maxAttempts {
+		t.Errorf("%d attempts, want %d", got, maxAttempts)
+	}
+	deliveries, _ := d.Deliveries(context.Background(), id, 100)
+	if len(deliveries) != maxAttempts || deliveries[0].Attempt != maxAttempts || deliveries[0].Succeeded {
+		t.Errorf("delivery log %+v, want %d failed attempts", deliveries, maxAttempts)
+	}
+}
+
+func TestDeleteDropsRetries(t *testing.T) {
+	d := newTestDispatcher(t)
+	d.retryDelay = 50 * time.Millisecond
+	r := newReceiver(t, http.StatusInternalServerError)
+	id := register(t, d, r.srv.URL)
+
+	d.Emit(EventGameStarted, nil)
+	waitFor(t, "the first attempt", func() bool { return len(r.received()) == 1 })
+	if err := d.Delete(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(4 * d.retryDelay)
+
+	if got := len(r.received()); got != 1 {
+		t.Errorf("%d attempts after the webhook was deleted, want 1", got)
+	}
+	if err := d.Delete(context.Background(), id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleting twice: %v, want ErrNotFound", err)
+	}
+	if len(d.List()) != 0 {
+		t.Errorf("webhooks left: %+v", d.List())
+	}
+}
+
+func TestEventSubscriptions(t *testing.T) {
+	d := newTestDispatcher(t)
+	all := newReceiver(t, http.StatusOK)
+	finished := newReceiver(t, http.StatusOK)
+	register(t, d, all.srv.URL)
+	register(t, d, finished.srv.URL, EventGameFinished, EventRoundFinished)
+
+	for _, e := range []string{EventGameStarted, EventGameFinished, EventRoundFinished, EventGameAbandoned} {
+		d.Emit(e, nil)
+	}
+	waitFor(t, "the deliveries", func() bool { return len(all.received()) == 4 && len(finished.received()) == 2 })
+	time.Sleep(20 * time.Millisecond)
+
+	types := func(r *receiver) map[string]bool {
+		seen := make(map[string]bool)
+		for _, req := range r.received() {
+			seen[req.header.Get(HeaderEvent)] = true
+		}
+		return seen
+	}
+	if got := types(finished); len(got) != 2 || !got[EventGameFinished] || !got[EventRoundFinished] {
+		t.Errorf("subscribed webhook received %v", got)
+	}
+	if got := types(all); len(got) != 4 {
+		t.Errorf("webhook without a filter received %v", got)
+	}
+}
+
+func TestRegisterValidation(t *testing.T) {
+	d := newTestDispatcher(t)
+	for _, u := range []string{"", "example.com/hook", "ftp://example.com/hook", "http://", "://bad"} {
+		if _, err := d.Register(context.Background(), u, nil); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("Register(%q): %v, want ErrInvalidURL", u, err)
+		}
+	}
+	if _, err := d.Register(context.Background(), "https://example.com/hook", []string{"game.moved"}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("unknown event: %v, want ErrUnknownEvent", err)
+	}
+
+	id := register(t, d, "https://example.com/hook", EventGameStarted)
+	hooks := d.List()
+	if len(hooks) != 1 || hooks[0].ID != id || hooks[0].Secret != "" {
+		t.Errorf("List() = %+v, want one webhook without its secret", hooks)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	d := NewDispatcher()
+	d.Close()
+	d.Close()
+}
@@ -7,24 +7,41 @@ import (
 
 type ActiveUser struct {
 	Username string `json:"username"`
-	Status   string `json:"status"` // "waiting", "in_game"
+	Status   string `json:"status"` // one of the Presence states
 }
 
 // GetActiveUsers returns a list of all active users
 func (h *Hub) GetActiveUsers() []ActiveUser {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	return h.activeUsersUnsafe()
+}
 
+// activeUsersUnsafe lists every online player. Must be called with h.mu held.
+func (h *Hub) activeUsersUnsafe() []ActiveUser {
 	activeUsers := make([]ActiveUser, 0)
+	seen := make(map[string]bool)
 	for client := range h.clients {
-		if client.username != "" && !client.isBot { // Only include non-bot users with usernames
-			status := "waiting"
-			if client.gameID != "" {
-				status = "in_game"
+		// Only include non-bot users with usernames whose socket is here
+		if client.username == "" || client.isBot || client.remoteInstance != "" || seen[client.username] {
+			continue
+		}
+		seen[client.username] = true
+		activeUsers = append(activeUsers, ActiveUser{
+			Username: client.username,
+			Status:   h.presenceOfUnsafe(client),
+		})
+	}
+
+	// Players connected to other instances are known through the backplane
+	if h.backplane != nil {
+		for _, p := range h.backplane.ListPresence() {
+			if p.InstanceID == h.instanceID {
+				continue
 			}
 			activeUsers = append(activeUsers, ActiveUser{
-				Username: client.username,
-				Status:   status,
+				Username: p.Username,
+				Status:   p.Status,
 			})
 		}
 	}
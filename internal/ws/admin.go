@@ -0,0 +1,339 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/webhook"
+)
+
+// AdminClient describes a registered client for operators
+type AdminClient struct {
+	Username        string     `json:"username"`
+	Status          string     `json:"status"`
+	GameID          string     `json:"gameId,omitempty"`
+	Spectating      string     `json:"spectating,omitempty"`
+	RemoteInstance  string     `json:"remoteInstance,omitempty"`
+	Transport       string     `json:"transport,omitempty"`
+	BotAccount      bool       `json:"botAccount,omitempty"`
+	ProtocolVersion int        `json:"protocolVersion,omitempty"`
+	DisconnectedAt  *time.Time `json:"disconnectedAt,omitempty"`
+}
+
+// AdminGame summarizes a game in activeGames for operators
+type AdminGame struct {
+	ID          string          `json:"id"`
+	Mode        string          `json:"mode"`
+	Player1     string          `json:"player1"`
+	Player2     string          `json:"player2"`
+	Status      game.GameStatus `json:"status"`
+	Moves       int             `json:"moves"`
+	Substituted [2]bool         `json:"substituted"`
+	Spectators  int             `json:"spectators"`
+	Series      *SeriesScore    `json:"series,omitempty"`
+}
+
+// AdminGameDetail is a game with its full state and recent events
+type AdminGameDetail struct {
+	AdminGame
+	State    *game.GameState `json:"state"`
+	EventSeq uint64          `json:"eventSeq"`
+	Events   []Envelope      `json:"events"`
+	Seats    [2]AdminClient  `json:"seats"`
+}
+
+// AnnouncementPayload is an operator message shown to every player
+type AnnouncementPayload struct {
+	Message string `json:"message"`
+}
+
+// AdminClients lists every registered human client
+func (h *Hub) AdminClients() []AdminClient {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients := make([]AdminClient, 0, len(h.clients))
+	for client := range h.clients {
+		if client.isBot || client.username == "" {
+			continue
+		}
+		clients = append(clients, h.adminClientUnsafe(client))
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Username < clients[j].Username })
+	return clients
+}
+
+func (h *Hub) adminClientUnsafe(client *Client) AdminClient {
+	info := AdminClient{
+		Username:        client.username,
+		GameID:          client.gameID,
+		Spectating:      client.spectating,
+		RemoteInstance:  client.remoteInstance,
+		ProtocolVersion: client.protocolVersion,
+		DisconnectedAt:  client.disconnectedAt,
+		BotAccount:      client.botAccount,
+	}
+	if client.remoteInstance == "" {
+		info.Status = h.presenceOfUnsafe(client)
+		info.Transport = TransportWebSocket
+		if client.fallback != nil {
+			info.Transport = TransportHTTP
+		} else if client.apiIdle != nil {
+			info.Transport = TransportREST
+		}
+	}
+	return info
+}
+
+// AdminGames lists the games in activeGames, running or waiting for a rematch
+func (h *Hub) AdminGames() []AdminGame {
+	h.mu.Lock()
+	registered := make([]*WSGame, 0, len(h.activeGames))
+	for _, g := range h.activeGames {
+		registered = append(registered, g)
+	}
+	h.mu.Unlock()
+
+	games := make([]AdminGame, 0, len(registered))
+	for _, g := range registered {
+		g.mu.Lock()
+		if !g.closed {
+			games = append(games, adminGame(g))
+		}
+		g.mu.Unlock()
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+	return games
+}
+
+// adminGame summarizes g. Must be called with g.mu held.
+func adminGame(g *WSGame) AdminGame {
+	moves := 0
+	for _, row := range g.game.Board.Grid {
+		for _, cell := range row {
+			if cell != 0 {
+				moves++
+			}
+		}
+	}
+	g.watchMu.Lock()
+	spectators := len(g.spectators)
+	g.watchMu.Unlock()
+	return AdminGame{
+		ID:          g.game.ID,
+		Mode:        g.mode,
+		Player1:     g.game.Player1.Username,
+		Player2:     g.game.Player2.Username,
+		Status:      g.game.GetState().Status,
+		Moves:       moves,
+		Substituted: g.substitutes,
+		Spectators:  spectators,
+		Series:      g.seriesScore(),
+	}
+}
+
+// AdminGame returns the full state of a game
+func (h *Hub) AdminGame(gameID string) (*AdminGameDetail, *ProtocolError) {
+	g := h.lockGame(gameID)
+	if g == nil {
+		return nil, newProtocolError(ErrCodeNoSuchGame, "game %s not found", gameID)
+	}
+	defer g.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	detail := &AdminGameDetail{
+		AdminGame: adminGame(g),
+		State:     g.ToGameState(),
+		EventSeq:  g.eventSeq,
+		Events:    append([]Envelope{}, g.events...),
+	}
+	for i, c := range []*Client{g.player1Client, g.player2Client} {
+		if c != nil {
+			detail.Seats[i] = h.adminClientUnsafe(c)
+		}
+	}
+	return detail, nil
+}
+
+// AdminEndGame stops a running game without a result. Tournament games need
+// a result to go on and must be adjudicated instead.
+func (h *Hub) AdminEndGame(gameID string) *ProtocolError {
+	g, perr := h.lockRunningGame(gameID)
+	if perr != nil {
+		return perr
+	}
+	defer g.mu.Unlock()
+	if g.mode == config.ModeTournament {
+		return newProtocolError(ErrCodeNotAllowed, "tournament games must be adjudicated")
+	}
+
+	log.Printf("[BACKEND-ADMIN] Aborting game %s", gameID)
+	if g.clockTimer != nil {
+		g.clockTimer.Stop()
+		g.clockTimer = nil
+	}
+	g.game.Abort()
+	h.emitGameEventLocked(g, webhook.EventGameAbandoned, 0, AbandonedAborted)
+	h.persistGameLocked(g)
+	h.broadcastGameUpdate(g)
+	return nil
+}
+
+// AdminAdjudicate ends a running game with winner winning, or drawn when
+// winner is empty. The result counts like any other.
+func (h *Hub) AdminAdjudicate(gameID, winner string) *ProtocolError {
+	g, perr := h.lockRunningGame(gameID)
+	if perr != nil {
+		return perr
+	}
+	defer g.mu.Unlock()
+	seat := 0
+	switch winner {
+	case "":
+	case g.game.Player1.Username:
+		seat = 1
+	case g.game.Player2.Username:
+		seat = 2
+	default:
+		return newProtocolError(ErrCodeNotAPlayer, "%s is not playing game %s", winner, gameID)
+	}
+
+	log.Printf("[BACKEND-ADMIN] Adjudicating game %s, winner=%q", gameID, winner)
+	g.game.Adjudicate(seat)
+	h.storeGameResult(g, seat, seat == 0)
+	h.persistGameLocked(g)
+	h.broadcastGameUpdate(g)
+	return nil
+}
+
+// lockGame locks and returns the registered game with the given ID, or nil
+func (h *Hub) lockGame(gameID string) *WSGame {
+	h.mu.Lock()
+	g := h.activeGames[gameID]
+	h.mu.Unlock()
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return nil
+	}
+	return g
+}
+
+// lockRunningGame is lockGame for a game that must still be in progress
+func (h *Hub) lockRunningGame(gameID string) (*WSGame, *ProtocolError) {
+	g := h.lockGame(gameID)
+	if g == nil {
+		return nil, newProtocolError(ErrCodeNoSuchGame, "game %s not found", gameID)
+	}
+	if !g.game.IsActive {
+		g.mu.Unlock()
+		return nil, newProtocolError(ErrCodeGameOver, "game %s is already over", gameID)
+	}
+	return g, nil
+}
+
+// AdminKick disconnects every local connection of username with reason in
+// the close frame. The session is invalidated so the seat cannot be resumed.
+// It returns the number of connections closed.
+func (h *Hub) AdminKick(username, reason string) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.kickUnsafe(username, "kicked: "+reason)
+}
+
+func (h *Hub) kickUnsafe(username, reason string) int {
+	kicked := 0
+	for client := range h.clients {
+		out := client.send.Load()
+		if client.username != username || client.isBot || client.remoteInstance != "" || out == nil {
+			continue
+		}
+		log.Printf("[BACKEND-ADMIN] Kicking %s (%s)", username, reason)
+		client.sessionToken = ""
+		out.closeWithReason(reason)
+		kicked++
+	}
+	return kicked
+}
+
+// AdminBan bars username from joining and disconnects it. The ban is
+// stored before it takes effect, so a failed write leaves nothing changed.
+func (h *Hub) AdminBan(ctx context.Context, username, reason string) error {
+	if h.db != nil {
+		if err := h.db.BanUser(ctx, username, reason); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bans[username] = reason
+	h.kickUnsafe(username, "banned: "+reason)
+	return nil
+}
+
+// AdminUnban lifts a ban
+func (h *Hub) AdminUnban(ctx context.Context, username string) error {
+	if h.db != nil {
+		if err := h.db.UnbanUser(ctx, username); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.bans, username)
+	return nil
+}
+
+// AdminBans returns the banned usernames with their reasons
+func (h *Hub) AdminBans() map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bans := make(map[string]string, len(h.bans))
+	for username, reason := range h.bans {
+		bans[username] = reason
+	}
+	return bans
+}
+
+// LoadBans reads the stored bans from the database
+func (h *Hub) LoadBans(ctx context.Context) error {
+	h.mu.Lock()
+	db := h.db
+	h.mu.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	bans, err := db.ListBans(ctx)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, b := range bans {
+		h.bans[b.Username] = b.Reason
+	}
+	log.Printf("[BACKEND-ADMIN] Loaded %d bans", len(bans))
+	return nil
+}
+
+// AdminAnnounce sends a maintenance announcement to every connected client
+// on every instance
+func (h *Hub) AdminAnnounce(message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	log.Printf("[BACKEND-ADMIN] Announcement: %s", message)
+	h.broadcastUnsafe(Envelope{Type: MsgAnnouncement, Payload: AnnouncementPayload{Message: message}})
+}
@@ -0,0 +1,396 @@
+package ws
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Admin commands, sent as the type of an admin WebSocket message
+const (
+	AdminListClients = "listClients"
+	AdminListGames   = "listGames"
+	AdminInspectGame = "inspectGame"
+	AdminEndGame     = "endGame"
+	AdminAdjudicate  = "adjudicate"
+	AdminKick        = "kick"
+	AdminBan         = "ban"
+	AdminUnban       = "unban"
+	AdminListBans    = "listBans"
+	AdminAnnounce    = "announce"
+	AdminRegisterBot = "registerBot"
+	AdminRevokeBot   = "revokeBot"
+	AdminListBots    = "listBots"
+
+	AdminRegisterWebhook   = "registerWebhook"
+	AdminDeleteWebhook     = "deleteWebhook"
+	AdminListWebhooks      = "listWebhooks"
+	AdminWebhookDeliveries = "webhookDeliveries"
+)
+
+// MsgAdminResult answers an admin command
+const MsgAdminResult = "adminResult"
+
+// maxAdminMessageSize leaves room for announcements in admin messages
+const maxAdminMessageSize = 4096
+
+// AdminCommandPayload carries the arguments of every admin command
+type AdminCommandPayload struct {
+	GameID   string `json:"gameId,omitempty"`
+	Username string `json:"username,omitempty"`
+	Winner   string `json:"winner,omitempty"` // adjudicate: empty for a draw
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+	// registerWebhook: the receiver and its events, empty for all of them
+	URL       string   `json:"url,omitempty"`
+	Events    []string `json:"events,omitempty"`
+	WebhookID int      `json:"webhookId,omitempty"`
+}
+
+// SetAdminToken enables the admin API for requests bearing token. The API
+// is disabled while no token is set.
+func (h *Hub) SetAdminToken(token string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.adminToken = token
+}
+
+// adminAuthorized checks the bearer token of r. The WebSocket endpoint also
+// accepts it as the token query parameter, since browsers cannot set headers
+// on WebSocket requests.
+func (h *Hub) adminAuthorized(r *http.Request, allowQuery bool) bool {
+	h.mu.Lock()
+	expected := h.adminToken
+	h.mu.Unlock()
+	if expected == "" {
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" && allowQuery {
+		token = r.URL.Query().Get("token")
+	}
+	return adminTokenMatches(token, expected)
+}
+
+// adminTokenMatches compares token with expected in constant time. Both are
+// hashed first so that not even the length of the expected token leaks.
+func adminTokenMatches(token, expected string) bool {
+	got, want := sha256.Sum256([]byte(token)), sha256.Sum256([]byte(expected))
+	return subtle.ConstantTimeCompare(got[:], want[:]) == 1
+}
+
+// runAdminCommand executes one admin command for either transport. ctx is
+// the context of the request the command arrived on.
+func (h *Hub) runAdminCommand(ctx context.Context, cmd string, p AdminCommandPayload) (interface{}, *ProtocolError) {
+	switch cmd {
+	case AdminListClients:
+		return h.AdminClients(), nil
+
+	case AdminListGames:
+		return h.AdminGames(), nil
+
+	case AdminInspectGame:
+		detail, perr := h.AdminGame(p.GameID)
+		if perr != nil {
+			return nil, perr
+		}
+		return detail, nil
+
+	case AdminEndGame:
+		return nil, h.AdminEndGame(p.GameID)
+
+	case AdminAdjudicate:
+		return nil, h.AdminAdjudicate(p.GameID, p.Winner)
+
+	case AdminKick:
+		if p.Username == "" {
+			return nil, newProtocolError(ErrCodeInvalidMessage, "username is required")
+		}
+		return map[string]int{"kicked": h.AdminKick(p.Username, p.Reason)}, nil
+
+	case AdminBan:
+		if p.Username == "" {
+			return nil, newProtocolError(ErrCodeInvalidMessage, "username is required")
+		}
+		if err := h.AdminBan(ctx, p.Username, p.Reason); err != nil {
+			return nil, newProtocolError(ErrCodeInternal, "%v", err)
+		}
+		return nil, nil
+
+	case AdminUnban:
+		if err := h.AdminUnban(ctx, p.Username); err != nil {
+			return nil, newProtocolError(ErrCodeInternal, "%v", err)
+		}
+		return nil, nil
+
+	case AdminListBans:
+		return h.AdminBans(), nil
+
+	case AdminAnnounce:
+		if strings.TrimSpace(p.Message) == "" {
+			return nil, newProtocolError(ErrCodeInvalidMessage, "message is required")
+		}
+		h.AdminAnnounce(p.Message)
+		return nil, nil
+
+	case AdminRegisterBot:
+		key, perr := h.AdminRegisterBot(ctx, p.Username)
+		if perr != nil {
+			return nil, perr
+		}
+		// The only time the key is shown
+		return map[string]string{"username": p.Username, "apiKey": key}, nil
+
+	case AdminRevokeBot:
+		if err := h.AdminRevokeBot(ctx, p.Username); err != nil {
+			return nil, newProtocolError(ErrCodeInternal, "%v", err)
+		}
+		return nil, nil
+
+	case AdminListBots:
+		return h.AdminBots(), nil
+
+	case AdminRegisterWebhook:
+		hook, perr := h.AdminRegisterWebhook(p.URL, p.Events)
+		if perr != nil {
+			return nil, perr
+		}
+		// The only time the secret is shown
+		return hook, nil
+
+	case AdminDeleteWebhook:
+		return nil, h.AdminDeleteWebhook(p.WebhookID)
+
+	case AdminListWebhooks:
+		hooks, perr := h.AdminWebhooks()
+		if perr != nil {
+			return nil, perr
+		}
+		return hooks, nil
+
+	case AdminWebhookDeliveries:
+		deliveries, perr := h.AdminWebhookDeliveries(p.WebhookID)
+		if perr != nil {
+			return nil, perr
+		}
+		return deliveries, nil
+	}
+	return nil, newProtocolError(ErrCodeUnknownType, "unknown admin command %q", cmd)
+}
+
+// HandleAdmin serves the admin API. Every request needs the admin token as
+// a bearer token.
+//
+//	GET    /admin/clients                    registered clients
+//	GET    /admin/games                      games in activeGames
+//	GET    /admin/games/{id}                 full state and recent events
+//	POST   /admin/games/{id}/end             stop a game without a result
+//	POST   /admin/games/{id}/adjudicate      set the result: {"winner": "..."}, empty for a draw
+//	POST   /admin/users/{username}/kick      disconnect a user: {"reason": "..."}
+//	POST   /admin/users/{username}/ban       ban and disconnect a user: {"reason": "..."}
+//	DELETE /admin/users/{username}/ban       lift a ban
+//	GET    /admin/bans                       banned users
+//	POST   /admin/announce                   message every player: {"message": "..."}
+//	GET    /admin/bots                       registered bot accounts
+//	POST   /admin/bots/{username}            register a bot or replace its API key
+//	DELETE /admin/bots/{username}            revoke a bot account
+//	GET    /admin/webhooks                   registered webhooks
+//	POST   /admin/webhooks                   register a webhook: {"url": "...", "events": [...]}
+//	DELETE /admin/webhooks/{id}              delete a webhook
+//	GET    /admin/webhooks/{id}/deliveries   latest delivery attempts of a webhook
+//	GET    /admin/ws                         admin WebSocket, commands as message types
+func (h *Hub) HandleAdmin(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/admin"), "/")
+	if path == "ws" {
+		h.serveAdminWs(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !h.adminAuthorized(r, false) {
+		writeProtocolError(w, newProtocolError(ErrCodeUnauthorized, "admin token required"))
+		return
+	}
+
+	var p AdminCommandPayload
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			writeProtocolError(w, newProtocolError(ErrCodeInvalidMessage, "invalid request body"))
+			return
+		}
+	}
+
+	parts := strings.Split(path, "/")
+	var cmd string
+	switch {
+	case path == "clients" && r.Method == http.MethodGet:
+		cmd = AdminListClients
+	case path == "games" && r.Method == http.MethodGet:
+		cmd = AdminListGames
+	case path == "bans" && r.Method == http.MethodGet:
+		cmd = AdminListBans
+	case path == "announce" && r.Method == http.MethodPost:
+		cmd = AdminAnnounce
+	case path == "bots" && r.Method == http.MethodGet:
+		cmd = AdminListBots
+	case len(parts) == 2 && parts[0] == "bots" && r.Method == http.MethodPost:
+		cmd, p.Username = AdminRegisterBot, parts[1]
+	case len(parts) == 2 && parts[0] == "bots" && r.Method == http.MethodDelete:
+		cmd, p.Username = AdminRevokeBot, parts[1]
+	case path == "webhooks" && r.Method == http.MethodGet:
+		cmd = AdminListWebhooks
+	case path == "webhooks" && r.Method == http.MethodPost:
+		cmd = AdminRegisterWebhook
+	case len(parts) == 2 && parts[0] == "webhooks" && r.Method == http.MethodDelete:
+		cmd, p.WebhookID = AdminDeleteWebhook, webhookID(parts[1])
+	case len(parts) == 3 && parts[0] == "webhooks" && parts[2] == "deliveries" && r.Method == http.MethodGet:
+		cmd, p.WebhookID = AdminWebhookDeliveries, webhookID(parts[1])
+	case len(parts) == 2 && parts[0] == "games" && r.Method == http.MethodGet:
+		cmd, p.GameID = AdminInspectGame, parts[1]
+	case len(parts) == 3 && parts[0] == "games" && parts[2] == "end" && r.Method == http.MethodPost:
+		cmd, p.GameID = AdminEndGame, parts[1]
+	case len(parts) == 3 && parts[0] == "games" && parts[2] == "adjudicate" && r.Method == http.MethodPost:
+		cmd, p.GameID = AdminAdjudicate, parts[1]
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "kick" && r.Method == http.MethodPost:
+		cmd, p.Username = AdminKick, parts[1]
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "ban" && r.Method == http.MethodPost:
+		cmd, p.Username = AdminBan, parts[1]
+	case len(parts) == 3 && parts[0] == "users" && parts[2] == "ban" && r.Method == http.MethodDelete:
+		cmd, p.Username = AdminUnban, parts[1]
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	result, perr := h.runAdminCommand(r.Context(), cmd, p)
+	if perr != nil {
+		writeProtocolError(w, perr)
+		return
+	}
+	if result == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// webhookID parses a webhook ID from a path; anything else matches no webhook
+func webhookID(s string) int {
+	id, _ := strconv.Atoi(s)
+	return id
+}
+
+// writeProtocolError maps a protocol error to an HTTP status
+func writeProtocolError(w http.ResponseWriter, perr *ProtocolError) {
+	status := http.StatusBadRequest
+	switch perr.Code {
+	case ErrCodeUnauthorized, ErrCodeSessionInvalid:
+		status = http.StatusUnauthorized
+	case ErrCodeNotAPlayer, ErrCodeBanned:
+		status = http.StatusForbidden
+	case ErrCodeNoSuchGame, ErrCodeUnknownType, ErrCodeUserUnavailable, ErrCodeNoSuchWebhook:
+		status = http.StatusNotFound
+	case ErrCodeNotAllowed, ErrCodeGameOver, ErrCodeGameInProgress, ErrCodeAlreadyInGame,
+		ErrCodeNotYourTurn, ErrCodeStaleMove, ErrCodeColumnFull, ErrCodeOutOfTime, ErrCodeUsernameTaken:
+		status = http.StatusConflict
+	case ErrCodeRateLimited:
+		status = http.StatusTooManyRequests
+	case ErrCodeInternal:
+		status = http.StatusInternalServerError
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(perr)
+}
+
+// serveAdminWs upgrades an authorized request to the admin WebSocket. The
+// connection receives presence deltas and answers admin commands.
+func (h *Hub) serveAdminWs(w http.ResponseWriter, r *http.Request) {
+	if !h.adminAuthorized(r, true) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		// The admin token, not the origin, decides who gets in
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("[BACKEND-ADMIN] Admin WebSocket upgrade failed: %v", err)
+		return
+	}
+	log.Printf("[BACKEND-ADMIN] Admin connected from %s", r.RemoteAddr)
+
+	admin := &Client{
+		hub:   h,
+		conn:  conn,
+		codec: jsonCodec{},
+	}
+	admin.send.Store(newOutbox())
+	go admin.writePump()
+
+	h.mu.Lock()
+	h.presenceSubscribers[admin] = true
+	admin.sendEnvelope(Envelope{
+		Type:    MsgPresenceSnapshot,
+		Payload: PresenceSnapshotPayload{Users: h.activeUsersUnsafe()},
+	})
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.presenceSubscribers, admin)
+		admin.closeQueue()
+		h.mu.Unlock()
+		log.Printf("[BACKEND-ADMIN] Admin from %s disconnected", r.RemoteAddr)
+	}()
+
+	conn.SetReadLimit(maxAdminMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg rawMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			h.mu.Lock()
+			admin.sendError("", newProtocolError(ErrCodeInvalidMessage, "malformed message"))
+			h.mu.Unlock()
+			continue
+		}
+
+		var p AdminCommandPayload
+		var perr *ProtocolError
+		if len(msg.Payload) > 0 && string(msg.Payload) != "null" {
+			perr = msg.decodePayload(&p)
+		}
+		var result interface{}
+		if perr == nil {
+			result, perr = h.runAdminCommand(r.Context(), msg.Type, p)
+		}
+
+		h.mu.Lock()
+		if perr != nil {
+			admin.sendError(msg.RequestID, perr)
+		} else {
+			admin.sendEnvelope(Envelope{Type: MsgAdminResult, RequestID: msg.RequestID, Payload: result})
+		}
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,309 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/gorilla/websocket"
+)
+
+const testAdminToken = "admin-secret-token"
+
+// adminRequest sends one request to the admin API with the given
+// Authorization header, or none when it is empty
+func adminRequest(h *Hub, method, path, auth, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleAdmin(rec, req)
+	return rec
+}
+
+func TestAdminAuthorization(t *testing.T) {
+	h := NewHub()
+	if rec := adminRequest(h, http.MethodGet, "/admin/clients", "Bearer ", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without an admin token set: %d, want the API disabled", rec.Code)
+	}
+	h.SetAdminToken(testAdminToken)
+
+	tests := []struct {
+		name string
+		path string
+		auth string
+		want int
+	}{
+		{"no header", "/admin/clients", "", http.StatusUnauthorized},
+		{"empty bearer", "/admin/clients", "Bearer ", http.StatusUnauthorized},
+		{"wrong token", "/admin/clients", "Bearer wrong", http.StatusUnauthorized},
+		{"prefix of the token", "/admin/clients", "Bearer " + testAdminToken[:5], http.StatusUnauthorized},
+		{"token with more after it", "/admin/clients", "Bearer " + testAdminToken + "x", http.StatusUnauthorized},
+		{"other case", "/admin/clients", "Bearer " + strings.ToUpper(testAdminToken), http.StatusUnauthorized},
+		{"other scheme", "/admin/clients", "Basic " + testAdminToken, http.StatusUnauthorized},
+		{"query parameter outside the WebSocket", "/admin/clients?token=" + testAdminToken, "", http.StatusUnauthorized},
+		{"unauthorized write", "/admin/announce", "Bearer wrong", http.StatusUnauthorized},
+		{"right token", "/admin/clients", "Bearer " + testAdminToken, http.StatusOK},
+	}
+	for _, tt := range tests {
+		method := http.MethodGet
+		if strings.HasSuffix(tt.path, "announce") {
+			method = http.MethodPost
+		}
+		rec := adminRequest(h, method, tt.path, tt.auth, `{"message": "hi"}`)
+		if rec.Code != tt.want {
+			t.Errorf("%s: %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+		}
+		if tt.want == http.StatusUnauthorized && !strings.Contains(rec.Body.String(), ErrCodeUnauthorized) {
+			t.Errorf("%s: body %s, want %s", tt.name, rec.Body, ErrCodeUnauthorized)
+		}
+	}
+
+	// The WebSocket takes the token as a query parameter too
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleAdmin))
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/admin/ws"
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"?token=wrong", nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("admin WebSocket with a wrong token: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?token="+testAdminToken, nil)
+	if err != nil {
+		t.Fatalf("admin WebSocket with the token: %v", err)
+	}
+	conn.Close()
+}
+
+// TestAdminTokenComparisonIsConstantTime times comparisons against a long
+// token. One differing in its first byte must take as long to refuse as one
+// differing in its last; a plain comparison returns at the first difference.
+func TestAdminTokenComparisonIsConstantTime(t *testing.T) {
+	expected := strings.Repeat("k", 64<<10)
+	early := "x" + expected[1:]
+	late := expected[:len(expected)-1] + "x"
+	short := "k"
+	if adminTokenMatches(early, expected) || adminTokenMatches(late, expected) || adminTokenMatches(short, expected) {
+		t.Fatal("a wrong token matched")
+	}
+	if !adminTokenMatches(expected, expected) {
+		t.Fatal("the right token did not match")
+	}
+
+	// The fastest of many runs is the least disturbed by everything else
+	fastest := func(token string) time.Duration {
+		best := time.Hour
+		for i := 0; i < 200; i++ {
+			start := time.Now()
+			adminTokenMatches(token, expected)
+			if d := time.Since(start); d < best {
+				best = d
+			}
+		}
+		return best
+	}
+	e, l := fastest(early), fastest(late)
+	if ratio := float64(l) / float64(e); ratio > 2 || ratio < 0.5 {
+		t.Errorf("refusing a token differing at the start takes %v, at the end %v", e, l)
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	h := NewHub()
+	h.SetAdminToken(testAdminToken)
+	sockets := newSocketServer(t)
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		return adminRequest(h, method, path, "Bearer "+testAdminToken, body)
+	}
+	decode := func(rec *httptest.ResponseRecorder, v interface{}) {
+		t.Helper()
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("%d %s: %v", rec.Code, rec.Body, err)
+		}
+	}
+
+	alice, alicePeer := sockets.watchedClient(t, h, "alice")
+	bob := sockets.mustClient(t, h, "bob")
+	carol, carolPeer := sockets.watchedClient(t, h, "carol")
+	dave, davePeer := sockets.watchedClient(t, h, "dave")
+	h.mu.Lock()
+	h.createGame(alice, bob, game.DefaultSettings(), config.ModeFriend, nil, "")
+	h.createGame(carol, dave, game.DefaultSettings(), config.ModeFriend, nil, "")
+	adjudicated, ended := alice.gameID, carol.gameID
+	carol.sessionToken = "carol-token"
+	h.mu.Unlock()
+
+	// List and inspect
+	var clients []AdminClient
+	decode(admin(http.MethodGet, "/admin/clients", ""), &clients)
+	if len(clients) != 4 || clients[0].Username != "alice" || clients[0].Status != PresencePlaying || clients[0].GameID != adjudicated {
+		t.Errorf("clients %+v", clients)
+	}
+	var games []AdminGame
+	decode(admin(http.MethodGet, "/admin/games", ""), &games)
+	if len(games) != 2 {
+		t.Errorf("games %+v, want 2", games)
+	}
+	if err := h.handleMove(alice, 3); err != nil {
+		t.Fatal(err)
+	}
+	var detail AdminGameDetail
+	decode(admin(http.MethodGet, "/admin/games/"+adjudicated, ""), &detail)
+	if detail.ID != adjudicated || detail.Player1 != "alice" || detail.Moves != 1 || detail.State == nil ||
+		detail.Seats[0].Username != "alice" || detail.Seats[1].Username != "bob" {
+		t.Errorf("game detail %+v", detail)
+	}
+	if rec := admin(http.MethodGet, "/admin/games/nope", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown game: %d", rec.Code)
+	}
+
+	// Adjudicate
+	if rec := admin(http.MethodPost, "/admin/games/"+adjudicated+"/adjudicate", `{"winner": "carol"}`); rec.Code != http.StatusForbidden {
+		t.Errorf("adjudicating for a non-player: %d %s", rec.Code, rec.Body)
+	}
+	if rec := admin(http.MethodPost, "/admin/games/"+adjudicated+"/adjudicate", `{"winner": "bob"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("adjudicate: %d %s", rec.Code, rec.Body)
+	}
+	var result game.GameState
+	for result.Status != game.StatusCompleted {
+		result = game.GameState{}
+		msg := alicePeer.expect(t, MsgGameState)
+		if perr := msg.decodePayload(&result); perr != nil {
+			t.Fatal(perr)
+		}
+	}
+	if result.Winner == nil || result.Winner.Username != "bob" {
+		t.Errorf("adjudicated game: %+v, want bob to win", result)
+	}
+	if rec := admin(http.MethodPost, "/admin/games/"+adjudicated+"/adjudicate", ""); rec.Code != http.StatusConflict {
+		t.Errorf("adjudicating a finished game: %d", rec.Code)
+	}
+
+	// Force-end
+	if rec := admin(http.MethodPost, "/admin/games/"+ended+"/end", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("end: %d %s", rec.Code, rec.Body)
+	}
+	decode(admin(http.MethodGet, "/admin/games/"+ended, ""), &detail)
+	if detail.Status != game.StatusAborted {
+		t.Errorf("ended game is %s, want %s", detail.Status, game.StatusAborted)
+	}
+	if rec := admin(http.MethodPost, "/admin/games/"+ended+"/end", ""); rec.Code != http.StatusConflict {
+		t.Errorf("ending a finished game: %d", rec.Code)
+	}
+
+	// Broadcast
+	if rec := admin(http.MethodPost, "/admin/announce", `{"message": "  "}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty announcement: %d", rec.Code)
+	}
+	if rec := admin(http.MethodPost, "/admin/announce", `{"message": "restarting in 5 minutes"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("announce: %d %s", rec.Code, rec.Body)
+	}
+	for _, p := range []*peer{alicePeer, carolPeer, davePeer} {
+		var announcement AnnouncementPayload
+		msg := p.expect(t, MsgAnnouncement)
+		if msg.decodePayload(&announcement); announcement.Message != "restarting in 5 minutes" {
+			t.Errorf("announcement %s", msg.Payload)
+		}
+	}
+
+	// Kick
+	var kicked map[string]int
+	decode(admin(http.MethodPost, "/admin/users/carol/kick", `{"reason": "spam"}`), &kicked)
+	if kicked["kicked"] != 1 {
+		t.Errorf("kick: %v", kicked)
+	}
+	if reason := carolPeer.closed(t); reason != "kicked: spam" {
+		t.Errorf("kicked with reason %q", reason)
+	}
+	h.mu.Lock()
+	token := carol.sessionToken
+	h.mu.Unlock()
+	if token != "" {
+		t.Error("a kicked player can still resume their session")
+	}
+	decode(admin(http.MethodPost, "/admin/users/nobody/kick", ""), &kicked)
+	if kicked["kicked"] != 0 {
+		t.Errorf("kicking nobody: %v", kicked)
+	}
+
+	// Ban and unban
+	if rec := admin(http.MethodPost, "/admin/users/dave/ban", `{"reason": "cheating"}`); rec.Code != http.StatusNoContent {
+		t.Fatalf("ban: %d %s", rec.Code, rec.Body)
+	}
+	if reason := davePeer.closed(t); reason != "banned: cheating" {
+		t.Errorf("banned with reason %q", reason)
+	}
+	var bans map[string]string
+	decode(admin(http.MethodGet, "/admin/bans", ""), &bans)
+	if bans["dave"] != "cheating" {
+		t.Errorf("bans %v", bans)
+	}
+	h.mu.Lock()
+	_, perr := h.claimUsernameUnsafe(&Client{}, "dave", "", true)
+	h.mu.Unlock()
+	if perr == nil || perr.Code != ErrCodeBanned {
+		t.Errorf("claiming a banned name: %v", perr)
+	}
+	if rec := admin(http.MethodDelete, "/admin/users/dave/ban", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("unban: %d %s", rec.Code, rec.Body)
+	}
+	bans = nil
+	decode(admin(http.MethodGet, "/admin/bans", ""), &bans)
+	if len(bans) != 0 {
+		t.Errorf("bans after unban: %v", bans)
+	}
+
+	// Requests that name no command
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/admin/nothing"},
+		{http.MethodDelete, "/admin/clients"},
+		{http.MethodGet, "/admin/users/dave/kick"},
+	} {
+		if rec := admin(r.method, r.path, ""); rec.Code != http.StatusNotFound {
+			t.Errorf("%s %s: %d, want 404", r.method, r.path, rec.Code)
+		}
+	}
+}
+
+func TestAdminWebSocket(t *testing.T) {
+	h := NewHub()
+	h.SetAdminToken(testAdminToken)
+	sockets := newSocketServer(t)
+	sockets.mustClient(t, h, "alice")
+	srv := httptest.NewServer(http.HandlerFunc(h.HandleAdmin))
+	defer srv.Close()
+
+	header := http.Header{"Authorization": {"Bearer " + testAdminToken}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/admin/ws", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	read := func() rawMessage {
+		var msg rawMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if msg := read(); msg.Type != MsgPresenceSnapshot {
+		t.Fatalf("first message %s, want %s", msg.Type, MsgPresenceSnapshot)
+	}
+	conn.WriteJSON(map[string]string{"type": AdminListClients, "requestId": "r1"})
+	msg := read()
+	var clients []AdminClient
+	if msg.Type != MsgAdminResult || msg.RequestID != "r1" || msg.decodePayload(&clients) != nil || len(clients) != 1 {
+		t.Errorf("listClients answered %s %s %s", msg.Type, msg.RequestID, msg.Payload)
+	}
+
+	conn.WriteJSON(map[string]string{"type": "shutdown", "requestId": "r2"})
+	if msg := read(); msg.Type != MsgError || msg.RequestID != "r2" || !strings.Contains(string(msg.Payload), ErrCodeUnknownType) {
+		t.Errorf("unknown command answered %s %s %s", msg.Type, msg.RequestID, msg.Payload)
+	}
+}
@@ -0,0 +1,151 @@
+package ws
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/connect4/backend/internal/database"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/middleware"
+)
+
+// Registered bots may send botMessageRate messages per second on average,
+// in bursts of up to botMessageBurst
+const (
+	botMessageRate  = 5
+	botMessageBurst = 10
+)
+
+// botAccountTimeControl is imposed on untimed games a registered bot plays
+// in, so a stuck bot loses on time instead of holding up its opponent
+var botAccountTimeControl = game.TimeControl{InitialSeconds: 120, IncrementSeconds: 2}
+
+// apiKeyPrefix marks bot API keys so they are recognizable in configs
+const apiKeyPrefix = "c4bot_"
+
+// hashAPIKey returns the form of an API key that is stored
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateBotUnsafe checks a bot's API key and marks client as that bot.
+// Must be called with h.mu held.
+func (h *Hub) authenticateBotUnsafe(client *Client, username, apiKey string) *ProtocolError {
+	hash, registered := h.botAccounts[username]
+	if !registered || subtle.ConstantTimeCompare([]byte(hash), []byte(hashAPIKey(apiKey))) != 1 {
+		return newProtocolError(ErrCodeUnauthorized, "unknown bot or wrong API key")
+	}
+	client.markBotAccount()
+	return nil
+}
+
+// markBotAccount subjects client to the limits registered bots play under
+func (c *Client) markBotAccount() {
+	c.botAccount = true
+	c.limiter.Store(middleware.NewRateLimiter(botMessageRate, botMessageBurst))
+}
+
+// enforceBotClock gives settings a clock when a registered bot plays
+func enforceBotClock(settings game.Settings, players ...*Client) game.Settings {
+	if settings.TimeControl != nil {
+		return settings
+	}
+	for _, p := range players {
+		if p != nil && p.botAccount {
+			tc := botAccountTimeControl
+			settings.TimeControl = &tc
+			break
+		}
+	}
+	return settings
+}
+
+// AdminRegisterBot registers a bot under username, or replaces its API key,
+// and returns the new key. The key is not stored and cannot be shown again.
+func (h *Hub) AdminRegisterBot(ctx context.Context, username string) (string, *ProtocolError) {
+	name, perr := validateUsername(username)
+	if perr != nil {
+		return "", perr
+	}
+	if name == botUsername {
+		return "", newProtocolError(ErrCodeInvalidUsername, "username %q is reserved", name)
+	}
+
+	key := apiKeyPrefix + newSessionToken()
+	if h.db != nil {
+		if err := h.db.SaveBotAccount(ctx, name, hashAPIKey(key)); err != nil {
+			if errors.Is(err, database.ErrUsernameInUse) {
+				return "", newProtocolError(ErrCodeUsernameTaken, "username %q belongs to a human player", name)
+			}
+			return "", newProtocolError(ErrCodeInternal, "%v", err)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	// Connections authenticated with the old key must not outlive it
+	if _, rotated := h.botAccounts[name]; rotated {
+		h.kickUnsafe(name, "API key replaced")
+	}
+	h.botAccounts[name] = hashAPIKey(key)
+	log.Printf("[BACKEND-BOT] Registered bot account %s", name)
+	return key, nil
+}
+
+// AdminRevokeBot deletes a bot account and disconnects the bot
+func (h *Hub) AdminRevokeBot(ctx context.Context, username string) error {
+	if h.db != nil {
+		if err := h.db.DeleteBotAccount(ctx, username); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, registered := h.botAccounts[username]; registered {
+		delete(h.botAccounts, username)
+		h.kickUnsafe(username, "bot account revoked")
+	}
+	return nil
+}
+
+// AdminBots returns the registered bot usernames
+func (h *Hub) AdminBots() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	bots := make([]string, 0, len(h.botAccounts))
+	for username := range h.botAccounts {
+		bots = append(bots, username)
+	}
+	sort.Strings(bots)
+	return bots
+}
+
+// LoadBotAccounts reads the registered bots from the database
+func (h *Hub) LoadBotAccounts(ctx context.Context) error {
+	h.mu.Lock()
+	db := h.db
+	h.mu.Unlock()
+	if db == nil {
+		return nil
+	}
+
+	bots, err := db.ListBotAccounts(ctx)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, b := range bots {
+		h.botAccounts[b.Username] = b.APIKeyHash
+	}
+	log.Printf("[BACKEND-BOT] Loaded %d bot accounts", len(bots))
+	return nil
+}
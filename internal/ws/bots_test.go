@@ -0,0 +1,169 @@
+package ws
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/connect4/backend/internal/game"
+)
+
+func registerBot(t *testing.T, h *Hub, username string) string {
+	t.Helper()
+	key, perr := h.AdminRegisterBot(context.Background(), username)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	return key
+}
+
+// joinBot connects a bot with key and waits for its welcome
+func joinBot(t *testing.T, srv *httptest.Server, username, key string) *player {
+	t.Helper()
+	bot := dialPlayer(t, srv)
+	bot.send(t, MsgJoin, "join", JoinPayload{Username: username, GameMode: "friend", Bot: true, APIKey: key, ProtocolVersion: ProtocolVersion})
+	bot.expect(t, MsgWelcome)
+	return bot
+}
+
+func TestBotAPIKey(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	key := registerBot(t, h, "deep-thought")
+
+	tests := []struct {
+		name string
+		join JoinPayload
+		code string
+	}{
+		{"wrong key", JoinPayload{Username: "deep-thought", Bot: true, APIKey: key + "x"}, ErrCodeUnauthorized},
+		{"no key", JoinPayload{Username: "deep-thought", Bot: true}, ErrCodeUnauthorized},
+		{"key of another bot", JoinPayload{Username: "hal", Bot: true, APIKey: key}, ErrCodeUnauthorized},
+		{"bot name without being the bot", JoinPayload{Username: "deep-thought", APIKey: key}, ErrCodeUsernameTaken},
+	}
+	for i, tt := range tests {
+		p := dialPlayer(t, srv)
+		tt.join.GameMode, tt.join.ProtocolVersion = "friend", ProtocolVersion
+		requestID := strconv.Itoa(i)
+		p.send(t, MsgJoin, requestID, tt.join)
+		if code := p.expectError(t, requestID); code != tt.code {
+			t.Errorf("%s: error %s, want %s", tt.name, code, tt.code)
+		}
+	}
+
+	joinBot(t, srv, "deep-thought", key)
+}
+
+// TestBotKeyRotation registers a bot again while it is connected. The old
+// key stops working and the connection made with it is closed.
+func TestBotKeyRotation(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	oldKey := registerBot(t, h, "deep-thought")
+	bot := joinBot(t, srv, "deep-thought", oldKey)
+
+	newKey := registerBot(t, h, "deep-thought")
+	if newKey == oldKey {
+		t.Fatal("registering again kept the API key")
+	}
+	if reason := bot.closed(t); reason != "API key replaced" {
+		t.Errorf("connection closed with %q", reason)
+	}
+
+	stale := dialPlayer(t, srv)
+	stale.send(t, MsgJoin, "j1", JoinPayload{Username: "deep-thought", GameMode: "friend", Bot: true, APIKey: oldKey, ProtocolVersion: ProtocolVersion})
+	if code := stale.expectError(t, "j1"); code != ErrCodeUnauthorized {
+		t.Errorf("old key: error %s, want %s", code, ErrCodeUnauthorized)
+	}
+	joinBot(t, srv, "deep-thought", newKey)
+}
+
+func TestEnforceBotClock(t *testing.T) {
+	human, bot, builtin := &Client{username: "alice"}, &Client{username: "hal", botAccount: true}, &Client{username: botUsername, isBot: true}
+	blitz := &game.TimeControl{InitialSeconds: 60}
+	tests := []struct {
+		name    string
+		clock   *game.TimeControl
+		players []*Client
+		want    *game.TimeControl
+	}{
+		{"humans", nil, []*Client{human, {username: "bob"}}, nil},
+		{"built-in bot", nil, []*Client{human, builtin}, nil},
+		{"registered bot", nil, []*Client{human, bot}, &botAccountTimeControl},
+		{"registered bot moving first", nil, []*Client{bot, human}, &botAccountTimeControl},
+		{"registered bot, timed game", blitz, []*Client{human, bot}, blitz},
+		{"no opponent yet", nil, []*Client{bot, nil}, &botAccountTimeControl},
+	}
+	for _, tt := range tests {
+		settings := game.DefaultSettings()
+		settings.TimeControl = tt.clock
+		got := enforceBotClock(settings, tt.players...).TimeControl
+		switch {
+		case tt.want == nil && got != nil:
+			t.Errorf("%s: clock %+v, want none", tt.name, *got)
+		case tt.want != nil && (got == nil || *got != *tt.want):
+			t.Errorf("%s: clock %v, want %+v", tt.name, got, *tt.want)
+		}
+	}
+
+	// The bot's clock is a copy; a game running it cannot change the default
+	settings := enforceBotClock(game.DefaultSettings(), bot)
+	settings.TimeControl.InitialSeconds = 1
+	if botAccountTimeControl.InitialSeconds == 1 {
+		t.Error("the game's clock is shared with botAccountTimeControl")
+	}
+}
+
+// TestBotGetsClock pairs a registered bot with a human in an untimed game
+func TestBotGetsClock(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	bot := joinBot(t, srv, "deep-thought", registerBot(t, h, "deep-thought"))
+	human := dialPlayer(t, srv)
+	human.send(t, MsgJoin, "", JoinPayload{Username: "alice", GameMode: "friend", ProtocolVersion: ProtocolVersion})
+
+	for _, p := range []*player{bot, human} {
+		msg := p.expect(t, MsgGameStart)
+		var start GameStartPayload
+		if perr := msg.decodePayload(&start); perr != nil {
+			t.Fatal(perr)
+		}
+		if start.Settings == nil || start.Settings.TimeControl == nil || *start.Settings.TimeControl != botAccountTimeControl {
+			t.Errorf("gameStart settings %s, want the bot clock", msg.Payload)
+		}
+	}
+}
+
+// TestBotRateLimit sends a registered bot's messages faster than it may. A
+// burst goes through, the next message is refused; humans are not limited.
+func TestBotRateLimit(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	bot := joinBot(t, srv, "deep-thought", registerBot(t, h, "deep-thought"))
+	human := dialPlayer(t, srv)
+	human.send(t, MsgJoin, "join", JoinPayload{Username: "alice", GameMode: "computer", ProtocolVersion: ProtocolVersion})
+	human.expect(t, MsgWelcome)
+
+	for i := 0; i <= botMessageBurst; i++ {
+		bot.send(t, "noop", strconv.Itoa(i), nil)
+	}
+	for i := 0; i <= botMessageBurst; i++ {
+		want := ErrCodeUnknownType
+		if i == botMessageBurst {
+			want = ErrCodeRateLimited
+		}
+		if code := bot.expectError(t, strconv.Itoa(i)); code != want {
+			t.Errorf("bot message %d: error %s, want %s", i+1, code, want)
+		}
+	}
+
+	for i := 0; i <= 2*botMessageBurst; i++ {
+		human.send(t, "noop", strconv.Itoa(i), nil)
+	}
+	for i := 0; i <= 2*botMessageBurst; i++ {
+		if code := human.expectError(t, strconv.Itoa(i)); code != ErrCodeUnknownType {
+			t.Errorf("human message %d: error %s, want %s", i+1, code, ErrCodeUnknownType)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/google/uuid"
+)
+
+// challengeTimeout is how long a challenge stays open before it expires
+const challengeTimeout = 60 * time.Second
+
+// Challenge is an open invitation from one online player to another
+type Challenge struct {
+	ID        string        `json:"challengeId"`
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Settings  game.Settings `json:"settings"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+
+	challenger *Client
+	target     *Client
+	timer      *time.Timer
+}
+
+// handleChallenge creates a challenge from client to the named user
+func (h *Hub) handleChallenge(client *Client, req ChallengePayload) *ProtocolError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.username == "" {
+		return newProtocolError(ErrCodeNotJoined, "join before sending challenges")
+	}
+	if client.gameID != "" {
+		return newProtocolError(ErrCodeAlreadyInGame, "you are already in a game")
+	}
+	if req.Username == "" || req.Username == client.username {
+		return newProtocolError(ErrCodeUserUnavailable, "invalid challenge target")
+	}
+
+	settings := req.Settings
+	if err := settings.Validate(); err != nil {
+		return newProtocolError(ErrCodeInvalidSettings, "%v", err)
+	}
+
+	target := h.findClientUnsafe(req.Username)
+	if target == nil || target.isBot || target.disconnectedAt != nil {
+		return newProtocolError(ErrCodeUserUnavailable, "user is not online")
+	}
+	if target.gameID != "" {
+		return newProtocolError(ErrCodeUserUnavailable, "user is already in a game")
+	}
+
+	ch := &Challenge{
+		ID:         uuid.New().String(),
+		From:       client.username,
+		To:         target.username,
+		Settings:   settings,
+		ExpiresAt:  time.Now().Add(challengeTimeout),
+		challenger: client,
+		target:     target,
+	}
+	ch.timer = time.AfterFunc(challengeTimeout, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.challenges[ch.ID]; !ok {
+			return
+		}
+		log.Printf("[BACKEND-CHALLENGE] Challenge %s from %s to %s expired", ch.ID, ch.From, ch.To)
+		delete(h.challenges, ch.ID)
+		h.sendChallengeEvent(ch, MsgChallengeExpired)
+	})
+	h.challenges[ch.ID] = ch
+
+	log.Printf("[BACKEND-CHALLENGE] %s challenged %s (challenge=%s)", ch.From, ch.To, ch.ID)
+	h.sendChallengeMessage(target, MsgChallengeReceived, ch)
+	h.sendChallengeMessage(client, MsgChallengeSent, ch)
+	return nil
+}
+
+// handleAcceptChallenge starts a game between the challenger and client
+func (h *Hub) handleAcceptChallenge(client *Client, challengeID string) *ProtocolError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch, ok := h.challenges[challengeID]
+	if !ok || ch.target != client {
+		return newProtocolError(ErrCodeNoSuchChallenge, "no such challenge")
+	}
+	ch.timer.Stop()
+	delete(h.challenges, ch.ID)
+
+	challenger := ch.challenger
+	if challenger.disconnectedAt != nil || challenger.gameID != "" || client.gameID != "" {
+		h.sendChallengeEvent(ch, MsgChallengeCancel)
+		return nil
+	}
+
+	// Either side may have been sitting in the matchmaking queue
+	for _, c := range []*Client{challenger, client} {
+		h.stopWaitingUnsafe(c)
+	}
+
+	// Drop any other open challenges involving these players
+	h.cancelChallengesUnsafe(challenger)
+	h.cancelChallengesUnsafe(client)
+
+	log.Printf("[BACKEND-CHALLENGE] %s accepted challenge %s from %s", client.username, ch.ID, ch.From)
+	h.createGame(challenger, client, ch.Settings, config.ModeChallenge, nil, "")
+	return nil
+}
+
+// handleDeclineChallenge rejects a challenge addressed to client
+func (h *Hub) handleDeclineChallenge(client *Client, challengeID string) *ProtocolError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch, ok := h.challenges[challengeID]
+	if !ok || ch.target != client {
+		return newProtocolError(ErrCodeNoSuchChallenge, "no such challenge")
+	}
+	ch.timer.Stop()
+	delete(h.challenges, ch.ID)
+
+	log.Printf("[BACKEND-CHALLENGE] %s declined challenge %s from %s", client.username, ch.ID, ch.From)
+	h.sendChallengeEvent(ch, MsgChallengeDeclined)
+	return nil
+}
+
+// cancelChallengesUnsafe withdraws every open challenge involving client.
+// Must be called with h.mu held.
+func (h *Hub) cancelChallengesUnsafe(client *Client) {
+	for id, ch := range h.challenges {
+		if ch.challenger != client && ch.target != client {
+			continue
+		}
+		ch.timer.Stop()
+		delete(h.challenges, id)
+		h.sendChallengeEvent(ch, MsgChallengeCancel)
+	}
+}
+
+// sendChallengeEvent notifies both sides of a challenge about its outcome
+func (h *Hub) sendChallengeEvent(ch *Challenge, msgType string) {
+	h.sendChallengeMessage(ch.challenger, msgType, ch)
+	h.sendChallengeMessage(ch.target, msgType, ch)
+}
+
+// sendChallengeMessage sends a challenge-related message to a single client
+func (h *Hub) sendChallengeMessage(client *Client, msgType string, ch *Challenge) {
+	client.sendEnvelope(Envelope{Type: msgType, Payload: ch})
+}
@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/connect4/backend/internal/game"
+)
+
+// joinLobby joins p as a player who is online for challenges but not queued
+func joinLobby(t *testing.T, p *player, name string) {
+	t.Helper()
+	p.send(t, MsgJoin, "join", JoinPayload{Username: name, GameMode: "lobby", ProtocolVersion: ProtocolVersion})
+	expectReply(t, p.peer, MsgAck, "join")
+}
+
+// expectChallenge waits for a challenge message of type msgType
+func expectChallenge(t *testing.T, p *player, msgType string) Challenge {
+	t.Helper()
+	msg := p.expect(t, msgType)
+	var ch Challenge
+	if perr := msg.decodePayload(&ch); perr != nil {
+		t.Fatal(perr)
+	}
+	return ch
+}
+
+// TestChallengeAccepted starts a game from a challenge. The settings of the
+// challenge are those of the game, and the other open challenges of both
+// players are withdrawn.
+func TestChallengeAccepted(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	alice, bob, carol := dialPlayer(t, srv), dialPlayer(t, srv), dialPlayer(t, srv)
+	joinLobby(t, alice, "alice")
+	joinLobby(t, bob, "bob")
+	joinLobby(t, carol, "carol")
+
+	settings := game.Settings{Variant: game.VariantStandard, TimeControl: &game.TimeControl{InitialSeconds: 180, IncrementSeconds: 2}}
+	alice.send(t, MsgChallenge, "c1", ChallengePayload{Username: "bob", Settings: settings})
+	sent := expectChallenge(t, alice, MsgChallengeSent)
+	received := expectChallenge(t, bob, MsgChallengeReceived)
+	expectReply(t, alice.peer, MsgAck, "c1")
+	if sent.ID == "" || received.ID != sent.ID || received.From != "alice" || received.To != "bob" {
+		t.Fatalf("sent %+v, received %+v", sent, received)
+	}
+	alice.send(t, MsgChallenge, "c2", ChallengePayload{Username: "carol", Settings: game.DefaultSettings()})
+	other := expectChallenge(t, carol, MsgChallengeReceived)
+
+	bob.send(t, MsgAcceptChallenge, "a1", ChallengeResponsePayload{ChallengeID: received.ID})
+	// The other challenges are withdrawn before the game starts
+	for _, p := range []*player{alice, carol} {
+		if ch := expectChallenge(t, p, MsgChallengeCancel); ch.ID != other.ID {
+			t.Errorf("cancelled %s, want the challenge to carol", ch.ID)
+		}
+	}
+	for _, p := range []*player{alice, bob} {
+		msg := p.expect(t, MsgGameStart)
+		var start GameStartPayload
+		if perr := msg.decodePayload(&start); perr != nil {
+			t.Fatal(perr)
+		}
+		if start.Settings == nil || start.Settings.TimeControl == nil || *start.Settings.TimeControl != *settings.TimeControl {
+			t.Errorf("game started with %s, want the challenge's clock", msg.Payload)
+		}
+		if start.Settings != nil && start.Settings.Rated {
+			t.Error("the challenge was unrated but the game is rated")
+		}
+	}
+	carol.send(t, MsgAcceptChallenge, "a2", ChallengeResponsePayload{ChallengeID: other.ID})
+	if code := carol.expectError(t, "a2"); code != ErrCodeNoSuchChallenge {
+		t.Errorf("accepting a withdrawn challenge: %s, want %s", code, ErrCodeNoSuchChallenge)
+	}
+}
+
+func TestChallengeDeclinedAndExpired(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	alice, bob := dialPlayer(t, srv), dialPlayer(t, srv)
+	joinLobby(t, alice, "alice")
+	joinLobby(t, bob, "bob")
+
+	alice.send(t, MsgChallenge, "c1", ChallengePayload{Username: "bob", Settings: game.DefaultSettings()})
+	ch := expectChallenge(t, bob, MsgChallengeReceived)
+	// Only the challenged player answers a challenge
+	alice.send(t, MsgDeclineChallenge, "d0", ChallengeResponsePayload{ChallengeID: ch.ID})
+	if code := alice.expectError(t, "d0"); code != ErrCodeNoSuchChallenge {
+		t.Errorf("challenger declining: %s, want %s", code, ErrCodeNoSuchChallenge)
+	}
+	bob.send(t, MsgDeclineChallenge, "d1", ChallengeResponsePayload{ChallengeID: ch.ID})
+	for _, p := range []*player{alice, bob} {
+		if declined := expectChallenge(t, p, MsgChallengeDeclined); declined.ID != ch.ID {
+			t.Errorf("declined %s, want %s", declined.ID, ch.ID)
+		}
+	}
+	bob.send(t, MsgDeclineChallenge, "d2", ChallengeResponsePayload{ChallengeID: ch.ID})
+	if code := bob.expectError(t, "d2"); code != ErrCodeNoSuchChallenge {
+		t.Errorf("declining twice: %s, want %s", code, ErrCodeNoSuchChallenge)
+	}
+
+	alice.send(t, MsgChallenge, "c2", ChallengePayload{Username: "bob", Settings: game.DefaultSettings()})
+	ch = expectChallenge(t, bob, MsgChallengeReceived)
+	// What the expiry timer does when it fires
+	h.mu.Lock()
+	h.challenges[ch.ID].timer.Reset(0)
+	h.mu.Unlock()
+	for _, p := range []*player{alice, bob} {
+		if expired := expectChallenge(t, p, MsgChallengeExpired); expired.ID != ch.ID {
+			t.Errorf("expired %s, want %s", expired.ID, ch.ID)
+		}
+	}
+	bob.send(t, MsgAcceptChallenge, "a1", ChallengeResponsePayload{ChallengeID: ch.ID})
+	if code := bob.expectError(t, "a1"); code != ErrCodeNoSuchChallenge {
+		t.Errorf("accepting an expired challenge: %s, want %s", code, ErrCodeNoSuchChallenge)
+	}
+}
+
+func TestChallengeRejected(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	alice, bob := dialPlayer(t, srv), dialPlayer(t, srv)
+	joinLobby(t, alice, "alice")
+	joinLobby(t, bob, "bob")
+	playing := dialPlayer(t, srv)
+	playing.send(t, MsgJoin, "join", JoinPayload{Username: "carol", GameMode: "computer", ProtocolVersion: ProtocolVersion})
+	playing.expect(t, MsgGameStart)
+	stranger := dialPlayer(t, srv)
+
+	tests := []struct {
+		name   string
+		from   *player
+		to     string
+		change func(*game.Settings)
+		code   string
+	}{
+		{"not joined", stranger, "bob", nil, ErrCodeNotJoined},
+		{"themselves", alice, "alice", nil, ErrCodeUserUnavailable},
+		{"nobody", alice, "", nil, ErrCodeUserUnavailable},
+		{"offline user", alice, "dave", nil, ErrCodeUserUnavailable},
+		{"user in a game", alice, "carol", nil, ErrCodeUserUnavailable},
+		{"from a game", playing, "alice", nil, ErrCodeAlreadyInGame},
+		{"unknown variant", alice, "bob", func(s *game.Settings) { s.Variant = "popout" }, ErrCodeInvalidSettings},
+		{"no time on the clock", alice, "bob", func(s *game.Settings) { s.TimeControl = &game.TimeControl{} }, ErrCodeInvalidSettings},
+		{"even series", alice, "bob", func(s *game.Settings) { s.BestOf = 2 }, ErrCodeInvalidSettings},
+	}
+	for i, tt := range tests {
+		settings := game.DefaultSettings()
+		if tt.change != nil {
+			tt.change(&settings)
+		}
+		requestID := string(rune('a' + i))
+		tt.from.send(t, MsgChallenge, requestID, ChallengePayload{Username: tt.to, Settings: settings})
+		if code := tt.from.expectError(t, requestID); code != tt.code {
+			t.Errorf("%s: error %s, want %s", tt.name, code, tt.code)
+		}
+	}
+	bob.send(t, MsgAcceptChallenge, "x", ChallengeResponsePayload{ChallengeID: "unknown"})
+	if code := bob.expectError(t, "x"); code != ErrCodeNoSuchChallenge {
+		t.Errorf("accepting an unknown challenge: %s, want %s", code, ErrCodeNoSuchChallenge)
+	}
+	h.mu.Lock()
+	open := len(h.challenges)
+	h.mu.Unlock()
+	if open != 0 {
+		t.Errorf("%d challenges open after only refused ones", open)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/connect4/backend/internal/game"
 	"github.com/gorilla/websocket"
 )
 
@@ -26,6 +27,8 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		// Clients list the encodings they accept in order of preference
+		Subprotocols: []string{SubprotocolMsgpack, SubprotocolJSON},
 
 		CheckOrigin: func(r *http.Request) bool {
 			origin := r.Header.Get("Origin")
@@ -86,13 +89,14 @@ func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("[BACKEND-2] ServeWs: WebSocket connection upgraded successfully from %s", r.RemoteAddr)
+	log.Printf("[BACKEND-2] ServeWs: WebSocket connection upgraded successfully from %s (subprotocol=%q)", r.RemoteAddr, conn.Subprotocol())
 
 	client := &Client{
-		hub:  hub,
-		conn: conn,
-		send: make(chan []byte, 256),
+		hub:   hub,
+		conn:  conn,
+		codec: codecForSubprotocol(conn.Subprotocol()),
 	}
+	client.send.Store(newOutbox())
 
 	client.hub.register <- client
 	go client.writePump()
@@ -116,7 +120,7 @@ func (c *Client) readPump() {
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		frameType, message, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNoStatusReceived) {
 				log.Printf("[BACKEND-5] Client.readPump: WebSocket error: %v", err)
@@ -126,70 +130,212 @@ func (c *Client) readPump() {
 			break
 		}
 
-		log.Printf("[BACKEND-6] Client.readPump: Received raw message: %s", string(message))
-
-		var msg Message
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("[BACKEND-6] Client.readPump: Error unmarshaling message: %v", err)
-			continue
+		// Binary frames carry MessagePack; text frames are always JSON
+		var dec codec = jsonCodec{}
+		if frameType == websocket.BinaryMessage {
+			dec = msgpackCodec{}
 		}
+		c.receive(dec, message)
+	}
+}
 
-		log.Printf("[BACKEND-7] Client.readPump: Parsed message type=%s, payload=%v", msg.Type, msg.Payload)
+// receive decodes one message from the client and handles it, here or on
+// the instance owning the client's game. Every transport feeds it.
+func (c *Client) receive(dec codec, message []byte) {
+	if _, ok := dec.(jsonCodec); ok {
+		log.Printf("[BACKEND-6] Client.receive: Received raw message: %s", string(message))
+	}
 
-		switch msg.Type {
-		case "join":
-			log.Printf("[BACKEND-8] Client.readPump: Processing 'join' message")
-			if usernameStr, ok := msg.Payload.(string); ok {
-				c.username = usernameStr
-				c.hub.handleNewPlayer(c, "friend") // default mode
-			} else if payloadObj, ok := msg.Payload.(map[string]interface{}); ok {
-				if username, ok := payloadObj["username"].(string); ok {
-					c.username = username
-					gameMode := "friend"
-					if mode, ok := payloadObj["gameMode"].(string); ok {
-						gameMode = mode
-					}
-					log.Printf("[BACKEND-9] Client.readPump: Player %s joining with mode: %s", username, gameMode)
-					c.hub.handleNewPlayer(c, gameMode)
-				}
-			}
+	var msg rawMessage
+	if err := dec.decode(message, &msg); err != nil || msg.Type == "" {
+		log.Printf("[BACKEND-6] Client.receive: Error unmarshaling message: %v", err)
+		c.reply(msg.RequestID, newProtocolError(ErrCodeInvalidMessage, "message is not a valid envelope"))
+		return
+	}
+	if limiter := c.limiter.Load(); limiter != nil && !limiter.Allow() {
+		c.reply(msg.RequestID, newProtocolError(ErrCodeRateLimited, "slow down: at most %d messages per second", botMessageRate))
+		return
+	}
 
-		case "move":
-			if move, ok := msg.Payload.(map[string]interface{}); ok {
-				if column, ok := move["column"].(float64); ok {
-					log.Printf("[BACKEND-9] Client.readPump: Player %s move column %d", c.username, int(column))
-					c.hub.handleMove(c, int(column))
-				}
-			}
+	log.Printf("[BACKEND-7] Client.receive: Parsed message type=%s, payload=%s", msg.Type, string(msg.Payload))
 
-		case "cancelWaiting":
-			c.hub.mu.Lock()
-			if c.hub.waitingPlayer == c {
-				c.hub.waitingPlayer = nil
-				msg := Message{
-					Type: "waitingCancelled",
-					Payload: map[string]interface{}{
-						"message": "Waiting cancelled",
-					},
-				}
-				if data, err := json.Marshal(msg); err == nil {
-					c.send <- data
-				}
+	if c.forwardToOwner(msg) {
+		return
+	}
+	c.handleMessage(msg)
+}
+
+// handleMessage dispatches a single client message to the hub and replies
+// with an ack or error when the request carried a requestId. Failures are
+// always reported. Messages forwarded from other instances enter here too.
+func (c *Client) handleMessage(msg rawMessage) {
+	c.reply(msg.RequestID, c.dispatch(msg))
+}
+
+// reply sends the outcome of a request back to the client
+func (c *Client) reply(requestID string, perr *ProtocolError) {
+	if perr != nil {
+		log.Printf("[BACKEND-6] Request from %s failed: %v", c.username, perr)
+		c.sendError(requestID, perr)
+		return
+	}
+	if requestID != "" {
+		c.sendEnvelope(Envelope{Type: MsgAck, RequestID: requestID})
+	}
+}
+
+// dispatch decodes the payload of msg and hands it to the matching handler
+func (c *Client) dispatch(msg rawMessage) *ProtocolError {
+	switch msg.Type {
+	case MsgJoin:
+		log.Printf("[BACKEND-8] Client.readPump: Processing 'join' message")
+		join := JoinPayload{GameMode: "friend"}
+		var username string
+		if err := json.Unmarshal(msg.Payload, &username); err == nil {
+			// Version 1 clients send the bare username
+			join.Username = username
+		} else if perr := msg.decodePayload(&join); perr != nil {
+			return perr
+		}
+		version := join.ProtocolVersion
+		if version == 0 {
+			version = MinProtocolVersion
+		}
+		if version < MinProtocolVersion {
+			return newProtocolError(ErrCodeUnsupportedProtocol, "protocol version %d is not supported (minimum %d)", version, MinProtocolVersion)
+		}
+		if version > ProtocolVersion {
+			version = ProtocolVersion
+		}
+
+		c.hub.mu.Lock()
+		if join.Bot {
+			if perr := c.hub.authenticateBotUnsafe(c, join.Username, join.APIKey); perr != nil {
+				c.hub.mu.Unlock()
+				return perr
 			}
+			// A bot plays under its registered name or not at all
+			join.SuffixDuplicate = false
+		}
+		username, perr := c.hub.claimUsernameUnsafe(c, join.Username, join.SessionToken, join.SuffixDuplicate)
+		if perr != nil {
 			c.hub.mu.Unlock()
+			return perr
+		}
+		c.username = username
+		c.protocolVersion = version
+		// Clients learn a suffixed name from the welcome message
+		if join.ProtocolVersion != 0 || username != join.Username {
+			c.sendEnvelope(Envelope{
+				Type:      MsgWelcome,
+				RequestID: msg.RequestID,
+				Payload: WelcomePayload{
+					Username:           username,
+					ProtocolVersion:    version,
+					MinProtocolVersion: MinProtocolVersion,
+					MaxProtocolVersion: ProtocolVersion,
+				},
+			})
+		}
+		c.hub.mu.Unlock()
 
-		case "playAgain":
-			c.hub.handlePlayAgain(c)
+		log.Printf("[BACKEND-9] Client.readPump: Player %s joining with mode: %s (protocol v%d)", username, join.GameMode, version)
+		c.hub.handleNewPlayer(c, join.GameMode, join.SessionToken)
+		return nil
 
-		case "exitGame":
-			c.hub.handleExit(c)
+	case MsgMove:
+		var move MovePayload
+		if perr := msg.decodePayload(&move); perr != nil {
+			return perr
 		}
+		if move.Column == nil {
+			return newProtocolError(ErrCodeInvalidMessage, "column is required")
+		}
+		log.Printf("[BACKEND-9] Client.readPump: Player %s move column %d", c.username, *move.Column)
+		if move.MoveNumber != nil {
+			return c.hub.handleMoveAt(c, *move.Column, *move.MoveNumber)
+		}
+		return c.hub.handleMove(c, *move.Column)
+
+	case MsgCancelWaiting:
+		return c.hub.handleCancelWaiting(c)
+
+	case MsgResume:
+		var resume ResumePayload
+		if perr := msg.decodePayload(&resume); perr != nil {
+			return perr
+		}
+		return c.hub.handleResume(c, resume.SessionToken)
+
+	case MsgChallenge:
+		req := ChallengePayload{Settings: game.DefaultSettings()}
+		if perr := msg.decodePayload(&req); perr != nil {
+			return perr
+		}
+		return c.hub.handleChallenge(c, req)
+
+	case MsgAcceptChallenge:
+		var resp ChallengeResponsePayload
+		if perr := msg.decodePayload(&resp); perr != nil {
+			return perr
+		}
+		return c.hub.handleAcceptChallenge(c, resp.ChallengeID)
+
+	case MsgDeclineChallenge:
+		var resp ChallengeResponsePayload
+		if perr := msg.decodePayload(&resp); perr != nil {
+			return perr
+		}
+		return c.hub.handleDeclineChallenge(c, resp.ChallengeID)
+
+	case MsgResync:
+		var resync ResyncPayload
+		if perr := msg.decodePayload(&resync); perr != nil {
+			return perr
+		}
+		return c.hub.handleResync(c, resync)
+
+	case MsgSubscribePresence:
+		return c.hub.subscribePresence(c)
+
+	case MsgUnsubscribePresence:
+		return c.hub.unsubscribePresence(c)
+
+	case MsgSpectate:
+		var spectate SpectatePayload
+		if perr := msg.decodePayload(&spectate); perr != nil {
+			return perr
+		}
+		return c.hub.handleSpectate(c, spectate.GameID)
+
+	case MsgStopSpectating:
+		return c.hub.handleStopSpectating(c)
+
+	case MsgPlayAgain:
+		var p PlayAgainPayload
+		if len(msg.Payload) > 0 && string(msg.Payload) != "null" {
+			if perr := msg.decodePayload(&p); perr != nil {
+				return perr
+			}
+		}
+		return c.hub.handlePlayAgain(c, p.BestOf)
+
+	case MsgExitGame:
+		return c.hub.handleExit(c)
 	}
+	return newProtocolError(ErrCodeUnknownType, "unknown message type %q", msg.Type)
 }
 
 // writePump continuously writes messages from the hub to the WebSocket connection
 func (c *Client) writePump() {
 	ticker := time.NewTicker(pingPeriod)
+	out := c.send.Load()
+	if out == nil {
+		// Disconnected before the pump got to run
+		ticker.Stop()
+		c.conn.Close()
+		return
+	}
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -197,19 +343,30 @@ func (c *Client) writePump() {
 
 	for {
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
+		case <-out.wake:
+			for {
+				message, ok := out.pop()
+				if !ok {
+					break
+				}
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				w, err := c.conn.NextWriter(c.codec.frameType())
+				if err != nil {
+					return
+				}
+				w.Write(message)
+				if err := w.Close(); err != nil {
+					return
+				}
 			}
 
-			w, err := c.conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-			if err := w.Close(); err != nil {
+			if closed, reason := out.done(); closed {
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				closeMsg := []byte{}
+				if reason != "" {
+					closeMsg = websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+				}
+				c.conn.WriteMessage(websocket.CloseMessage, closeMsg)
 				return
 			}
 
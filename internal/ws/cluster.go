@@ -0,0 +1,293 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/connect4/backend/internal/backplane"
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+)
+
+// forwardedTypes are the message types that act on a game and must be
+// handled by the instance that owns it
+var forwardedTypes = map[string]bool{
+	MsgMove:      true,
+	MsgPlayAgain: true,
+	MsgExitGame:  true,
+	MsgResync:    true,
+}
+
+// SetBackplane connects the hub to other instances (optional). Without a
+// backplane the hub keeps all state in process, as a single instance.
+func (h *Hub) SetBackplane(bp backplane.Backplane, instanceID string) error {
+	h.mu.Lock()
+	h.backplane = bp
+	h.instanceID = instanceID
+	h.mu.Unlock()
+
+	log.Printf("[BACKEND-CLUSTER] Hub joined backplane as instance %s", instanceID)
+	return bp.Subscribe(instanceID, h.handleEnvelope)
+}
+
+// handleEnvelope processes an envelope sent by another instance
+func (h *Hub) handleEnvelope(env backplane.Envelope) {
+	switch env.Kind {
+	case backplane.KindDeliver:
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		client := h.findLocalClientUnsafe(env.Username)
+		if client == nil {
+			return
+		}
+		// The owner's messages tell us which game the player is in now
+		if env.GameID != "" && client.gameID != env.GameID {
+			h.stopWaitingUnsafe(client)
+			client.gameID = env.GameID
+			client.gameOwner = env.From
+			h.refreshPresenceUnsafe(client)
+		}
+		var msg rawMessage
+		if err := json.Unmarshal(env.Data, &msg); err != nil {
+			log.Printf("[BACKEND-CLUSTER] Invalid delivered message from %s: %v", env.From, err)
+			return
+		}
+		client.sendEnvelope(msg.envelope())
+
+	case backplane.KindForward:
+		var msg rawMessage
+		if err := json.Unmarshal(env.Data, &msg); err != nil {
+			log.Printf("[BACKEND-CLUSTER] Invalid forwarded message from %s: %v", env.From, err)
+			return
+		}
+		h.mu.Lock()
+		proxy := h.findProxyUnsafe(env.Username, env.From)
+		h.mu.Unlock()
+		if proxy == nil {
+			return
+		}
+		proxy.handleMessage(msg)
+
+	case backplane.KindDisconnect:
+		h.mu.Lock()
+		proxy := h.findProxyUnsafe(env.Username, env.From)
+		h.mu.Unlock()
+		if proxy != nil {
+			h.handlePlayerDisconnect(proxy)
+		}
+
+	case backplane.KindBroadcast:
+		var msg rawMessage
+		if err := json.Unmarshal(env.Data, &msg); err != nil {
+			log.Printf("[BACKEND-CLUSTER] Invalid broadcast from %s: %v", env.From, err)
+			return
+		}
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for client := range h.clients {
+			if client.remoteInstance == "" {
+				client.sendEnvelope(msg.envelope())
+			}
+		}
+
+	case backplane.KindPresence:
+		h.deliverPresence(env)
+	}
+}
+
+// forwardToOwner sends a game message to the instance that owns the client's
+// game. It returns false if the message should be handled locally.
+func (c *Client) forwardToOwner(msg rawMessage) bool {
+	h := c.hub
+	bp := h.backplane
+	if bp == nil || !forwardedTypes[msg.Type] {
+		return false
+	}
+	h.mu.Lock()
+	owner := c.gameOwner
+	h.mu.Unlock()
+	if owner == "" {
+		return false
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return true
+	}
+	if err := bp.Publish(owner, backplane.Envelope{
+		Kind:     backplane.KindForward,
+		From:     h.instanceID,
+		Username: c.username,
+		Data:     data,
+	}); err != nil {
+		log.Printf("[BACKEND-CLUSTER] Failed to forward %s from %s to %s: %v", msg.Type, c.username, owner, err)
+	}
+	return true
+}
+
+// sendRemote publishes env for a player whose socket lives on another
+// instance. The game the envelope belongs to tells that instance which game
+// the player is in.
+func (c *Client) sendRemote(env Envelope) bool {
+	h := c.hub
+	if h.backplane == nil {
+		return false
+	}
+	data, err := json.Marshal(env)
+	if err != nil {
+		return false
+	}
+	err = h.backplane.Publish(c.remoteInstance, backplane.Envelope{
+		Kind:     backplane.KindDeliver,
+		From:     h.instanceID,
+		Username: c.username,
+		GameID:   env.GameID,
+		Data:     data,
+	})
+	if err != nil {
+		log.Printf("[BACKEND-CLUSTER] Failed to deliver to %s on %s: %v", c.username, c.remoteInstance, err)
+		return false
+	}
+	return true
+}
+
+// broadcastUnsafe sends env to every connected player on every instance.
+// Must be called with h.mu held.
+func (h *Hub) broadcastUnsafe(env Envelope) {
+	for client := range h.clients {
+		if client.remoteInstance == "" {
+			client.sendEnvelope(env)
+		}
+	}
+	if h.backplane != nil {
+		data, err := json.Marshal(env)
+		if err != nil {
+			return
+		}
+		h.backplane.PublishAll(backplane.Envelope{
+			Kind: backplane.KindBroadcast,
+			From: h.instanceID,
+			Data: data,
+		})
+	}
+}
+
+// matchRemoteUnsafe pairs a joining client with a player waiting on another
+// instance whose rating is close enough. This instance becomes the owner of
+// the game. Must be called with h.mu held.
+func (h *Hub) matchRemoteUnsafe(client *Client, now time.Time) bool {
+	if h.backplane == nil {
+		return false
+	}
+	entry, ok := h.backplane.TakeWaiting(h.instanceID, func(entry backplane.WaitingEntry) bool {
+		return h.acceptsUnsafe(entry.Rating, client.rating, entry.QueuedAt, now)
+	})
+	if !ok {
+		return false
+	}
+	h.startRemoteGameUnsafe(entry, client)
+	return true
+}
+
+// startRemoteGameUnsafe seats a player taken from the shared queue against a
+// local client. Must be called with h.mu held.
+func (h *Hub) startRemoteGameUnsafe(entry backplane.WaitingEntry, client *Client) {
+	log.Printf("[BACKEND-CLUSTER] Matching %s with %s waiting on instance %s", client.username, entry.Username, entry.InstanceID)
+	proxy := h.findProxyUnsafe(entry.Username, entry.InstanceID)
+	if proxy == nil {
+		proxy = &Client{
+			hub:            h,
+			username:       entry.Username,
+			remoteInstance: entry.InstanceID,
+		}
+		h.clients[proxy] = true
+	}
+	proxy.disconnectedAt = nil
+	h.createGame(proxy, client, game.DefaultSettings(), config.ModeFriend, nil, "")
+}
+
+// enqueueWaitingUnsafe advertises a local waiting player to other instances
+func (h *Hub) enqueueWaitingUnsafe(client *Client) {
+	if h.backplane == nil {
+		return
+	}
+	h.backplane.EnqueueWaiting(backplane.WaitingEntry{
+		Username:   client.username,
+		InstanceID: h.instanceID,
+		Rating:     client.rating,
+		QueuedAt:   client.waitingSince,
+	})
+}
+
+// dequeueWaitingUnsafe withdraws a local waiting player from the shared queue.
+// It returns false if another instance has already taken the player.
+func (h *Hub) dequeueWaitingUnsafe(client *Client) bool {
+	if h.backplane == nil {
+		return true
+	}
+	return h.backplane.RemoveWaiting(client.username, h.instanceID)
+}
+
+// leaveRemoteGameUnsafe tells the owner of the client's game that the client
+// is gone, so the owner can run its disconnect handling for the proxy
+func (h *Hub) leaveRemoteGameUnsafe(client *Client) {
+	if h.backplane == nil || client.gameOwner == "" {
+		return
+	}
+	h.backplane.Publish(client.gameOwner, backplane.Envelope{
+		Kind:     backplane.KindDisconnect,
+		From:     h.instanceID,
+		Username: client.username,
+	})
+	client.gameOwner = ""
+	client.gameID = ""
+}
+
+// removeGameUnsafe drops a game from the registry, releases its ownership
+// and forgets its stored snapshot. Spectators stop watching it. Must be
+// called with g.mu and h.mu held.
+func (h *Hub) removeGameUnsafe(g *WSGame) {
+	gameID := g.game.ID
+	if h.activeGames[gameID] == g {
+		delete(h.activeGames, gameID)
+	}
+	h.forgetGameUnsafe(gameID)
+	if h.backplane != nil {
+		h.backplane.ReleaseGame(gameID)
+	}
+	if g.closed {
+		return
+	}
+	g.closed = true
+
+	g.watchMu.Lock()
+	spectators := g.spectators
+	g.spectators = nil
+	g.watchMu.Unlock()
+	for spectator := range spectators {
+		spectator.spectating = ""
+		h.refreshPresenceUnsafe(spectator)
+	}
+	h.refreshPresenceUnsafe(g.player1Client)
+	h.refreshPresenceUnsafe(g.player2Client)
+}
+
+// findLocalClientUnsafe finds a client whose socket is on this instance
+func (h *Hub) findLocalClientUnsafe(username string) *Client {
+	for client := range h.clients {
+		if client.username == username && client.remoteInstance == "" && client.disconnectedAt == nil {
+			return client
+		}
+	}
+	return nil
+}
+
+// findProxyUnsafe finds the local stand-in for a player on another instance
+func (h *Hub) findProxyUnsafe(username, instanceID string) *Client {
+	for client := range h.clients {
+		if client.username == username && client.remoteInstance == instanceID {
+			return client
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/connect4/backend/internal/backplane"
+	"github.com/connect4/backend/internal/config"
+)
+
+// newClusterHubs starts two hubs sharing one in-memory backplane
+func newClusterHubs(t *testing.T) (*Hub, *Hub) {
+	bp := backplane.NewMemory()
+	hubs := make([]*Hub, 2)
+	for i, id := range []string{"a", "b"} {
+		h := NewHub()
+		policies := config.DefaultPolicies()
+		for mode, policy := range policies.Modes {
+			policy.BotFallback = false
+			policies.Modes[mode] = policy
+		}
+		h.SetPolicies(policies)
+		if err := h.SetBackplane(bp, id); err != nil {
+			t.Fatal(err)
+		}
+		go h.Run()
+		hubs[i] = h
+	}
+	return hubs[0], hubs[1]
+}
+
+// TestClusterMatchmaking pairs players connected to different instances
+// and plays moves from both sides through the owner of the game
+func TestClusterMatchmaking(t *testing.T) {
+	a, b := newClusterHubs(t)
+	sockets := newSocketServer(t)
+
+	alice := sockets.mustClient(t, a, "alice")
+	a.handleNewPlayer(alice, "friend", "")
+	bob := sockets.mustClient(t, b, "bob")
+	b.handleNewPlayer(bob, "friend", "")
+
+	// b took alice from the shared queue and owns the game
+	gameID := currentGame(b, bob)
+	if gameID == "" {
+		t.Fatal("bob was not matched with the player waiting on the other instance")
+	}
+	waitFor(t, "alice to learn about her game", func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return alice.gameID == gameID && alice.gameOwner == "b"
+	})
+	a.mu.Lock()
+	waiting := a.isWaitingUnsafe(alice)
+	a.mu.Unlock()
+	if waiting {
+		t.Error("alice is still waiting on instance a")
+	}
+
+	// The waiting player takes the first seat and moves first
+	payload, _ := json.Marshal(MovePayload{Column: intPtr(3)})
+	if !alice.forwardToOwner(rawMessage{Type: MsgMove, Payload: payload}) {
+		t.Fatal("alice's move was not forwarded to the owner")
+	}
+	b.mu.Lock()
+	g := b.activeGames[gameID]
+	b.mu.Unlock()
+	moves := func() int {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.game.Board.MoveCount()
+	}
+	waitFor(t, "the forwarded move", func() bool { return moves() == 1 })
+	if perr := b.handleMove(bob, 4); perr != nil {
+		t.Fatal(perr)
+	}
+	if got := moves(); got != 2 {
+		t.Errorf("%d moves played, want 2", got)
+	}
+}
+
+// TestClusterDequeueOnChallenge has a queued player accept a challenge. The
+// shared queue must forget them, or another instance could seat them in a
+// second game.
+func TestClusterDequeueOnChallenge(t *testing.T) {
+	a, b := newClusterHubs(t)
+	sockets := newSocketServer(t)
+
+	carol := sockets.mustClient(t, a, "carol")
+	a.handleNewPlayer(carol, "friend", "")
+	dave := sockets.mustClient(t, a, "dave")
+	a.handleNewPlayer(dave, "lobby", "")
+	if perr := a.handleChallenge(dave, ChallengePayload{Username: "carol"}); perr != nil {
+		t.Fatal(perr)
+	}
+	a.mu.Lock()
+	var challengeID string
+	for id := range a.challenges {
+		challengeID = id
+	}
+	a.mu.Unlock()
+	if perr := a.handleAcceptChallenge(carol, challengeID); perr != nil {
+		t.Fatal(perr)
+	}
+	carolGame := currentGame(a, carol)
+	if carolGame == "" {
+		t.Fatal("accepting the challenge did not start a game")
+	}
+
+	erin := sockets.mustClient(t, b, "erin")
+	b.handleNewPlayer(erin, "friend", "")
+	if id := currentGame(b, erin); id != "" {
+		t.Fatalf("erin was matched into game %s with a player who is already playing", id)
+	}
+	b.mu.Lock()
+	waiting := b.isWaitingUnsafe(erin)
+	b.mu.Unlock()
+	if !waiting {
+		t.Error("erin is not waiting for an opponent")
+	}
+	if id := currentGame(a, carol); id != carolGame {
+		t.Errorf("carol moved from game %s to %s", carolGame, id)
+	}
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,154 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// WebSocket subprotocols a client can ask for in Sec-WebSocket-Protocol.
+// Connections that negotiate none use JSON.
+const (
+	SubprotocolJSON    = "connect4.json"
+	SubprotocolMsgpack = "connect4.msgpack"
+)
+
+// codec encodes server envelopes and decodes client messages for one
+// connection. Both codecs carry the same message catalogue with the same
+// field names; only the wire encoding differs.
+type codec interface {
+	encode(env Envelope) ([]byte, error)
+	decode(data []byte, msg *rawMessage) error
+	frameType() int
+}
+
+// codecForSubprotocol returns the codec for a negotiated subprotocol
+func codecForSubprotocol(subprotocol string) codec {
+	if subprotocol == SubprotocolMsgpack {
+		return msgpackCodec{}
+	}
+	return jsonCodec{}
+}
+
+// jsonCodec sends text frames holding JSON
+type jsonCodec struct{}
+
+func (jsonCodec) encode(env Envelope) ([]byte, error) {
+	return json.Marshal(env)
+}
+
+func (jsonCodec) decode(data []byte, msg *rawMessage) error {
+	return json.Unmarshal(data, msg)
+}
+
+func (jsonCodec) frameType() int {
+	return websocket.TextMessage
+}
+
+// msgpackCodec sends binary frames holding MessagePack. Messages are encoded
+// straight from their Go types using the JSON struct tags, so field names and
+// omitempty rules match the JSON encoding. Timestamps use the MessagePack
+// timestamp extension instead of RFC 3339 strings.
+type msgpackCodec struct{}
+
+// msgpackMessage is a client message as it arrives in MessagePack. The
+// payload is passed on as JSON, which is what the handlers decode.
+type msgpackMessage struct {
+	Type      string      `json:"type"`
+	RequestID string      `json:"requestId,omitempty"`
+	GameID    string      `json:"gameId,omitempty"`
+	EventSeq  uint64      `json:"eventSeq,omitempty"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+func (msgpackCodec) encode(env Envelope) ([]byte, error) {
+	// Messages relayed from another instance carry their payload as JSON
+	if raw, ok := env.Payload.(json.RawMessage); ok {
+		payload, err := decodeJSONNumbers(raw)
+		if err != nil {
+			return nil, err
+		}
+		env.Payload = payload
+	}
+
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	if err := enc.Encode(env); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) decode(data []byte, msg *rawMessage) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+	if !msgpcode.IsFixedMap(code) && code != msgpcode.Map16 && code != msgpcode.Map32 {
+		return fmt.Errorf("message is not a map")
+	}
+	var m msgpackMessage
+	if err := dec.Decode(&m); err != nil {
+		return err
+	}
+
+	*msg = rawMessage{
+		Type:      m.Type,
+		RequestID: m.RequestID,
+		GameID:    m.GameID,
+		EventSeq:  m.EventSeq,
+	}
+	if m.Payload != nil {
+		payload, err := json.Marshal(m.Payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = payload
+	}
+	return nil
+}
+
+func (msgpackCodec) frameType() int {
+	return websocket.BinaryMessage
+}
+
+// decodeJSONNumbers decodes a JSON document, keeping integers apart from
+// floats so boards and counters encode as single-byte MessagePack ints
+func decodeJSONNumbers(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return compactNumbers(v), nil
+}
+
+// compactNumbers replaces JSON numbers with integers where possible
+func compactNumbers(v interface{}) interface{} {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = compactNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = compactNumbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,207 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/tournament"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// TestMsgpackServerMessages encodes one message of every server type with
+// both codecs. Decoded, the two must hold the same fields and values.
+func TestMsgpackServerMessages(t *testing.T) {
+	g, err := game.NewGame(nil, game.Player{Username: "red"}, game.Player{Username: "yellow", IsBot: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.MakeMove(3); err != nil {
+		t.Fatal(err)
+	}
+	winner := "red"
+	you := &SeatPayload{Seat: 1, Color: game.ColorRed, MovesFirst: true}
+	series := &SeriesScore{SeriesID: "s1", BestOf: 3, Game: 2, Score: map[string]int{"red": 1, "yellow": 0}}
+	challenge := &Challenge{
+		ID:        "c1",
+		From:      "red",
+		To:        "yellow",
+		Settings:  game.Settings{Variant: game.VariantStandard, TimeControl: &game.TimeControl{InitialSeconds: 60, IncrementSeconds: 1}},
+		ExpiresAt: time.Date(2026, 10, 18, 12, 30, 0, 500, time.UTC),
+	}
+
+	messages := []Envelope{
+		{Type: MsgWelcome, Seq: 1, Payload: WelcomePayload{Username: "red", ProtocolVersion: 2, MinProtocolVersion: 1, MaxProtocolVersion: 2}},
+		{Type: MsgAck, Seq: 2, RequestID: "r1"},
+		{Type: MsgError, RequestID: "r2", Payload: newProtocolError(ErrCodeColumnFull, "column 3 is full")},
+		{Type: MsgGameStart, GameID: g.ID, EventSeq: 1, Payload: GameStartPayload{GameState: g.GetState(), SessionToken: "token", Series: series, You: you}},
+		{Type: MsgGameState, GameID: g.ID, EventSeq: 2, Payload: GameStatePayload{GameState: g.GetState(), You: you}},
+		{Type: MsgGameState, Payload: WaitingPayload{Status: game.StatusWaiting, Board: [][]int{{0, 0}, {1, 2}}, CurrentTurn: 1}},
+		{Type: MsgGameFinished, GameID: g.ID, Payload: GameFinishedPayload{GameID: g.ID, Winner: &winner, Reason: game.EndReasonTimeout, Series: series}},
+		{Type: MsgGameFinished, GameID: g.ID, Payload: GameFinishedPayload{GameID: g.ID, IsDraw: true}},
+		{Type: MsgPlayAgainUpdate, Payload: PlayAgainUpdatePayload{PlayAgainRequests: []string{"red"}, BestOf: 5}},
+		{Type: MsgOpponentExited, Payload: OpponentExitedPayload{GameID: g.ID, Message: "gone"}},
+		{Type: MsgWaitingCancelled, Payload: WaitingCancelledPayload{Message: "Waiting cancelled"}},
+		{Type: MsgLeaderboardUpdate, Payload: LeaderboardUpdatePayload{GameID: g.ID, Winner: &winner, WinnerIsBot: true}},
+		{Type: MsgChallengeReceived, Payload: challenge},
+		{Type: MsgChallengeSent, Payload: challenge},
+		{Type: MsgChallengeDeclined, Payload: challenge},
+		{Type: MsgChallengeExpired, Payload: challenge},
+		{Type: MsgChallengeCancel, Payload: challenge},
+		{Type: MsgResynced, GameID: g.ID, EventSeq: 1 << 40, Payload: ResyncedPayload{Replayed: 3}},
+		{Type: MsgTournamentRound, Payload: tournament.RoundNotice{TournamentID: "t1", Name: "cup", Round: 2, Opponent: "yellow", MovesFirst: true}},
+		{Type: MsgPresenceSnapshot, Payload: PresenceSnapshotPayload{Users: []ActiveUser{{Username: "red", Status: PresencePlaying}}}},
+		{Type: MsgPresence, Payload: PresenceDeltaPayload{Event: PresenceLeave, Username: "yellow"}},
+		{Type: MsgAnnouncement, Payload: AnnouncementPayload{Message: "restarting soon"}},
+		{Type: MsgAdminResult, RequestID: "r3", Payload: map[string]int{"kicked": 2}},
+		// Relayed from the instance that owns the game
+		{Type: MsgGameState, Payload: json.RawMessage(`{"board":[[0,1]],"currentTurn":2,"rating":1512.5}`)},
+	}
+
+	for _, env := range messages {
+		js, err := jsonCodec{}.encode(env)
+		if err != nil {
+			t.Fatalf("%s: json: %v", env.Type, err)
+		}
+		var want interface{}
+		if err := json.Unmarshal(js, &want); err != nil {
+			t.Fatal(err)
+		}
+
+		mp, err := msgpackCodec{}.encode(env)
+		if err != nil {
+			t.Fatalf("%s: msgpack: %v", env.Type, err)
+		}
+		var got interface{}
+		if err := msgpack.Unmarshal(mp, &got); err != nil {
+			t.Fatalf("%s: decoding msgpack: %v", env.Type, err)
+		}
+
+		if got := normalizeMsgpack(got); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\nmsgpack %v\njson    %v", env.Type, got, want)
+		}
+	}
+}
+
+// normalizeMsgpack turns decoded MessagePack into what the JSON decoder
+// produces for the same message
+func normalizeMsgpack(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = normalizeMsgpack(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = normalizeMsgpack(e)
+		}
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case int8:
+		return float64(t)
+	case int16:
+		return float64(t)
+	case int32:
+		return float64(t)
+	case int64:
+		return float64(t)
+	case uint8:
+		return float64(t)
+	case uint16:
+		return float64(t)
+	case uint32:
+		return float64(t)
+	case uint64:
+		return float64(t)
+	case float32:
+		return float64(t)
+	}
+	return v
+}
+
+// TestMsgpackClientMessages decodes one message of every client type sent
+// in MessagePack. It must read the same as the message sent in JSON.
+func TestMsgpackClientMessages(t *testing.T) {
+	messages := []map[string]interface{}{
+		{"type": MsgJoin, "requestId": "r1", "payload": map[string]interface{}{"username": "red", "gameMode": "friend", "protocolVersion": 2, "suffixDuplicate": true}},
+		{"type": MsgJoin, "payload": "red"},
+		{"type": MsgMove, "requestId": "r2", "payload": map[string]interface{}{"column": 3, "moveNumber": 7}},
+		{"type": MsgCancelWaiting},
+		{"type": MsgResume, "payload": map[string]interface{}{"sessionToken": "token"}},
+		{"type": MsgChallenge, "payload": map[string]interface{}{"username": "yellow", "settings": map[string]interface{}{"variant": "standard", "rated": true, "clock": map[string]interface{}{"initialSeconds": 60, "incrementSeconds": 1}}}},
+		{"type": MsgAcceptChallenge, "payload": map[string]interface{}{"challengeId": "c1"}},
+		{"type": MsgDeclineChallenge, "payload": map[string]interface{}{"challengeId": "c1"}},
+		{"type": MsgPlayAgain, "payload": map[string]interface{}{"bestOf": 3}},
+		{"type": MsgExitGame, "gameId": "g1"},
+		{"type": MsgResync, "gameId": "g1", "eventSeq": 12, "payload": map[string]interface{}{"gameId": "g1", "lastEventSeq": uint64(1 << 40)}},
+		{"type": MsgSubscribePresence},
+		{"type": MsgUnsubscribePresence},
+		{"type": MsgSpectate, "payload": map[string]interface{}{"gameId": "g1"}},
+		{"type": MsgStopSpectating, "payload": nil},
+	}
+
+	for _, m := range messages {
+		js, err := json.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want rawMessage
+		if err := (jsonCodec{}).decode(js, &want); err != nil {
+			t.Fatal(err)
+		}
+		mp, err := msgpack.Marshal(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got rawMessage
+		if err := (msgpackCodec{}).decode(mp, &got); err != nil {
+			t.Fatalf("%s: %v", m["type"], err)
+		}
+
+		if got.Type != want.Type || got.RequestID != want.RequestID || got.GameID != want.GameID || got.EventSeq != want.EventSeq {
+			t.Errorf("%s: envelope %+v, want %+v", m["type"], got, want)
+		}
+		var gotPayload, wantPayload interface{}
+		if len(want.Payload) > 0 {
+			json.Unmarshal(want.Payload, &wantPayload)
+		}
+		if len(got.Payload) > 0 {
+			if err := json.Unmarshal(got.Payload, &gotPayload); err != nil {
+				t.Fatalf("%s: payload %s: %v", m["type"], got.Payload, err)
+			}
+		}
+		if !reflect.DeepEqual(gotPayload, wantPayload) {
+			t.Errorf("%s: payload %s, want %s", m["type"], got.Payload, want.Payload)
+		}
+	}
+
+	// The handlers read the payload as they would from JSON
+	mp, _ := msgpack.Marshal(map[string]interface{}{"type": MsgMove, "payload": map[string]interface{}{"column": 6}})
+	var msg rawMessage
+	if err := (msgpackCodec{}).decode(mp, &msg); err != nil {
+		t.Fatal(err)
+	}
+	var move MovePayload
+	if perr := msg.decodePayload(&move); perr != nil || move.Column == nil || *move.Column != 6 {
+		t.Errorf("move payload decoded as %+v, %v", move, perr)
+	}
+}
+
+func TestMsgpackRejectsNonMaps(t *testing.T) {
+	for _, v := range []interface{}{"move", 3, []interface{}{"move", 3}, nil} {
+		data, err := msgpack.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg rawMessage
+		if err := (msgpackCodec{}).decode(data, &msg); err == nil {
+			t.Errorf("%v decoded as %+v", v, msg)
+		}
+	}
+	var msg rawMessage
+	if err := (msgpackCodec{}).decode([]byte{0x81, 0xa4}, &msg); err == nil {
+		t.Error("a truncated message decoded")
+	}
+}
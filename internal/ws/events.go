@@ -0,0 +1,72 @@
+package ws
+
+import "log"
+
+// eventLogSize is how many recent events each game keeps for replay. Clients
+// that fall further behind get a full snapshot instead.
+const eventLogSize = 64
+
+// publishGameEventLocked numbers env with the game's next event sequence,
+// records it for replay and sends it to both players and the spectators.
+// Must be called with g.mu held.
+func (h *Hub) publishGameEventLocked(g *WSGame, env Envelope) {
+	g.eventSeq++
+	env.GameID = g.game.ID
+	env.EventSeq = g.eventSeq
+
+	g.events = append(g.events, env)
+	if len(g.events) > eventLogSize {
+		g.events = g.events[len(g.events)-eventLogSize:]
+	}
+
+	g.player1Client.sendEnvelope(env)
+	g.player2Client.sendEnvelope(env)
+	g.watchMu.Lock()
+	defer g.watchMu.Unlock()
+	for spectator := range g.spectators {
+		spectator.sendEnvelope(env)
+	}
+}
+
+// handleResync brings a client that missed messages back in line with its
+// game. A gap in the connection's seq numbers tells the client a message was
+// dropped; it then sends the last eventSeq it applied. Events after that are
+// replayed when still in the log, otherwise the current state is sent.
+func (h *Hub) handleResync(client *Client, req ResyncPayload) *ProtocolError {
+	g := h.lockGameOf(client)
+	if g == nil {
+		return newProtocolError(ErrCodeNoSuchGame, "you are not in a game")
+	}
+	defer g.mu.Unlock()
+	if req.GameID != "" && req.GameID != g.game.ID {
+		return newProtocolError(ErrCodeNoSuchGame, "game %s is not your current game", req.GameID)
+	}
+
+	replayed := 0
+	snapshot := true
+	if req.LastEventSeq <= g.eventSeq {
+		missing := g.eventSeq - req.LastEventSeq
+		if missing <= uint64(len(g.events)) {
+			for _, env := range g.events[uint64(len(g.events))-missing:] {
+				client.sendEnvelope(env)
+				replayed++
+			}
+			snapshot = false
+		}
+	}
+	if snapshot {
+		log.Printf("[BACKEND-RESYNC] %s is too far behind in game %s (had %d, now %d), sending snapshot", client.username, g.game.ID, req.LastEventSeq, g.eventSeq)
+		h.sendCurrentStateLocked(g, client)
+	}
+
+	client.sendEnvelope(Envelope{
+		Type:     MsgResynced,
+		GameID:   g.game.ID,
+		EventSeq: g.eventSeq,
+		Payload: ResyncedPayload{
+			Replayed: replayed,
+			Snapshot: snapshot,
+		},
+	})
+	return nil
+}
@@ -0,0 +1,142 @@
+package ws
+
+import "testing"
+
+// expectResynced waits for the end of a resync and returns its payload, the
+// event seq it brought the client to and the events replayed before it
+func expectResynced(t *testing.T, p *player, requestID string) (ResyncedPayload, uint64, []uint64) {
+	t.Helper()
+	p.mu.Lock()
+	from := p.next
+	p.mu.Unlock()
+	msg := p.expect(t, MsgResynced)
+	var replayed []uint64
+	p.mu.Lock()
+	for _, m := range p.msgs[from : p.next-1] {
+		replayed = append(replayed, m.EventSeq)
+	}
+	p.mu.Unlock()
+	var resynced ResyncedPayload
+	if perr := msg.decodePayload(&resynced); perr != nil {
+		t.Fatal(perr)
+	}
+	expectReply(t, p.peer, MsgAck, requestID)
+	return resynced, msg.EventSeq, replayed
+}
+
+// TestResyncReplaysMissedEvents numbers the events of a game and replays
+// those a client says it missed, in order
+func TestResyncReplaysMissedEvents(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	first, second, start, _ := joinFriendGame(t, srv, "alice", "bob")
+
+	for i, column := range []int{3, 4, 3} {
+		p := first
+		if i%2 == 1 {
+			p = second
+		}
+		p.send(t, MsgMove, "", map[string]int{"column": column})
+		for _, watcher := range []*player{first, second} {
+			msg := watcher.expect(t, MsgGameState)
+			if msg.EventSeq != uint64(i+1) || msg.GameID != start.ID {
+				t.Fatalf("move %d published as event %d of game %s", i+1, msg.EventSeq, msg.GameID)
+			}
+		}
+	}
+
+	second.send(t, MsgResync, "r1", ResyncPayload{GameID: start.ID, LastEventSeq: 1})
+	resynced, seq, replayed := expectResynced(t, second, "r1")
+	if resynced.Replayed != 2 || resynced.Snapshot || seq != 3 {
+		t.Errorf("resynced %+v at event %d, want 2 replayed at event 3", resynced, seq)
+	}
+	// The outbox may coalesce the replayed states, keeping the latest
+	if n := len(replayed); n == 0 || n > 2 || replayed[0] < 2 || replayed[n-1] != 3 {
+		t.Errorf("replayed events %v, want 2 and 3", replayed)
+	}
+
+	// A client that is up to date gets nothing but the confirmation
+	first.send(t, MsgResync, "r2", ResyncPayload{LastEventSeq: 3})
+	if resynced, seq, replayed := expectResynced(t, first, "r2"); resynced.Replayed != 0 || resynced.Snapshot || seq != 3 || len(replayed) != 0 {
+		t.Errorf("resynced %+v at event %d after %v, want nothing replayed at event 3", resynced, seq, replayed)
+	}
+
+	// One claiming events the game never had is sent the current state
+	first.send(t, MsgResync, "r3", ResyncPayload{LastEventSeq: 9})
+	msg := first.expect(t, MsgGameState)
+	var state GameStatePayload
+	if perr := msg.decodePayload(&state); perr != nil {
+		t.Fatal(perr)
+	}
+	if msg.EventSeq != 3 || state.You == nil || state.You.Seat != start.You.Seat || state.Board[len(state.Board)-2][3] != start.You.Seat {
+		t.Errorf("snapshot at event %d: %s", msg.EventSeq, msg.Payload)
+	}
+	if resynced, _, _ := expectResynced(t, first, "r3"); resynced.Replayed != 0 || !resynced.Snapshot {
+		t.Errorf("resynced %+v, want a snapshot", resynced)
+	}
+}
+
+// A client further behind than the log reaches gets a snapshot instead
+func TestResyncPastTheLog(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	first, _, start, _ := joinFriendGame(t, srv, "alice", "bob")
+	h.mu.Lock()
+	g := h.activeGames[start.ID]
+	h.mu.Unlock()
+
+	g.mu.Lock()
+	for i := 0; i < eventLogSize+2; i++ {
+		h.publishGameEventLocked(g, Envelope{Type: MsgPlayAgainUpdate, Payload: PlayAgainUpdatePayload{}})
+	}
+	logged, oldest := len(g.events), g.events[0].EventSeq
+	g.mu.Unlock()
+	if logged != eventLogSize || oldest != 3 {
+		t.Fatalf("the log holds %d events from %d, want %d from 3", logged, oldest, eventLogSize)
+	}
+	// Read past the events as they were published
+	for first.expect(t, MsgPlayAgainUpdate).EventSeq != eventLogSize+2 {
+	}
+
+	// The oldest event still logged can be replayed...
+	first.send(t, MsgResync, "r1", ResyncPayload{LastEventSeq: 2})
+	resynced, seq, replayed := expectResynced(t, first, "r1")
+	if resynced.Replayed != eventLogSize || resynced.Snapshot || seq != eventLogSize+2 {
+		t.Errorf("resynced %+v at event %d, want all %d logged events", resynced, seq, eventLogSize)
+	}
+	if len(replayed) != eventLogSize {
+		t.Fatalf("%d events replayed, want %d", len(replayed), eventLogSize)
+	}
+	for i, eventSeq := range replayed {
+		if eventSeq != uint64(i+3) {
+			t.Fatalf("replayed events %v, want 3 to %d in order", replayed, eventLogSize+2)
+		}
+	}
+	// ...one before it cannot
+	first.send(t, MsgResync, "r2", ResyncPayload{LastEventSeq: 1})
+	first.expect(t, MsgGameState)
+	if resynced, _, _ := expectResynced(t, first, "r2"); resynced.Replayed != 0 || !resynced.Snapshot {
+		t.Errorf("resynced %+v, want a snapshot", resynced)
+	}
+}
+
+func TestResyncRejected(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	first, _, _, _ := joinFriendGame(t, srv, "alice", "bob")
+	lobby := dialPlayer(t, srv)
+	joinLobby(t, lobby, "carol")
+
+	lobby.send(t, MsgResync, "r1", ResyncPayload{LastEventSeq: 0})
+	if code := lobby.expectError(t, "r1"); code != ErrCodeNoSuchGame {
+		t.Errorf("resync outside a game: %s, want %s", code, ErrCodeNoSuchGame)
+	}
+	first.send(t, MsgResync, "r2", ResyncPayload{GameID: "another-game", LastEventSeq: 0})
+	if code := first.expectError(t, "r2"); code != ErrCodeNoSuchGame {
+		t.Errorf("resync of another game: %s, want %s", code, ErrCodeNoSuchGame)
+	}
+	first.send(t, MsgResync, "r3", nil)
+	if code := first.expectError(t, "r3"); code != ErrCodeInvalidMessage {
+		t.Errorf("resync without a payload: %s, want %s", code, ErrCodeInvalidMessage)
+	}
+}
@@ -0,0 +1,293 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transports a client can be connected over, as reported to operators
+const (
+	TransportWebSocket = "websocket"
+	TransportHTTP      = "http"
+	TransportREST      = "rest"
+)
+
+const (
+	fallbackIdle = pongWait         // a connection nobody reads for this long is closed
+	longPollWait = 25 * time.Second // how long a poll waits for the first message
+)
+
+// fallbackConn is a player connection over plain HTTP for networks that
+// block WebSocket upgrades. Server messages are read from one stream or poll
+// at a time; client messages arrive as separate POSTs.
+type fallbackConn struct {
+	id     string
+	client *Client
+
+	mu     sync.Mutex
+	reader chan struct{} // closed to detach the current reader, nil if none
+	idle   *time.Timer
+	gone   bool
+}
+
+// FallbackConnectPayload answers POST /fallback/connect
+type FallbackConnectPayload struct {
+	ConnectionID string `json:"connectionId"`
+}
+
+// FallbackPollPayload answers GET /fallback/poll. Closed is set once the
+// server has ended the connection; the client must connect again.
+type FallbackPollPayload struct {
+	Messages []json.RawMessage `json:"messages"`
+	Closed   bool              `json:"closed,omitempty"`
+	Reason   string            `json:"reason,omitempty"`
+}
+
+// HandleFallback serves the HTTP fallback transport. Messages are the JSON
+// envelopes used on /ws.
+//
+//	POST /fallback/connect          open a connection, returns its ID
+//	GET  /fallback/events?conn=ID   server messages as Server-Sent Events
+//	GET  /fallback/poll?conn=ID     server messages by long polling
+//	POST /fallback/send?conn=ID     one client message; replies arrive on the stream
+//
+// Messages taken by a stream or poll that breaks before delivering them are
+// lost; clients send a resync after attaching again.
+func (h *Hub) HandleFallback(w http.ResponseWriter, r *http.Request) {
+	route := strings.TrimPrefix(r.URL.Path, "/fallback")
+	method := http.MethodGet
+	if route == "/connect" || route == "/send" {
+		method = http.MethodPost
+	}
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if route == "/connect" {
+		fc := h.openFallback()
+		log.Printf("[BACKEND-FALLBACK] Opened connection from %s", r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(FallbackConnectPayload{ConnectionID: fc.id})
+		return
+	}
+
+	h.mu.Lock()
+	fc := h.fallbackConns[r.URL.Query().Get("conn")]
+	h.mu.Unlock()
+	if fc == nil {
+		http.Error(w, "Unknown connection", http.StatusNotFound)
+		return
+	}
+
+	switch route {
+	case "/events":
+		fc.serveEvents(w, r)
+	case "/poll":
+		fc.servePoll(w, r)
+	case "/send":
+		fc.serveSend(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// openFallback registers a client for a new fallback connection
+func (h *Hub) openFallback() *fallbackConn {
+	fc := &fallbackConn{id: newSessionToken()}
+	fc.client = &Client{
+		hub:      h,
+		fallback: fc,
+		codec:    jsonCodec{},
+	}
+	fc.client.send.Store(newOutbox())
+	fc.idle = time.AfterFunc(fallbackIdle, fc.expire)
+
+	h.mu.Lock()
+	h.fallbackConns[fc.id] = fc
+	h.mu.Unlock()
+	h.register <- fc.client
+	return fc
+}
+
+// attach makes the caller the connection's only reader and returns the
+// queue to read along with a channel closed when another reader takes over
+func (fc *fallbackConn) attach() (*outbox, <-chan struct{}) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	out := fc.client.send.Load()
+	if fc.gone || out == nil {
+		return nil, nil
+	}
+	if fc.reader != nil {
+		close(fc.reader)
+	}
+	fc.reader = make(chan struct{})
+	fc.idle.Stop()
+	return out, fc.reader
+}
+
+// detach ends a read started by attach. The connection closes unless
+// someone reads again within fallbackIdle.
+func (fc *fallbackConn) detach(reader <-chan struct{}) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if fc.reader == reader {
+		fc.reader = nil
+		fc.idle.Reset(fallbackIdle)
+	}
+}
+
+// expire closes the connection if nobody has come back to read from it
+func (fc *fallbackConn) expire() {
+	fc.mu.Lock()
+	reading := fc.reader != nil
+	fc.mu.Unlock()
+	if !reading {
+		log.Printf("[BACKEND-FALLBACK] Connection for %s idle, closing", fc.client.username)
+		fc.close()
+	}
+}
+
+// close forgets the connection and disconnects its client, like a socket
+// closing
+func (fc *fallbackConn) close() {
+	fc.mu.Lock()
+	if fc.gone {
+		fc.mu.Unlock()
+		return
+	}
+	fc.gone = true
+	fc.idle.Stop()
+	fc.mu.Unlock()
+
+	h := fc.client.hub
+	h.mu.Lock()
+	delete(h.fallbackConns, fc.id)
+	h.mu.Unlock()
+	h.unregister <- fc.client
+}
+
+// serveEvents streams server messages as Server-Sent Events until the
+// request ends, another reader attaches or the connection closes
+func (fc *fallbackConn) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	out, detached := fc.attach()
+	if out == nil {
+		http.Error(w, "Connection closed", http.StatusGone)
+		return
+	}
+	defer fc.detach(detached)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	for {
+		for {
+			message, ok := out.pop()
+			if !ok {
+				break
+			}
+			// Envelopes are compact JSON, so one data line holds one
+			fmt.Fprintf(w, "data: %s\n\n", message)
+		}
+		if closed, reason := out.done(); closed {
+			fmt.Fprintf(w, "event: close\ndata: %s\n\n", reason)
+			flusher.Flush()
+			fc.close()
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-out.wake:
+		case <-ticker.C:
+			// Keeps proxies from timing out a quiet stream
+			io.WriteString(w, ": keepalive\n\n")
+		case <-detached:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// servePoll answers with the queued server messages, waiting up to
+// longPollWait for the first one
+func (fc *fallbackConn) servePoll(w http.ResponseWriter, r *http.Request) {
+	out, detached := fc.attach()
+	if out == nil {
+		http.Error(w, "Connection closed", http.StatusGone)
+		return
+	}
+	defer fc.detach(detached)
+
+	timeout := time.NewTimer(longPollWait)
+	defer timeout.Stop()
+	resp := FallbackPollPayload{Messages: []json.RawMessage{}}
+	for {
+		for {
+			message, ok := out.pop()
+			if !ok {
+				break
+			}
+			resp.Messages = append(resp.Messages, message)
+		}
+		if closed, reason := out.done(); closed {
+			resp.Closed, resp.Reason = true, reason
+			fc.close()
+			break
+		}
+		if len(resp.Messages) > 0 {
+			break
+		}
+
+		select {
+		case <-out.wake:
+			continue
+		case <-timeout.C:
+		case <-detached:
+		case <-r.Context().Done():
+			return
+		}
+		break
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// serveSend handles one client message. It is processed before the request
+// returns, so its ack or error is already queued for the reader.
+func (fc *fallbackConn) serveSend(w http.ResponseWriter, r *http.Request) {
+	message, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		http.Error(w, "Message too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	fc.mu.Lock()
+	gone := fc.gone
+	fc.mu.Unlock()
+	if gone {
+		http.Error(w, "Connection closed", http.StatusGone)
+		return
+	}
+
+	fc.client.receive(jsonCodec{}, message)
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,347 @@
+package ws
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFallbackServer serves the fallback transport of a running hub
+func newFallbackServer(t *testing.T, h *Hub) *httptest.Server {
+	go h.Run()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fallback/", h.HandleFallback)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// fallbackPlayer is a client connected over the fallback transport
+type fallbackPlayer struct {
+	srv  *httptest.Server
+	id   string
+	msgs []rawMessage // read by poll, not yet looked at by pollFor
+}
+
+func connectFallback(t *testing.T, srv *httptest.Server) *fallbackPlayer {
+	t.Helper()
+	resp, err := http.Post(srv.URL+"/fallback/connect", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var payload FallbackConnectPayload
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil || payload.ConnectionID == "" {
+		t.Fatalf("connect answered %d without a connection ID: %v", resp.StatusCode, err)
+	}
+	return &fallbackPlayer{srv: srv, id: payload.ConnectionID}
+}
+
+// send posts one message and returns the status it was answered with
+func (f *fallbackPlayer) send(t *testing.T, msgType, requestID string, payload interface{}) int {
+	t.Helper()
+	body, err := json.Marshal(map[string]interface{}{"type": msgType, "requestId": requestID, "payload": payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(f.srv.URL+"/fallback/send?conn="+f.id, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// fallbackPoll is the answer to one poll
+type fallbackPoll struct {
+	payload FallbackPollPayload
+	status  int
+	err     error
+}
+
+// startPoll polls in the background. The poll has taken over reading once
+// the connection has a reader again.
+func (f *fallbackPlayer) startPoll() <-chan fallbackPoll {
+	answer := make(chan fallbackPoll, 1)
+	go func() {
+		var poll fallbackPoll
+		resp, err := http.Get(f.srv.URL + "/fallback/poll?conn=" + f.id)
+		if err != nil {
+			poll.err = err
+		} else {
+			poll.status = resp.StatusCode
+			if resp.StatusCode == http.StatusOK {
+				poll.err = json.NewDecoder(resp.Body).Decode(&poll.payload)
+			}
+			resp.Body.Close()
+		}
+		answer <- poll
+	}()
+	return answer
+}
+
+// poll reads the queued server messages once
+func (f *fallbackPlayer) poll(t *testing.T) (FallbackPollPayload, int) {
+	t.Helper()
+	return f.collect(t, f.startPoll())
+}
+
+// collect waits for a poll started by startPoll and keeps its messages for
+// pollFor
+func (f *fallbackPlayer) collect(t *testing.T, answer <-chan fallbackPoll) (FallbackPollPayload, int) {
+	t.Helper()
+	poll := <-answer
+	if poll.err != nil {
+		t.Fatal(poll.err)
+	}
+	for _, data := range poll.payload.Messages {
+		var msg rawMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("undecodable message %s", data)
+		}
+		f.msgs = append(f.msgs, msg)
+	}
+	return poll.payload, poll.status
+}
+
+// pollFor polls until the message of type msgType answering requestID, or
+// any message of the type for an empty requestID, arrives. Others are skipped.
+func (f *fallbackPlayer) pollFor(t *testing.T, msgType, requestID string) rawMessage {
+	t.Helper()
+	for polls := 0; polls < 20; polls++ {
+		for len(f.msgs) > 0 {
+			msg := f.msgs[0]
+			f.msgs = f.msgs[1:]
+			if msg.Type == msgType && (requestID == "" || msg.RequestID == requestID) {
+				return msg
+			}
+		}
+		payload, status := f.poll(t)
+		if status != http.StatusOK || payload.Closed {
+			t.Fatalf("poll answered %d (closed %v, %q) waiting for %s", status, payload.Closed, payload.Reason, msgType)
+		}
+	}
+	t.Fatalf("no %s for %q after 20 polls", msgType, requestID)
+	return rawMessage{}
+}
+
+// expectReply waits for the message of type msgType answering requestID
+func expectReply(t *testing.T, p *peer, msgType, requestID string) rawMessage {
+	t.Helper()
+	for {
+		if msg := p.expect(t, msgType); msg.RequestID == requestID {
+			return msg
+		}
+	}
+}
+
+// readerAttached reports whether a stream or poll is reading from f
+func (f *fallbackPlayer) readerAttached(h *Hub) bool {
+	h.mu.Lock()
+	fc := h.fallbackConns[f.id]
+	h.mu.Unlock()
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.reader != nil
+}
+
+// streamEnded reports whether the events read by p have stopped
+func streamEnded(p *peer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err != nil
+}
+
+// streamEvents reads the connection's Server-Sent Events into a peer until
+// the stream ends
+func (f *fallbackPlayer) streamEvents(t *testing.T) *peer {
+	t.Helper()
+	resp, err := http.Get(f.srv.URL + "/fallback/events?conn=" + f.id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("events answered %d with %q", resp.StatusCode, ct)
+	}
+	p := &peer{}
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		event := ""
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: ") && event == "close":
+				p.end(errors.New("closed: " + strings.TrimPrefix(line, "data: ")))
+				return
+			case strings.HasPrefix(line, "data: "):
+				p.record([]byte(strings.TrimPrefix(line, "data: ")))
+			}
+		}
+		p.end(io.EOF)
+	}()
+	return p
+}
+
+// TestFallbackPolling joins, plays a move and reads the replies by polling
+func TestFallbackPolling(t *testing.T) {
+	h := NewHub()
+	srv := newFallbackServer(t, h)
+	f := connectFallback(t, srv)
+
+	if status := f.send(t, MsgJoin, "j1", JoinPayload{Username: "alice", GameMode: "computer", ProtocolVersion: ProtocolVersion}); status != http.StatusAccepted {
+		t.Fatalf("send answered %d", status)
+	}
+	f.pollFor(t, MsgWelcome, "j1")
+	msg := f.pollFor(t, MsgGameStart, "")
+	f.pollFor(t, MsgAck, "j1")
+	var start GameStartPayload
+	if perr := msg.decodePayload(&start); perr != nil {
+		t.Fatal(perr)
+	}
+	if start.You == nil || !start.You.MovesFirst {
+		t.Fatalf("gameStart %s, want alice moving first", msg.Payload)
+	}
+
+	f.send(t, MsgMove, "m1", map[string]int{"column": 3})
+	f.pollFor(t, MsgAck, "m1")
+	// Errors come back the same way
+	f.send(t, "noop", "n1", nil)
+	msg = f.pollFor(t, MsgError, "n1")
+	var perr ProtocolError
+	msg.decodePayload(&perr)
+	if perr.Code != ErrCodeUnknownType {
+		t.Errorf("error %s, want %s", msg.Payload, ErrCodeUnknownType)
+	}
+
+	h.mu.Lock()
+	g := h.activeGames[start.ID]
+	h.mu.Unlock()
+	if g == nil {
+		t.Fatal("the game is not running")
+	}
+	g.mu.Lock()
+	played := g.game.Board.MoveCount()
+	g.mu.Unlock()
+	if played < 1 {
+		t.Errorf("%d moves played, want alice's", played)
+	}
+}
+
+// TestFallbackEvents reads server messages as Server-Sent Events. A poll
+// takes over from the stream, which ends.
+func TestFallbackEvents(t *testing.T) {
+	h := NewHub()
+	srv := newFallbackServer(t, h)
+	f := connectFallback(t, srv)
+	events := f.streamEvents(t)
+
+	f.send(t, MsgJoin, "j1", JoinPayload{Username: "alice", GameMode: "computer", ProtocolVersion: ProtocolVersion})
+	expectReply(t, events, MsgWelcome, "j1")
+	events.expect(t, MsgGameStart)
+	f.send(t, MsgMove, "m1", map[string]int{"column": 3})
+	expectReply(t, events, MsgAck, "m1")
+
+	// One reader at a time: a poll detaches the stream and reads on
+	answer := f.startPoll()
+	waitFor(t, "the stream to end", func() bool { return streamEnded(events) })
+	f.send(t, "noop", "n1", nil)
+	f.collect(t, answer)
+	f.pollFor(t, MsgError, "n1")
+}
+
+func TestFallbackRejectsUnknownConnection(t *testing.T) {
+	h := NewHub()
+	srv := newFallbackServer(t, h)
+	f := connectFallback(t, srv)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/fallback/events?conn=unknown", http.StatusNotFound},
+		{http.MethodGet, "/fallback/poll?conn=unknown", http.StatusNotFound},
+		{http.MethodPost, "/fallback/send?conn=unknown", http.StatusNotFound},
+		{http.MethodGet, "/fallback/poll", http.StatusNotFound},
+		{http.MethodPost, "/fallback/send?conn=" + f.id[:len(f.id)-1], http.StatusNotFound},
+		{http.MethodGet, "/fallback/other?conn=" + f.id, http.StatusNotFound},
+		{http.MethodGet, "/fallback/connect", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/fallback/poll?conn=" + f.id, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/fallback/send?conn=" + f.id, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader(`{"type":"noop"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s: %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+// TestFallbackExpiresAfterDetach leaves a connection without a reader until
+// its idle timer fires. The connection is gone and its player disconnected.
+func TestFallbackExpiresAfterDetach(t *testing.T) {
+	h := NewHub()
+	srv := newFallbackServer(t, h)
+	f := connectFallback(t, srv)
+	f.send(t, MsgJoin, "j1", JoinPayload{Username: "alice", GameMode: "friend", ProtocolVersion: ProtocolVersion})
+	f.pollFor(t, MsgWelcome, "j1")
+
+	h.mu.Lock()
+	fc := h.fallbackConns[f.id]
+	h.mu.Unlock()
+
+	// The timer firing while a stream is attached leaves the connection open
+	events := f.streamEvents(t)
+	waitFor(t, "the stream to attach", func() bool { return f.readerAttached(h) })
+	fc.expire()
+	f.send(t, "noop", "n1", nil)
+	expectReply(t, events, MsgError, "n1")
+
+	// A poll detaches the stream, and itself once answered
+	answer := f.startPoll()
+	waitFor(t, "the stream to end", func() bool { return streamEnded(events) })
+	f.send(t, "noop", "n2", nil)
+	f.collect(t, answer)
+	f.pollFor(t, MsgError, "n2")
+	if f.readerAttached(h) {
+		t.Fatal("the connection has a reader after the poll returned")
+	}
+
+	// What the idle timer does
+	fc.expire()
+	if _, status := f.poll(t); status != http.StatusNotFound {
+		t.Errorf("poll after expiry: %d, want %d", status, http.StatusNotFound)
+	}
+	if status := f.send(t, "noop", "n3", nil); status != http.StatusNotFound {
+		t.Errorf("send after expiry: %d, want %d", status, http.StatusNotFound)
+	}
+	waitFor(t, "alice to leave", func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for c := range h.clients {
+			if c.username == "alice" {
+				return false
+			}
+		}
+		return true
+	})
+	// A new connection can take the name
+	g := connectFallback(t, srv)
+	g.send(t, MsgJoin, "j2", JoinPayload{Username: "alice", GameMode: "friend", ProtocolVersion: ProtocolVersion})
+	g.pollFor(t, MsgWelcome, "j2")
+}
@@ -3,30 +3,85 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/connect4/backend/internal/bot"
+	"github.com/connect4/backend/internal/config"
 	"github.com/connect4/backend/internal/database"
 	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/webhook"
 )
 
-// WSGame represents the websocket wrapper around a game session
+// WSGame represents the websocket wrapper around a game session. Moves,
+// timers and rematches of one game are serialized by its own mu, so games
+// never wait for each other.
 type WSGame struct {
+	hub    *Hub
+	mode   string            // game mode whose policy applies
+	policy config.GamePolicy // policy of mode when the game started
+
+	// mu guards everything below it
+	mu            sync.Mutex
 	game          *game.Game
-	hub           *Hub
 	player1Client *Client
 	player2Client *Client
-	mu            sync.Mutex // Mutex for thread-safe operations
-	// Track play-again requests (usernames)
+	clockTimer    *time.Timer
+	// Set once the game is dropped from the hub; nothing may happen in it
+	// after that
+	closed bool
+	// Recent game events for resync, numbered by eventSeq
+	events   []Envelope
+	eventSeq uint64
+	// Seats a bot is playing for an absent player
+	substitutes [2]bool
+	// Best-of-N series the game belongs to, nil for a single game
+	series *series
+	// Track play-again requests (usernames) and the series length proposed
+	// with the first one
 	PlayAgainRequests []string
+	rematchBestOf     int
+
+	// Mirrors game.IsActive for code holding only h.mu
+	running atomic.Bool
+	// Clients watching the game without playing, guarded by watchMu. It is
+	// taken last, after any other lock.
+	watchMu    sync.Mutex
+	spectators map[*Client]bool
 }
 
 func (g *WSGame) ToGameState() *game.GameState {
 	return g.game.GetState()
 }
 
+// isRunning reports whether the game is still being played. Unlike
+// g.game.IsActive it may be read without holding g.mu.
+func (g *WSGame) isRunning() bool {
+	return g.running.Load()
+}
+
+// syncRunning publishes g.game.IsActive to isRunning. Must be called with
+// g.mu held whenever the game ends.
+func (g *WSGame) syncRunning() {
+	g.running.Store(g.game.IsActive)
+}
+
+// seatPayload describes a seat to the player holding it, nil for seat 0
+func (g *WSGame) seatPayload(seat int) *SeatPayload {
+	if seat != 1 && seat != 2 {
+		return nil
+	}
+	return &SeatPayload{
+		Seat:       seat,
+		Color:      g.game.ColorOf(seat),
+		MovesFirst: seat == g.game.FirstTurn,
+	}
+}
+
 func (g *WSGame) CheckWinner() int {
 	if g.game.CheckWin() {
 		return g.game.Board.LastMove.Player
@@ -34,22 +89,19 @@ func (g *WSGame) CheckWinner() int {
 	return 0
 }
 
-// GameMessage represents a game-related message
-type GameMessage struct {
-	Type    string      `json:"type"`
-	GameID  string      `json:"gameId"`
-	Payload interface{} `json:"payload"`
-}
-
 // handleMove processes a player's move
-func (h *Hub) handleMove(client *Client, column int) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+func (h *Hub) handleMove(client *Client, column int) *ProtocolError {
+	return h.handleMoveAt(client, column, -1)
+}
 
-	g, exists := h.activeGames[client.gameID]
-	if !exists {
-		return
+// handleMoveAt is handleMove for a client that saw moveNumber moves played.
+// The move is rejected if the game has moved on since; -1 skips the check.
+func (h *Hub) handleMoveAt(client *Client, column, moveNumber int) *ProtocolError {
+	g := h.lockGameOf(client)
+	if g == nil {
+		return newProtocolError(ErrCodeNoSuchGame, "you are not in a game")
 	}
+	defer g.mu.Unlock()
 
 	// Verify it's the player's turn
 	// Determine if it's the client's turn
@@ -57,25 +109,43 @@ func (h *Hub) handleMove(client *Client, column int) {
 	var isPlayer2 = g.game.Player2.ID == client.username
 
 	if !isPlayer1 && !isPlayer2 {
-		return
+		return newProtocolError(ErrCodeNotAPlayer, "you are not a player in this game")
+	}
+
+	if !g.game.IsActive {
+		return newProtocolError(ErrCodeGameOver, "the game is over")
+	}
+
+	// A bot holds the seat until its player resumes the session
+	if (isPlayer1 && g.substitutes[0]) || (isPlayer2 && g.substitutes[1]) {
+		return newProtocolError(ErrCodeNotAllowed, "a bot is playing your seat; resume your session to take it back")
+	}
+
+	if played := g.game.Board.MoveCount(); moveNumber >= 0 && moveNumber != played {
+		return newProtocolError(ErrCodeStaleMove, "move %d was expected but %d moves have been played", moveNumber, played)
 	}
 
 	if (g.game.CurrentTurn != 1 && isPlayer1) || (g.game.CurrentTurn != 2 && isPlayer2) {
-		return
+		return newProtocolError(ErrCodeNotYourTurn, "it is not your turn")
+	}
+
+	// A move that arrives after the flag fell ends the game instead
+	if h.checkClockTimeout(g) {
+		return newProtocolError(ErrCodeOutOfTime, "you ran out of time")
 	}
 
 	// Make the move
 	if err := g.game.MakeMove(column); err != nil {
-		// Send error message to client
-		msg := GameMessage{
-			Type:    "error",
-			GameID:  g.game.ID,
-			Payload: err.Error(),
-		}
-		if data, err := json.Marshal(msg); err == nil {
-			client.send <- data
+		switch {
+		case errors.Is(err, game.ErrColumnFull):
+			return newProtocolError(ErrCodeColumnFull, "column %d is full", column)
+		case errors.Is(err, game.ErrInvalidColumn):
+			return newProtocolError(ErrCodeInvalidColumn, "column %d is out of range", column)
+		case errors.Is(err, game.ErrOutOfTime):
+			return newProtocolError(ErrCodeOutOfTime, "you ran out of time")
+		default:
+			return newProtocolError(ErrCodeGameOver, "%v", err)
 		}
-		return
 	}
 
 	// Check for game end conditions
@@ -89,81 +159,13 @@ func (h *Hub) handleMove(client *Client, column int) {
 		h.storeGameResult(g, 0, true)
 	}
 
-	// Broadcast game state to both players
-	state := g.ToGameState()
-	msg := GameMessage{
-		Type:    "gameState",
-		GameID:  g.game.ID,
-		Payload: state,
-	}
-
-	if data, err := json.Marshal(msg); err == nil {
-		// We already hold h.mu; use findClientUnsafe to avoid deadlock
-		if p1Client := h.findClientUnsafe(g.game.Player1.ID); p1Client != nil {
-			select {
-			case p1Client.send <- data:
-			default:
-			}
-		}
-		if p2Client := h.findClientUnsafe(g.game.Player2.ID); p2Client != nil {
-			select {
-			case p2Client.send <- data:
-			default:
-			}
-		}
-	}
-
-	// If the game finished, send a dedicated gameFinished message so frontends can
-	// show a popup with Play Again / Exit options.
-	if !g.game.IsActive {
-		isDraw := g.game.Board.IsBoardFull() && !g.game.CheckWin()
-		var winnerUsername interface{} = nil
-		botWon := false
-		if g.game.CheckWin() {
-			if g.game.Board.LastMove.Player == 1 {
-				winnerUsername = g.game.Player1.Username
-			} else if g.game.Board.LastMove.Player == 2 {
-				if !g.game.Player2.IsBot {
-					winnerUsername = g.game.Player2.Username
-				} else {
-					// Bot won
-					botWon = true
-				}
-			}
-		}
-
-		finishPayload := map[string]interface{}{
-			"gameId": g.game.ID,
-			"isDraw": isDraw,
-			"winner": winnerUsername,
-			"botWon": botWon,
-		}
-
-		finishMsg := GameMessage{
-			Type:    "gameFinished",
-			GameID:  g.game.ID,
-			Payload: finishPayload,
-		}
-		if data, err := json.Marshal(finishMsg); err == nil {
-			if p1Client := h.findClientUnsafe(g.game.Player1.ID); p1Client != nil && p1Client.send != nil {
-				select {
-				case p1Client.send <- data:
-				default:
-				}
-			}
-			if p2Client := h.findClientUnsafe(g.game.Player2.ID); p2Client != nil && p2Client.send != nil {
-				select {
-				case p2Client.send <- data:
-				default:
-				}
-			}
-		}
-	}
+	h.persistGameLocked(g)
+	h.broadcastGameUpdate(g)
+	h.scheduleClockTimeout(g)
 
 	// If playing against bot, trigger bot move
-	if g.game.IsActive && g.game.Player2.IsBot && g.game.CurrentTurn == 2 {
-		go h.makeBotMove(g)
-	}
+	h.scheduleBotMoveLocked(g)
+	return nil
 }
 
 // GetBoardForBot creates a 2D slice representation of the board for the bot
@@ -171,20 +173,31 @@ func (g *WSGame) GetBoardForBot() [][]int {
 	return g.game.GetBoardForBot()
 }
 
-// makeBotMove handles the bot's turn
+// makeBotMove handles the bot's turn, for a bot opponent or a bot standing
+// in for an absent player
 func (h *Hub) makeBotMove(wsGame *WSGame) {
-	botPlayer := bot.NewBot()
+	wsGame.mu.Lock()
+	if wsGame.closed || !wsGame.game.IsActive || !wsGame.botToMove() {
+		wsGame.mu.Unlock()
+		return
+	}
+	player := wsGame.game.CurrentTurn
+	grid := wsGame.game.Board.Grid
+	think := wsGame.policy.BotThinkDelay
 	board := wsGame.game.GetBoardForBot()
-	column := botPlayer.CalculateNextMove(board)
+	wsGame.mu.Unlock()
+
+	botPlayer := bot.NewBot()
+	column := botPlayer.CalculateMoveAs(board, player)
 
 	// Small delay to simulate "thinking"
-	time.Sleep(500 * time.Millisecond)
+	time.Sleep(think)
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	wsGame.mu.Lock()
+	defer wsGame.mu.Unlock()
 
 	// Verify game still exists and is active
-	if _, exists := h.activeGames[wsGame.game.ID]; !exists {
+	if wsGame.closed {
 		return
 	}
 
@@ -192,6 +205,15 @@ func (h *Hub) makeBotMove(wsGame *WSGame) {
 		return
 	}
 
+	// The position changed or the player came back while we were thinking
+	if wsGame.game.Board.Grid != grid || wsGame.game.CurrentTurn != player || !wsGame.botToMove() {
+		return
+	}
+
+	if h.checkClockTimeout(wsGame) {
+		return
+	}
+
 	if err := wsGame.game.MakeMove(column); err != nil {
 		log.Printf("Bot move error: %v", err)
 		return
@@ -208,74 +230,97 @@ func (h *Hub) makeBotMove(wsGame *WSGame) {
 		h.storeGameResult(wsGame, 0, true)
 	}
 
-	// Broadcast updated game state
-	state := wsGame.ToGameState()
-	msg := GameMessage{
-		Type:    "gameState",
-		GameID:  wsGame.game.ID,
-		Payload: state,
+	h.persistGameLocked(wsGame)
+	h.broadcastGameUpdate(wsGame)
+	h.scheduleClockTimeout(wsGame)
+	h.scheduleBotMoveLocked(wsGame)
+}
+
+// broadcastGameUpdate sends the current game state to both players and, if the
+// game has just finished, a dedicated gameFinished message so frontends can
+// show a popup with Play Again / Exit options. Must be called with g.mu held
+// and h.mu not held.
+func (h *Hub) broadcastGameUpdate(g *WSGame) {
+	g.syncRunning()
+	h.publishGameEventLocked(g, Envelope{
+		Type:    MsgGameState,
+		Payload: g.ToGameState(),
+	})
+
+	if g.game.IsActive {
+		return
+	}
+	h.mu.Lock()
+	h.refreshPresenceUnsafe(g.player1Client)
+	h.refreshPresenceUnsafe(g.player2Client)
+	h.mu.Unlock()
+
+	isDraw := g.game.Drawn || (g.game.Board.IsBoardFull() && !g.game.CheckWin() && g.game.Winner == 0)
+	winner := g.game.Winner
+	if winner == 0 && g.game.CheckWin() {
+		winner = g.game.Board.LastMove.Player
 	}
 
-	if data, err := json.Marshal(msg); err == nil {
-		// h.mu is held here; use unsafe lookup to send without re-locking
-		if p1Client := h.findClientUnsafe(wsGame.game.Player1.ID); p1Client != nil {
-			select {
-			case p1Client.send <- data:
-			default:
-			}
-		}
-		if p2Client := h.findClientUnsafe(wsGame.game.Player2.ID); p2Client != nil {
-			select {
-			case p2Client.send <- data:
-			default:
-			}
+	finished := GameFinishedPayload{
+		GameID: g.game.ID,
+		IsDraw: isDraw,
+		Reason: g.game.EndReason,
+		Series: g.seriesScore(),
+	}
+	if winner == 1 {
+		finished.Winner = &g.game.Player1.Username
+	} else if winner == 2 {
+		if !g.game.Player2.IsBot {
+			finished.Winner = &g.game.Player2.Username
+		} else {
+			// Bot won
+			finished.BotWon = true
 		}
 	}
 
-	// If the game finished after the bot move, send gameFinished message
-	if !wsGame.game.IsActive {
-		isDraw := wsGame.game.Board.IsBoardFull() && !wsGame.game.CheckWin()
-		var winnerUsername interface{} = nil
-		botWon := false
-		if wsGame.game.CheckWin() {
-			if wsGame.game.Board.LastMove.Player == 1 {
-				winnerUsername = wsGame.game.Player1.Username
-			} else if wsGame.game.Board.LastMove.Player == 2 {
-				if !wsGame.game.Player2.IsBot {
-					winnerUsername = wsGame.game.Player2.Username
-				} else {
-					botWon = true
-				}
-			}
-		}
+	h.publishGameEventLocked(g, Envelope{
+		Type:    MsgGameFinished,
+		Payload: finished,
+	})
+}
 
-		finishPayload := map[string]interface{}{
-			"gameId": wsGame.game.ID,
-			"isDraw": isDraw,
-			"winner": winnerUsername,
-			"botWon": botWon,
-		}
+// scheduleClockTimeout arms a timer that ends a timed game when the player to
+// move runs out of time. Must be called with g.mu held.
+func (h *Hub) scheduleClockTimeout(g *WSGame) {
+	if g.clockTimer != nil {
+		g.clockTimer.Stop()
+		g.clockTimer = nil
+	}
+	if g.game.Clock == nil || !g.game.IsActive {
+		return
+	}
 
-		finishMsg := GameMessage{
-			Type:    "gameFinished",
-			GameID:  wsGame.game.ID,
-			Payload: finishPayload,
-		}
-		if data, err := json.Marshal(finishMsg); err == nil {
-			if p1Client := h.findClientUnsafe(wsGame.game.Player1.ID); p1Client != nil && p1Client.send != nil {
-				select {
-				case p1Client.send <- data:
-				default:
-				}
-			}
-			if p2Client := h.findClientUnsafe(wsGame.game.Player2.ID); p2Client != nil && p2Client.send != nil {
-				select {
-				case p2Client.send <- data:
-				default:
-				}
-			}
-		}
+	turn := g.game.CurrentTurn
+	remaining := g.game.Clock.RemainingFor(turn, turn, time.Now())
+	g.clockTimer = time.AfterFunc(remaining, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		h.checkClockTimeout(g)
+	})
+}
+
+// checkClockTimeout ends the game if the player to move has no time left.
+// Must be called with g.mu held and h.mu not held.
+func (h *Hub) checkClockTimeout(g *WSGame) bool {
+	if g.closed || g.game.Clock == nil || !g.game.IsActive {
+		return false
 	}
+	turn := g.game.CurrentTurn
+	if g.game.Clock.RemainingFor(turn, turn, time.Now()) > 0 {
+		return false
+	}
+
+	log.Printf("[BACKEND-CLOCK] Game %s: player %d flagged", g.game.ID, turn)
+	g.game.EndByTimeout()
+	h.storeGameResult(g, g.game.Winner, false)
+	h.persistGameLocked(g)
+	h.broadcastGameUpdate(g)
+	return true
 }
 
 // findClient finds a client by username
@@ -291,11 +336,38 @@ func (h *Hub) findClient(username string) *Client {
 	return nil
 }
 
+// needsResultRecordLocked reports whether storeGameResult has to write the
+// result of g. A rated game normally has a record created by game.NewGame,
+// and the game writes its own result into it when it ends, players'
+// statistics and ratings included. Writing it again here used to create a
+// second games row and count the result twice; now only rated games without
+// a record, e.g. because creating it failed, are stored here. Must be
+// called with g.mu held.
+func (g *WSGame) needsResultRecordLocked() bool {
+	return g.game.Settings.Rated && !g.game.Substituted && g.game.DBGameID == 0
+}
+
 // storeGameResult stores game result to database if available
 // Note: This requires database integration with user management to map
 // string usernames to integer player IDs. Currently a placeholder.
+// Must be called with g.mu held and h.mu not held; the database is written
+// under the game's lock only.
 func (h *Hub) storeGameResult(g *WSGame, winner int, isDraw bool) {
-	if h.db != nil {
+	g.syncRunning()
+	if g.clockTimer != nil {
+		g.clockTimer.Stop()
+		g.clockTimer = nil
+	}
+	h.mu.Lock()
+	h.notifyResultUnsafe(g, winner, isDraw)
+	h.mu.Unlock()
+	h.recordSeriesGameLocked(g, winner, isDraw)
+
+	// Unrated and substituted games never touch player statistics
+	if g.game.Substituted {
+		log.Printf("[BACKEND-STORE] Game %s was finished by a substitute bot, not rating it", g.game.ID)
+	}
+	if h.db != nil && g.needsResultRecordLocked() {
 		ctx := context.Background()
 		// 1. Get or create players by username
 		p1, err := h.db.GetPlayer(ctx, g.game.Player1.Username)
@@ -366,7 +438,10 @@ func (h *Hub) storeGameResult(g *WSGame, winner int, isDraw bool) {
 			log.Printf("DB error unmarshaling game state: %v", err)
 			return
 		}
-		err = h.db.UpdateGameResult(ctx, gameRec.ID, winnerID, gameStateMap)
+		if err := h.db.SaveMoves(ctx, gameRec.ID, g.game.Moves); err != nil {
+			log.Printf("DB error saving moves: %v", err)
+		}
+		err = h.db.UpdateGameResult(ctx, gameRec.ID, winnerID, isDraw, gameStateMap, g.game.BotRating)
 		if err != nil {
 			log.Printf("DB error updating game result: %v", err)
 			return
@@ -379,62 +454,78 @@ func (h *Hub) storeGameResult(g *WSGame, winner int, isDraw bool) {
 	// can refresh their leaderboard view. We include minimal details (winner username,
 	// isDraw, gameId). To avoid frontend optimistically adding bot users to the
 	// leaderboard, only include the winner username when the winner is a human.
-	payload := map[string]interface{}{
-		"gameId": g.game.ID,
-		"isDraw": isDraw,
+	payload := LeaderboardUpdatePayload{
+		GameID: g.game.ID,
+		IsDraw: isDraw,
 	}
 	if winner == 1 {
-		payload["winner"] = g.game.Player1.Username
+		payload.Winner = &g.game.Player1.Username
+		payload.WinnerIsBot = g.game.Player1.BotAccount
 	} else if winner == 2 {
 		// Only include winner username if player2 is not a bot
 		if !g.game.Player2.IsBot {
-			payload["winner"] = g.game.Player2.Username
+			payload.Winner = &g.game.Player2.Username
+			payload.WinnerIsBot = g.game.Player2.BotAccount
 		} else {
 			// Bot won - do not include winner to prevent optimistic frontend insert
-			payload["winner"] = nil
-			payload["botWon"] = true
+			payload.BotWon = true
 		}
-	} else {
-		payload["winner"] = nil
 	}
 
-	msg := map[string]interface{}{
-		"type":    "leaderboardUpdate",
-		"payload": payload,
-	}
+	h.emitGameEventLocked(g, webhook.EventGameFinished, winner, "")
 
-	if data, err := json.Marshal(msg); err == nil {
-		log.Printf("[BACKEND-STORE] Broadcasting leaderboardUpdate: game=%s, winner=%v, isDraw=%v", g.game.ID, payload["winner"], isDraw)
-		// Iterate over connected clients and send if they have a send channel
-		for client := range h.clients {
-			if client.send == nil {
-				continue
-			}
-			select {
-			case client.send <- data:
-			default:
-				// If client's send channel is full, skip to avoid blocking
-			}
-		}
-	} else {
-		log.Printf("[BACKEND-STORE] Failed to marshal leaderboardUpdate: %v", err)
-	}
+	log.Printf("[BACKEND-STORE] Broadcasting leaderboardUpdate: game=%s, winner=%d, isDraw=%v", g.game.ID, winner, isDraw)
+	// Send to every connected client, including those on other instances
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.broadcastUnsafe(Envelope{Type: MsgLeaderboardUpdate, Payload: payload})
 }
 
-// createGame creates a new game between two players
-func (h *Hub) createGame(player1, player2 *Client) {
+// createGame creates a new game between two players. mode selects the
+// policy that applies to the game. firstMover names the player who moves
+// first, player1 when empty. A game continuing a match series passes it in;
+// otherwise a new series starts when the settings ask for one. Must be
+// called with h.mu held.
+func (h *Hub) createGame(player1, player2 *Client, settings game.Settings, mode string, s *series, firstMover string) {
 	log.Printf("[BACKEND-14] Hub.createGame: Creating game between player1=%s, player2=%s (isBot=%v)", player1.username, player2.username, player2.isBot)
 
+	// Unrated games are not recorded in the database
+	db := h.db
+	if !settings.Rated {
+		db = nil
+	}
+
 	// Call NewGame with database (can be nil) and handle error
 	g, err := game.NewGame(
-		h.db,
-		game.Player{ID: player1.username, Username: player1.username},
-		game.Player{ID: player2.username, Username: player2.username, IsBot: player2.isBot},
+		db,
+		game.Player{ID: player1.username, Username: player1.username, BotAccount: player1.botAccount},
+		game.Player{ID: player2.username, Username: player2.username, IsBot: player2.isBot, BotAccount: player2.botAccount},
 	)
 	if err != nil {
 		log.Printf("[BACKEND-14] Hub.createGame: Error creating game: %v", err)
 		return
 	}
+	settings = enforceBotClock(settings, player1, player2)
+	g.SetSettings(settings)
+	policy := h.policyUnsafe(mode)
+	g.BotRating = policy.BotRating
+	if player2.isBot && settings.Rated && g.BotRating > 0 {
+		g.Player2.Rating = int(math.Round(g.BotRating))
+	}
+	if s == nil && settings.BestOf > 1 {
+		if firstMover == player2.username {
+			s = newSeries(player2.username, player1.username, settings.BestOf)
+		} else {
+			s = newSeries(player1.username, player2.username, settings.BestOf)
+		}
+	}
+	// The first move alternates between the games of a series
+	if s != nil {
+		firstMover = s.firstMover()
+	}
+	if firstMover != "" && firstMover == player2.username {
+		g.SetFirstTurn(2)
+	}
 
 	log.Printf("[BACKEND-15] Hub.createGame: Game created with ID=%s, CurrentTurn=%d", g.ID, g.CurrentTurn)
 	player1.gameID = g.ID
@@ -445,51 +536,66 @@ func (h *Hub) createGame(player1, player2 *Client) {
 		hub:           h,
 		player1Client: player1,
 		player2Client: player2,
+		mode:          mode,
+		policy:        policy,
+		series:        s,
 	}
+	// Nobody can be waiting for the lock of a game that is not registered
+	// yet, so taking it under h.mu cannot deadlock
+	wsGame.mu.Lock()
+	defer wsGame.mu.Unlock()
+	wsGame.syncRunning()
 	h.activeGames[g.ID] = wsGame
 	log.Printf("[BACKEND-16] Hub.createGame: Game added to activeGames, total active games: %d", len(h.activeGames))
+	if h.backplane != nil {
+		if err := h.backplane.ClaimGame(g.ID, h.instanceID); err != nil {
+			log.Printf("[BACKEND-16] Hub.createGame: Failed to claim game ownership: %v", err)
+		}
+	}
+	for _, player := range []*Client{player1, player2} {
+		h.stopSpectatingUnsafe(player)
+		h.refreshPresenceUnsafe(player)
+	}
+	h.scheduleClockTimeout(wsGame)
+	h.emitGameEventLocked(wsGame, webhook.EventGameStarted, 0, "")
 
 	// Send initial game state to both players
 	state := g.GetState()
 	log.Printf("[BACKEND-17] Hub.createGame: Game state retrieved, status=%s, player1=%s, player2=%s", state.Status, state.Player1.Username, state.Player2.Username)
 
-	msg := GameMessage{
-		Type:    "gameStart",
-		GameID:  g.ID,
-		Payload: state,
-	}
-
-	if data, err := json.Marshal(msg); err == nil {
-		log.Printf("[BACKEND-18] Hub.createGame: GameStart message marshaled, size=%d bytes", len(data))
-		// Send to player1 if they have a send channel
-		if player1.send != nil {
-			log.Printf("[BACKEND-19] Hub.createGame: Sending gameStart to player1=%s", player1.username)
-			select {
-			case player1.send <- data:
-				log.Printf("[BACKEND-20] Hub.createGame: gameStart message sent to player1=%s", player1.username)
-			default:
-				log.Printf("[BACKEND-19] Hub.createGame: Failed to send game start to player1: %s (channel full)", player1.username)
-			}
-		} else {
-			log.Printf("[BACKEND-19] Hub.createGame: player1=%s has no send channel", player1.username)
+	// Each human player gets its own copy carrying a private session token
+	for i, player := range []*Client{player1, player2} {
+		if player == nil || player.isBot {
+			log.Printf("[BACKEND-19] Hub.createGame: player%d is a bot or nil (no send queue)", i+1)
+			continue
 		}
-		// Send to player2 only if they're not a bot (bots don't have send channels)
-		if player2 != nil && !player2.isBot {
-			if player2.send != nil {
-				log.Printf("[BACKEND-19] Hub.createGame: Sending gameStart to player2=%s", player2.username)
-				select {
-				case player2.send <- data:
-					log.Printf("[BACKEND-20] Hub.createGame: gameStart message sent to player2=%s", player2.username)
-				default:
-					log.Printf("[BACKEND-19] Hub.createGame: Failed to send game start to player2: %s (channel full)", player2.username)
-				}
-			} else {
-				log.Printf("[BACKEND-19] Hub.createGame: player2=%s has no send channel (connection missing)", player2.username)
-			}
+		if player.sessionToken == "" {
+			player.sessionToken = newSessionToken()
+		}
+
+		msg := Envelope{
+			Type:   MsgGameStart,
+			GameID: g.ID,
+			Payload: GameStartPayload{
+				GameState:    state,
+				SessionToken: player.sessionToken,
+				Series:       wsGame.seriesScore(),
+				You:          wsGame.seatPayload(i + 1),
+			},
+		}
+		if player.send.Load() == nil && player.remoteInstance == "" {
+			log.Printf("[BACKEND-19] Hub.createGame: player%d=%s has no send queue (connection missing)", i+1, player.username)
+			continue
+		}
+		log.Printf("[BACKEND-19] Hub.createGame: Sending gameStart to player%d=%s", i+1, player.username)
+		if player.sendEnvelope(msg) {
+			log.Printf("[BACKEND-20] Hub.createGame: gameStart message sent to player%d=%s", i+1, player.username)
 		} else {
-			log.Printf("[BACKEND-19] Hub.createGame: player2=%s is a bot or nil (no send channel)", player2.username)
+			log.Printf("[BACKEND-19] Hub.createGame: Failed to send game start to player%d: %s (client dropped)", i+1, player.username)
 		}
-	} else {
-		log.Printf("[BACKEND-18] Hub.createGame: Error marshaling gameStart message: %v", err)
 	}
+
+	// Persist once the session tokens are assigned so restored seats can be reclaimed
+	h.persistGameUnsafe(wsGame)
+	h.scheduleBotMoveLocked(wsGame)
 }
@@ -0,0 +1,31 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/connect4/backend/internal/game"
+)
+
+// The game writes the result into its own record; the hub only stores
+// results the game could not
+func TestNeedsResultRecord(t *testing.T) {
+	tests := []struct {
+		name        string
+		rated       bool
+		substituted bool
+		dbGameID    int
+		want        bool
+	}{
+		{"recorded by the game", true, false, 42, false},
+		{"record could not be created", true, false, 0, true},
+		{"unrated", false, false, 0, false},
+		{"finished by a substitute", true, true, 0, false},
+	}
+	for _, tt := range tests {
+		g := &WSGame{game: &game.Game{DBGameID: tt.dbGameID, Substituted: tt.substituted}}
+		g.game.Settings.Rated = tt.rated
+		if got := g.needsResultRecordLocked(); got != tt.want {
+			t.Errorf("%s: needsResultRecordLocked() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
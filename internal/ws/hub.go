@@ -1,52 +1,102 @@
 package ws
 
 import (
-	"encoding/json"
 	"log"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/connect4/backend/internal/backplane"
+	"github.com/connect4/backend/internal/config"
 	"github.com/connect4/backend/internal/database"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/middleware"
+	"github.com/connect4/backend/internal/rating"
+	"github.com/connect4/backend/internal/webhook"
 	"github.com/gorilla/websocket"
 )
 
-// Hub maintains the set of active clients and broadcasts messages
+// Hub maintains the set of active clients and broadcasts messages.
+//
+// h.mu guards the registries: clients and their fields, the waiting players,
+// games, challenges, presence and bans. What happens inside a game is
+// serialized by that game's own mu instead. A game lock is always taken
+// before h.mu and never while holding it, except for a new game that is not
+// registered yet. The integrations passed to the Set methods are configured
+// before clients connect and are read without a lock afterwards.
 type Hub struct {
-	clients        map[*Client]bool
-	register       chan *Client
-	unregister     chan *Client
-	waitingPlayer  *Client
-	activeGames    map[string]*WSGame
-	mu             sync.Mutex
-	db             *database.DB
-	producer       interface{}
+	clients       map[*Client]bool
+	fallbackConns map[string]*fallbackConn // HTTP fallback connections by ID
+	register      chan *Client
+	unregister    chan *Client
+	waiting       []*Client // players queued for a match, longest waiting first
+	activeGames   map[string]*WSGame
+	challenges    map[string]*Challenge
+	mu            sync.Mutex
+	db            *database.DB
+	producer      interface{}
+	backplane     backplane.Backplane // nil when running as a single instance
+	instanceID    string
+	snapshotStore SnapshotStore // nil when games are not persisted
+	persistQueue  chan persistOp
+	policies      config.PolicyConfig
+	// Told about every finished game, e.g. tournaments
+	resultListeners []ResultListener
+	// Last status pushed for each local player, and who receives the changes
+	presence            map[string]string
+	presenceSubscribers map[*Client]bool
+	// Usernames barred by operators, with the reason
+	bans       map[string]string
+	adminToken string // empty while the admin API is disabled
+	// Registered third-party bots, by username, with the hash of their API key
+	botAccounts map[string]string
+	webhooks    *webhook.Dispatcher // nil when no events are sent out
 }
 
 // Client represents a connected player
 type Client struct {
 	hub             *Hub
 	conn            *websocket.Conn
-	send            chan []byte
+	fallback        *fallbackConn          // set instead of conn on the HTTP fallback transport
+	apiIdle         *time.Timer            // set on REST API players; disconnects them when they go quiet
+	send            atomic.Pointer[outbox] // nil while the client has no socket
+	sendMu          sync.Mutex             // keeps seq numbers in queue order
 	username        string
 	gameID          string
-	isBot           bool
+	isBot           bool // the built-in bot
+	botAccount      bool // a registered third-party bot
 	disconnectedAt  *time.Time
 	waitingBotTimer *time.Timer
-}
-
-// Message represents the WebSocket message structure
-type Message struct {
-	Type    string      `json:"type"`
-	Payload interface{} `json:"payload"`
+	sessionToken    string // issued in gameStart, required to reconnect
+	remoteInstance  string // set on proxies for players connected to another instance
+	gameOwner       string // instance owning gameID when it is not this one
+	protocolVersion int    // negotiated on join
+	codec           codec  // wire encoding negotiated on upgrade
+	seq             atomic.Uint64
+	spectating      string // game the client is watching
+	// Caps the message rate of registered bots; nil for everyone else
+	limiter atomic.Pointer[middleware.RateLimiter]
+	// When the client joined the matchmaking queue, and the rating it is
+	// matched by
+	waitingSince time.Time
+	rating       int
 }
 
 // NewHub creates a new Hub instance
 func NewHub() *Hub {
 	return &Hub{
-		clients:     make(map[*Client]bool),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		activeGames: make(map[string]*WSGame),
+		clients:             make(map[*Client]bool),
+		fallbackConns:       make(map[string]*fallbackConn),
+		register:            make(chan *Client),
+		unregister:          make(chan *Client),
+		activeGames:         make(map[string]*WSGame),
+		challenges:          make(map[string]*Challenge),
+		policies:            config.DefaultPolicies(),
+		presence:            make(map[string]string),
+		presenceSubscribers: make(map[*Client]bool),
+		bans:                make(map[string]string),
+		botAccounts:         make(map[string]string),
 	}
 }
 
@@ -64,77 +114,96 @@ func (h *Hub) SetProducer(producer interface{}) {
 	h.producer = producer
 }
 
+// SetPolicies replaces the per-mode matchmaking and reconnect policies.
+// Running timers keep the values they were started with.
+func (h *Hub) SetPolicies(policies config.PolicyConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.policies = policies
+}
+
+// policyUnsafe returns the policy for a game mode. Must be called with h.mu held.
+func (h *Hub) policyUnsafe(mode string) config.GamePolicy {
+	return h.policies.For(mode)
+}
+
 // Run starts the hub
 func (h *Hub) Run() {
+	ticker := time.NewTicker(matchInterval)
+	defer ticker.Stop()
 	for {
 		select {
 		case client := <-h.register:
+			h.mu.Lock()
 			h.clients[client] = true
+			h.mu.Unlock()
 
 		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				if client.send != nil {
-					close(client.send)
-					client.send = nil
-				}
-				h.handlePlayerDisconnect(client)
-			}
+			h.handlePlayerDisconnect(client)
+
+		case <-ticker.C:
+			h.matchWaiting()
 		}
 	}
 }
 
 // handleNewPlayer processes a new player connection
-func (h *Hub) handleNewPlayer(client *Client, gameMode string) {
+func (h *Hub) handleNewPlayer(client *Client, gameMode, sessionToken string) {
 	log.Printf("[BACKEND-10] Hub.handleNewPlayer: Called for username=%s, mode=%s", client.username, gameMode)
 
-	if h.reconnectClient(client) {
+	if h.reconnectClient(client, sessionToken) {
 		log.Printf("[BACKEND-10] Hub.handleNewPlayer: Successfully reconnected %s to existing game", client.username)
 		return
 	}
 
+	// Read before taking the lock; only queued players need it
+	playerRating := int(rating.DefaultRating)
+	if gameMode != "lobby" && gameMode != "computer" {
+		playerRating = h.lookupRating(client.username)
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// Joining again abandons a game hosted by another instance
+	h.leaveRemoteGameUnsafe(client)
+	defer h.refreshPresenceUnsafe(client)
+
+	// Lobby clients are online for challenges but not queued for a match
+	if gameMode == "lobby" {
+		log.Printf("[BACKEND-10] Hub.handleNewPlayer: %s entered the lobby", client.username)
+		return
+	}
+
 	if gameMode == "computer" {
 		log.Printf("[BACKEND-11] Hub.handleNewPlayer: COMPUTER MODE - Creating immediate bot game for %s", client.username)
-		botClient := &Client{
-			hub:      h,
-			conn:     nil,
-			send:     nil,
-			username: "AI Bot",
-			isBot:    true,
-		}
-		h.clients[botClient] = true
-		h.createGame(client, botClient)
+		h.startBotGameUnsafe(client, game.DefaultSettings(), config.ModeComputer)
 		return
 	}
 
-	if h.waitingPlayer == nil {
-		h.waitingPlayer = client
-		h.sendWaitingMessage(client)
-		client.waitingBotTimer = time.AfterFunc(10*time.Second, func() {
-			h.mu.Lock()
-			defer h.mu.Unlock()
-			if h.waitingPlayer == client {
-				h.waitingPlayer = nil
-				botClient := &Client{
-					hub:      h,
-					username: "AI Bot",
-					isBot:    true,
-				}
-				h.clients[botClient] = true
-				h.createGame(client, botClient)
-			}
-		})
-	} else {
-		waiting := h.waitingPlayer
-		if waiting.waitingBotTimer != nil {
-			waiting.waitingBotTimer.Stop()
-		}
-		h.waitingPlayer = nil
-		h.createGame(waiting, client)
+	// Pair with the closest rated player in line, or wait for the rating
+	// window to widen until someone fits
+	now := time.Now()
+	h.stopWaitingUnsafe(client)
+	client.rating = playerRating
+	client.waitingSince = now
+	if h.matchLocalUnsafe(client, now) || h.matchRemoteUnsafe(client, now) {
+		return
 	}
+	h.queueUnsafe(client)
+	h.sendWaitingMessage(client)
+}
+
+// startBotGameUnsafe starts a game between client and a new bot. Must be
+// called with h.mu held.
+func (h *Hub) startBotGameUnsafe(client *Client, settings game.Settings, mode string) {
+	botClient := &Client{
+		hub:      h,
+		username: botUsername,
+		isBot:    true,
+	}
+	h.clients[botClient] = true
+	h.createGame(client, botClient, settings, mode, nil, "")
 }
 
 // handlePlayerDisconnect handles player disconnection
@@ -142,174 +211,277 @@ func (h *Hub) handlePlayerDisconnect(client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	client.closeQueue()
+
 	now := time.Now()
 	client.disconnectedAt = &now
-
-	if h.waitingPlayer == client {
-		if client.waitingBotTimer != nil {
-			client.waitingBotTimer.Stop()
-		}
-		h.waitingPlayer = nil
+	h.cancelChallengesUnsafe(client)
+	delete(h.presenceSubscribers, client)
+	h.stopSpectatingUnsafe(client)
+	defer h.refreshPresenceUnsafe(client)
+
+	if h.isWaitingUnsafe(client) {
+		h.stopWaitingUnsafe(client)
+		delete(h.clients, client)
 		return
 	}
 
-	if client.gameID == "" {
+	// The owning instance keeps the seat open for games hosted elsewhere
+	if client.gameOwner != "" {
+		h.leaveRemoteGameUnsafe(client)
+		delete(h.clients, client)
 		return
 	}
 
+	// Clients in a game stay registered for the reconnect window so their
+	// session can be resumed; everyone else is dropped right away
 	g, exists := h.activeGames[client.gameID]
-	if !exists {
+	if client.gameID == "" || !exists {
+		delete(h.clients, client)
 		return
 	}
 
-	if !client.isBot {
-		client.waitingBotTimer = time.AfterFunc(10*time.Second, func() {
-			h.mu.Lock()
-			defer h.mu.Unlock()
-			if client.disconnectedAt != nil && g.game.IsActive {
+	h.armReconnectWindowUnsafe(client, g)
+}
+
+// armReconnectWindowUnsafe starts the timers that hand a disconnected
+// player's seat to a bot and eventually drop the game if they never return.
+// While a bot plays the seat, the player can come back until the game ends.
+// Must be called with h.mu held.
+func (h *Hub) armReconnectWindowUnsafe(client *Client, g *WSGame) {
+	policy := g.policy
+	if !client.isBot && policy.BotTakeover {
+		client.waitingBotTimer = time.AfterFunc(policy.BotTakeoverDelay, func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			if h.isAbsent(client) && !g.closed && g.game.IsActive {
 				log.Printf("[BACKEND] Bot fallback triggered for player %s", client.username)
-				botClient := &Client{
-					hub:      h,
-					username: "AI Bot",
-					isBot:    true,
-				}
-				h.clients[botClient] = true
-				if g.game.Player1.ID == client.username {
-					g.game.Player1.ID = botClient.username
-				} else {
-					g.game.Player2.ID = botClient.username
-				}
+				h.substituteLocked(g, client)
 			}
 		})
 	}
 
-	time.AfterFunc(30*time.Second, func() {
+	var expire func()
+	expire = func() {
+		// Reconnecting takes the game lock too, so the player cannot come
+		// back halfway through
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if !h.isAbsent(client) {
+			return
+		}
+		// The bot is still playing; keep the seat open for its owner
+		if h.substitutedLocked(g, client) {
+			time.AfterFunc(policy.ReconnectGrace, expire)
+			return
+		}
+		// Tournament games always need a result, whatever the policy says
+		if policy.Abandonment == config.AbandonForfeit || g.mode == config.ModeTournament {
+			h.forfeitLocked(g, client)
+		}
+		if g.game.IsActive {
+			h.emitGameEventLocked(g, webhook.EventGameAbandoned, 0, AbandonedDisconnected)
+		}
+
 		h.mu.Lock()
 		defer h.mu.Unlock()
-		if client.disconnectedAt != nil {
-			delete(h.activeGames, client.gameID)
-			delete(h.clients, client)
-			if client.send != nil {
-				close(client.send)
-				client.send = nil
-			}
+		if client.gameID == g.game.ID {
+			h.removeGameUnsafe(g)
 		}
-	})
+		delete(h.clients, client)
+		client.closeQueue()
+		h.refreshPresenceUnsafe(client)
+	}
+	time.AfterFunc(policy.ReconnectGrace, expire)
 }
 
-// reconnectClient attempts to reconnect a previously disconnected client
-func (h *Hub) reconnectClient(client *Client) bool {
+// isAbsent reports whether client is disconnected and has not come back
+func (h *Hub) isAbsent(client *Client) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	return client.disconnectedAt != nil
+}
 
-	var existingClient *Client
-	for c := range h.clients {
-		if c.username == client.username && c.disconnectedAt != nil {
-			existingClient = c
-			break
+// lockGameOf locks and returns the game the client is seated in, or nil if
+// there is none. The caller unlocks it.
+func (h *Hub) lockGameOf(client *Client) *WSGame {
+	for {
+		h.mu.Lock()
+		g := h.activeGames[client.gameID]
+		h.mu.Unlock()
+		if g == nil {
+			return nil
+		}
+		g.mu.Lock()
+		if !g.closed {
+			return g
 		}
+		// Replaced, e.g. by a rematch, while we waited for the lock
+		g.mu.Unlock()
 	}
+}
 
-	if existingClient == nil {
+// reconnectClient attempts to reattach client to the session identified by
+// token. The token is the one issued in gameStart; a matching username alone
+// is not enough to take over a seat.
+func (h *Hub) reconnectClient(client *Client, token string) bool {
+	h.mu.Lock()
+	existingClient := h.findSessionUnsafe(token)
+	if existingClient == nil || existingClient == client {
+		h.mu.Unlock()
 		return false
 	}
+	g := h.activeGames[existingClient.gameID]
+	h.mu.Unlock()
 
-	if time.Since(*existingClient.disconnectedAt) > 30*time.Second {
+	// The seat belongs to the game, whose lock comes first
+	if g != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.findSessionUnsafe(token) != existingClient || h.activeGames[existingClient.gameID] != g {
 		return false
 	}
 
+	if existingClient.disconnectedAt != nil && (g == nil || !h.substitutedLocked(g, existingClient)) {
+		grace := h.policyUnsafe(config.ModeFriend).ReconnectGrace
+		if g != nil {
+			grace = g.policy.ReconnectGrace
+		}
+		if time.Since(*existingClient.disconnectedAt) > grace {
+			return false
+		}
+	}
+
 	if existingClient.waitingBotTimer != nil {
 		existingClient.waitingBotTimer.Stop()
 	}
 
+	// A still-connected holder of the token is replaced by the new connection
+	if existingClient.disconnectedAt == nil && existingClient.send.Load() != nil {
+		log.Printf("[BACKEND] Session for %s taken over by a new connection", existingClient.username)
+		existingClient.closeQueue()
+	}
+
+	client.username = existingClient.username
+	if existingClient.botAccount && !client.botAccount {
+		client.markBotAccount()
+	}
 	client.gameID = existingClient.gameID
-	client.send = make(chan []byte, 256)
+	client.sessionToken = existingClient.sessionToken
+	// REST players have no socket to queue messages for
+	if client.apiIdle == nil {
+		client.send.CompareAndSwap(nil, newOutbox())
+	}
 	delete(h.clients, existingClient)
 	h.clients[client] = true
 	client.disconnectedAt = nil
 
-	if g, exists := h.activeGames[client.gameID]; exists {
-		if g.game.Player1.ID == client.username {
+	// Disarm the pending cleanup of the old client and invalidate its token
+	existingClient.disconnectedAt = nil
+	existingClient.sessionToken = ""
+
+	if g != nil {
+		seat := g.seatOf(existingClient)
+		if seat == 1 {
 			g.player1Client = client
-		} else if g.game.Player2.ID == client.username {
+		} else if seat == 2 {
 			g.player2Client = client
 		}
+		h.restoreSeatLocked(g, seat)
 	}
 
+	delete(h.presenceSubscribers, existingClient)
+	h.refreshPresenceUnsafe(client)
+
 	log.Printf("[BACKEND] Successfully reconnected client %s", client.username)
+	if g != nil {
+		h.sendCurrentStateLocked(g, client)
+	}
 	return true
 }
 
 // sendWaitingMessage sends waiting status to client
 func (h *Hub) sendWaitingMessage(client *Client) {
-	if client.send == nil {
-		return
-	}
-
 	board := make([][]int, 6)
 	for i := range board {
 		board[i] = make([]int, 7)
 	}
 
-	msg := GameMessage{
-		Type:   "gameState",
-		Payload: map[string]interface{}{
-			"status":      "waiting",
-			"board":       board,
-			"currentTurn": 1,
+	client.sendEnvelope(Envelope{
+		Type: MsgGameState,
+		Payload: WaitingPayload{
+			Status:      game.StatusWaiting,
+			Board:       board,
+			CurrentTurn: 1,
 		},
-	}
-
-	if data, err := json.Marshal(msg); err == nil {
-		select {
-		case client.send <- data:
-		default:
-		}
-	}
+	})
 }
 
-// handlePlayAgain handles a client's request to play again
-func (h *Hub) handlePlayAgain(client *Client) {
+// handleCancelWaiting takes a client out of the matchmaking queue
+func (h *Hub) handleCancelWaiting(client *Client) *ProtocolError {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if client.conn == nil {
-		log.Printf("[BACKEND-PLAYAGAIN] Ignoring playAgain from disconnected client %s", client.username)
-		return
+	if !h.isWaitingUnsafe(client) {
+		return newProtocolError(ErrCodeNotWaiting, "you are not waiting for a match")
 	}
+	h.stopWaitingUnsafe(client)
+	h.refreshPresenceUnsafe(client)
+	client.sendEnvelope(Envelope{
+		Type:    MsgWaitingCancelled,
+		Payload: WaitingCancelledPayload{Message: "Waiting cancelled"},
+	})
+	return nil
+}
 
-	if client.gameID == "" {
-		return
+// handlePlayAgain handles a client's request to play again
+func (h *Hub) handlePlayAgain(client *Client, bestOf int) *ProtocolError {
+	if !client.isConnected() {
+		log.Printf("[BACKEND-PLAYAGAIN] Ignoring playAgain from disconnected client %s", client.username)
+		return nil
 	}
-	g, exists := h.activeGames[client.gameID]
-	if !exists {
-		return
+
+	g := h.lockGameOf(client)
+	if g == nil {
+		return newProtocolError(ErrCodeNoSuchGame, "you are not in a game")
 	}
+	defer g.mu.Unlock()
 	if g.game.IsActive {
-		return
+		return newProtocolError(ErrCodeGameInProgress, "the game is still in progress")
+	}
+	if g.mode == config.ModeTournament {
+		return newProtocolError(ErrCodeNotAllowed, "tournament games cannot be replayed")
 	}
 
 	for _, u := range g.PlayAgainRequests {
 		if u == client.username {
-			return
+			return nil
+		}
+	}
+	// The first request may propose a series; a running series carries on
+	if len(g.PlayAgainRequests) == 0 && bestOf != 0 && (g.series == nil || g.series.Finished) {
+		proposed := g.game.Settings
+		proposed.BestOf = bestOf
+		if err := proposed.Validate(); err != nil {
+			return newProtocolError(ErrCodeInvalidSettings, "%v", err)
 		}
+		g.rematchBestOf = bestOf
 	}
 	g.PlayAgainRequests = append(g.PlayAgainRequests, client.username)
 
-	msg := GameMessage{
-		Type: "playAgainUpdate",
-		Payload: map[string]interface{}{
-			"playAgainRequests": g.PlayAgainRequests,
+	h.publishGameEventLocked(g, Envelope{
+		Type: MsgPlayAgainUpdate,
+		Payload: PlayAgainUpdatePayload{
+			PlayAgainRequests: g.PlayAgainRequests,
+			BestOf:            g.rematchBestOf,
 		},
-	}
-	if data, err := json.Marshal(msg); err == nil {
-		if p1 := h.findClientUnsafe(g.game.Player1.ID); p1 != nil && p1.send != nil {
-			select { case p1.send <- data: default: }
-		}
-		if p2 := h.findClientUnsafe(g.game.Player2.ID); p2 != nil && p2.send != nil {
-			select { case p2.send <- data: default: }
-		}
-	}
+	})
 
 	bothRequested := len(g.PlayAgainRequests) >= 2
 	if g.player1Client != nil && g.player1Client.isBot {
@@ -320,11 +492,34 @@ func (h *Hub) handlePlayAgain(client *Client) {
 	}
 
 	if bothRequested {
-		delete(h.activeGames, g.game.ID)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		// A player who has moved on, e.g. into a tournament game, declines
+		for _, c := range []*Client{g.player1Client, g.player2Client} {
+			if c != nil && !c.isBot && c.gameID != g.game.ID {
+				g.PlayAgainRequests = nil
+				return nil
+			}
+		}
+
+		h.removeGameUnsafe(g)
 		p1 := g.player1Client
 		p2 := g.player2Client
+		settings := g.game.Settings
 		g.PlayAgainRequests = nil
 
+		// Continue an undecided series, otherwise start over with the
+		// proposed length or the length of the last series
+		s := g.series
+		if s != nil && s.Finished {
+			s = nil
+		}
+		if s == nil && g.rematchBestOf != 0 {
+			settings.BestOf = g.rematchBestOf
+		}
+		firstMover := h.rematchFirstMover(g)
+
 		if (p1 != nil && p1.isBot) || (p2 != nil && p2.isBot) {
 			var human *Client
 			if p1 != nil && !p1.isBot {
@@ -334,47 +529,69 @@ func (h *Hub) handlePlayAgain(client *Client) {
 			}
 
 			if human != nil {
-				if human.send == nil && human.conn != nil {
-					human.send = make(chan []byte, 256)
+				if human.conn != nil || human.fallback != nil {
+					human.send.CompareAndSwap(nil, newOutbox())
 				}
-
-				oldGameID := human.gameID
 				human.gameID = ""
-				delete(h.activeGames, oldGameID)
 
 				botClient := &Client{
 					hub:      h,
-					username: "AI Bot",
+					username: botUsername,
 					isBot:    true,
 				}
 				h.clients[botClient] = true
 
-				go func(human *Client, botClient *Client) {
-					log.Printf("[BACKEND] Starting immediate rematch human=%s vs bot (fresh bot instance)", human.username)
-					h.createGame(human, botClient)
-				}(human, botClient)
-				return
+				log.Printf("[BACKEND] Starting immediate rematch human=%s vs bot (fresh bot instance)", human.username)
+				h.createGame(human, botClient, settings, g.mode, s, firstMover)
+				return nil
 			}
 		}
 
-		h.createGame(p1, p2)
+		h.createGame(p1, p2, settings, g.mode, s, firstMover)
+	}
+	return nil
+}
+
+// rematchFirstMover picks who moves first in the rematch of g according
+// to the mode's rematch policy. Must be called with g.mu held.
+func (h *Hub) rematchFirstMover(g *WSGame) string {
+	first, second := g.game.Player1.Username, g.game.Player2.Username
+	if g.game.FirstTurn == 2 {
+		first, second = second, first
+	}
+	switch g.policy.RematchSides {
+	case config.RematchKeep:
+		return first
+	case config.RematchRandom:
+		if rand.Intn(2) == 0 {
+			return first
+		}
 	}
+	return second
 }
 
 // handleExit handles a client's request to exit the game
-func (h *Hub) handleExit(client *Client) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+func (h *Hub) handleExit(client *Client) *ProtocolError {
+	g := h.lockGameOf(client)
+	if g == nil {
+		return newProtocolError(ErrCodeNoSuchGame, "you are not in a game")
+	}
+	defer g.mu.Unlock()
 
-	if client.gameID == "" {
-		return
+	// Leaving a running tournament game loses it
+	if g.game.IsActive && g.mode == config.ModeTournament {
+		h.forfeitLocked(g, client)
 	}
-	g, exists := h.activeGames[client.gameID]
-	if !exists {
-		return
+	if g.game.IsActive {
+		h.emitGameEventLocked(g, webhook.EventGameAbandoned, 0, AbandonedExited)
 	}
 
 	g.game.IsActive = false
+	g.syncRunning()
+	g.PlayAgainRequests = nil
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	var otherClient *Client
 	if g.game.Player1.ID == client.username {
 		otherClient = h.findClientUnsafe(g.game.Player2.ID)
@@ -382,23 +599,72 @@ func (h *Hub) handleExit(client *Client) {
 		otherClient = h.findClientUnsafe(g.game.Player1.ID)
 	}
 
-	if otherClient != nil && otherClient.send != nil {
-		msg := GameMessage{
-			Type: "opponentExited",
-			Payload: map[string]interface{}{
-				"gameId":  client.gameID,
-				"message": "opponentExited",
+	if otherClient != nil {
+		otherClient.sendEnvelope(Envelope{
+			Type:   MsgOpponentExited,
+			GameID: client.gameID,
+			Payload: OpponentExitedPayload{
+				GameID:  client.gameID,
+				Message: "opponentExited",
 			},
-		}
-		if data, err := json.Marshal(msg); err == nil {
-			select { case otherClient.send <- data: default: }
-		}
+		})
 		otherClient.gameID = ""
 	}
 
-	g.PlayAgainRequests = nil
-	delete(h.activeGames, client.gameID)
+	h.removeGameUnsafe(g)
 	client.gameID = ""
+	return nil
+}
+
+// sendEnvelope stamps env with the client's next sequence number and queues
+// it without blocking. Messages are dropped if the client has no send queue;
+// otherwise the outbox policy applies. It needs no hub or game lock.
+func (c *Client) sendEnvelope(env Envelope) bool {
+	if c == nil {
+		return false
+	}
+	if c.remoteInstance != "" {
+		// The instance holding the socket assigns the sequence number
+		return c.sendRemote(env)
+	}
+	out := c.send.Load()
+	if out == nil {
+		return false
+	}
+	// A game and the hub may send to the same client at once
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	env.Seq = c.seq.Add(1)
+	data, err := c.codec.encode(env)
+	if err != nil {
+		log.Printf("[BACKEND-SEND] Failed to encode %s for %s: %v", env.Type, c.username, err)
+		return false
+	}
+	return out.push(env.Type, env.GameID, data)
+}
+
+// closeQueue stops the client's send queue; frames already queued are still
+// written
+func (c *Client) closeQueue() {
+	if out := c.send.Swap(nil); out != nil {
+		out.close()
+	}
+}
+
+// sendError replies to a failed request with a structured error
+func (c *Client) sendError(requestID string, perr *ProtocolError) {
+	c.sendEnvelope(Envelope{
+		Type:      MsgError,
+		RequestID: requestID,
+		Payload:   perr,
+	})
+}
+
+// isConnected reports whether the client is a live player connection, either
+// a local socket, fallback connection or REST API player, or a proxy for a
+// socket on another instance
+func (c *Client) isConnected() bool {
+	return c.conn != nil || c.fallback != nil || c.apiIdle != nil || c.remoteInstance != ""
 }
 
 // findClientUnsafe finds a client without locking
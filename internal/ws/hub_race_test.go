@@ -0,0 +1,355 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/gorilla/websocket"
+)
+
+// TestConcurrentGames plays many games at once, with rematches, bot games
+// and reconnects, while other goroutines read the registries. It finds
+// little without the race detector: go test -race ./internal/ws
+func TestConcurrentGames(t *testing.T) {
+	pairs, botGames, rounds := 100, 2, 2
+	if testing.Short() {
+		pairs, botGames = 10, 1
+	}
+
+	h := NewHub()
+	policies := config.DefaultPolicies()
+	for mode, policy := range policies.Modes {
+		policy.BotFallback = false
+		policy.BotThinkDelay = 0
+		policies.Modes[mode] = policy
+	}
+	h.SetPolicies(policies)
+	go h.Run()
+	sockets := newSocketServer(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*pairs+botGames)
+	for i := 0; i < pairs; i++ {
+		p1 := sockets.mustClient(t, h, fmt.Sprintf("p%d-a", i))
+		p2 := sockets.mustClient(t, h, fmt.Sprintf("p%d-b", i))
+		h.mu.Lock()
+		h.createGame(p1, p2, game.DefaultSettings(), config.ModeFriend, nil, "")
+		h.mu.Unlock()
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- playRounds(h, sockets, p1, rounds, false)
+		}()
+		go func(reconnect bool) {
+			defer wg.Done()
+			errs <- playRounds(h, sockets, p2, rounds, reconnect)
+		}(i%10 == 0)
+	}
+	for i := 0; i < botGames; i++ {
+		c := sockets.mustClient(t, h, fmt.Sprintf("solo%d", i))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.handleNewPlayer(c, config.ModeComputer, "")
+			errs <- playRounds(h, sockets, c, rounds, false)
+		}()
+	}
+
+	// Readers of the hub registries running alongside the games
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	watcher := sockets.mustClient(t, h, "watcher")
+	h.subscribePresence(watcher)
+	for _, read := range []func(){
+		func() { h.GetActiveUsers() },
+		func() { h.AdminGames() },
+		func() { h.AdminClients() },
+		func() {
+			games := h.AdminGames()
+			if len(games) > 0 {
+				h.handleSpectate(watcher, games[rand.Intn(len(games))].ID)
+			}
+		},
+	} {
+		readers.Add(1)
+		go func(read func()) {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					read()
+					runtime.Gosched()
+				}
+			}
+		}(read)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Minute):
+		t.Fatal("games did not finish; a game or the hub is deadlocked")
+	}
+	close(stop)
+	readers.Wait()
+
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id := range h.activeGames {
+		t.Errorf("game %s was not removed after its players left", id)
+	}
+}
+
+// socketServer hands out the server side of WebSocket connections so tests
+// can drive the hub directly while clients still write to real sockets
+type socketServer struct {
+	srv   *httptest.Server
+	conns chan *websocket.Conn
+}
+
+func newSocketServer(t *testing.T) *socketServer {
+	s := &socketServer{conns: make(chan *websocket.Conn)}
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.conns <- conn
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// client registers a client named username whose socket is read and
+// discarded on the other end
+func (s *socketServer) client(h *Hub, username string) (*Client, error) {
+	return s.connect(h, username, func([]byte) {}, func(error) {})
+}
+
+// connect registers a client named username. The other end of its socket
+// hands every message to read and the error that ends the connection to
+// closed.
+func (s *socketServer) connect(h *Hub, username string, read func([]byte), closed func(error)) (*Client, error) {
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.srv.URL, "http"), nil)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				closed(err)
+				return
+			}
+			read(data)
+		}
+	}()
+
+	c := &Client{hub: h, conn: <-s.conns, username: username, codec: jsonCodec{}}
+	c.send.Store(newOutbox())
+	go c.writePump()
+	h.mu.Lock()
+	h.clients[c] = true
+	h.mu.Unlock()
+	return c, nil
+}
+
+// peer is the far end of a test client's socket. It keeps what the hub
+// sends so tests can wait for it.
+type peer struct {
+	mu   sync.Mutex
+	msgs []rawMessage
+	next int   // first message expect has not looked at
+	err  error // why the connection ended
+}
+
+// watchedClient registers a client named username whose messages are kept
+// by the returned peer
+func (s *socketServer) watchedClient(t *testing.T, h *Hub, username string) (*Client, *peer) {
+	p := &peer{}
+	c, err := s.connect(h, username, p.record, p.end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, p
+}
+
+func (p *peer) record(data []byte) {
+	var msg rawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		msg.Type = "undecodable: " + string(data)
+	}
+	p.mu.Lock()
+	p.msgs = append(p.msgs, msg)
+	p.mu.Unlock()
+}
+
+func (p *peer) end(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+// expect returns the next message of type msgType, skipping the others
+func (p *peer) expect(t *testing.T, msgType string) rawMessage {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		for p.next < len(p.msgs) {
+			msg := p.msgs[p.next]
+			p.next++
+			if msg.Type == msgType {
+				p.mu.Unlock()
+				return msg
+			}
+		}
+		err := p.err
+		p.mu.Unlock()
+		if err != nil {
+			t.Fatalf("connection ended waiting for %s: %v", msgType, err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", msgType)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+// closed waits for the connection to end and returns the reason in its
+// close frame
+func (p *peer) closed(t *testing.T) string {
+	t.Helper()
+	var err error
+	waitFor(t, "the connection to close", func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		err = p.err
+		return err != nil
+	})
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return ce.Text
+	}
+	return ""
+}
+
+func (s *socketServer) mustClient(t *testing.T, h *Hub, username string) *Client {
+	c, err := s.client(h, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// playRounds plays rounds games for c, asking for a rematch after each but
+// the last and leaving after that. With reconnect set the player drops the
+// connection once per game and resumes it on a new one.
+func playRounds(h *Hub, s *socketServer, c *Client, rounds int, reconnect bool) error {
+	gameID := currentGame(h, c)
+	for round := 0; round < rounds; round++ {
+		if gameID == "" {
+			return fmt.Errorf("%s: no game in round %d", c.username, round)
+		}
+		var perr *ProtocolError
+		for moves := 0; ; moves++ {
+			if reconnect && moves == 3 {
+				resumed, err := reconnectTestClient(h, s, c)
+				if err != nil {
+					return fmt.Errorf("%s: reconnect: %v", c.username, err)
+				}
+				c = resumed
+			}
+			perr = h.handleMove(c, rand.Intn(7))
+			if perr == nil {
+				continue
+			}
+			if perr.Code == ErrCodeGameOver {
+				break
+			}
+			// After the last game the opponent may leave before we notice
+			// it is over
+			if perr.Code == ErrCodeNoSuchGame && round == rounds-1 {
+				break
+			}
+			switch perr.Code {
+			case ErrCodeNotYourTurn:
+				time.Sleep(time.Millisecond)
+			case ErrCodeColumnFull:
+			default:
+				return fmt.Errorf("%s: move in game %s: %v", c.username, gameID, perr)
+			}
+		}
+
+		if round == rounds-1 {
+			break
+		}
+		if perr := h.handlePlayAgain(c, 0); perr != nil {
+			return fmt.Errorf("%s: play again: %v", c.username, perr)
+		}
+		deadline := time.Now().Add(30 * time.Second)
+		for currentGame(h, c) == gameID {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s: no rematch of game %s", c.username, gameID)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		gameID = currentGame(h, c)
+	}
+
+	// The opponent may have left first and taken the game with them
+	if perr := h.handleExit(c); perr != nil && perr.Code != ErrCodeNoSuchGame {
+		return fmt.Errorf("%s: exit: %v", c.username, perr)
+	}
+	return nil
+}
+
+func currentGame(h *Hub, c *Client) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return c.gameID
+}
+
+// reconnectTestClient disconnects c and resumes its session on a new client
+func reconnectTestClient(h *Hub, s *socketServer, c *Client) (*Client, error) {
+	h.mu.Lock()
+	token := c.sessionToken
+	h.mu.Unlock()
+	c.conn.Close()
+	h.handlePlayerDisconnect(c)
+
+	resumed, err := s.client(h, "")
+	if err != nil {
+		return nil, err
+	}
+	if !h.reconnectClient(resumed, token) {
+		return nil, fmt.Errorf("session was not resumed")
+	}
+	return resumed, nil
+}
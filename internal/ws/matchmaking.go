@@ -0,0 +1,191 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/connect4/backend/internal/backplane"
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/rating"
+)
+
+// matchInterval is how often waiting players are paired again as their
+// rating windows widen
+const matchInterval = 2 * time.Second
+
+// lookupRating returns the rating a player is matched by. Players without a
+// record, or a hub without a database, get the rating of a new player.
+func (h *Hub) lookupRating(username string) int {
+	if h.db == nil {
+		return int(rating.DefaultRating)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	player, err := h.db.GetPlayer(ctx, username)
+	if err != nil {
+		log.Printf("[BACKEND-10] Could not read the rating of %s: %v", username, err)
+		return int(rating.DefaultRating)
+	}
+	if player == nil {
+		return int(rating.DefaultRating)
+	}
+	return player.Rating
+}
+
+// acceptsUnsafe reports whether two players are close enough in rating to be
+// paired now. The window widens from the moment the earlier of them started
+// waiting. Must be called with h.mu held.
+func (h *Hub) acceptsUnsafe(rating1, rating2 int, since, now time.Time) bool {
+	policy := h.policyUnsafe(config.ModeFriend)
+	if policy.RatingWindow == 0 {
+		return true
+	}
+	gap := rating1 - rating2
+	if gap < 0 {
+		gap = -gap
+	}
+	waited := int(now.Sub(since) / time.Second)
+	return gap <= policy.RatingWindow+policy.RatingWindowGrowth*waited
+}
+
+// isWaitingUnsafe reports whether client is queued for a match on this
+// instance. Must be called with h.mu held.
+func (h *Hub) isWaitingUnsafe(client *Client) bool {
+	for _, waiting := range h.waiting {
+		if waiting == client {
+			return true
+		}
+	}
+	return false
+}
+
+// queueUnsafe puts client in line for a match, here and in the shared
+// queue, and arms the bot fallback. Must be called with h.mu held.
+func (h *Hub) queueUnsafe(client *Client) {
+	// Keep the line in the order players started waiting
+	i := len(h.waiting)
+	for i > 0 && h.waiting[i-1].waitingSince.After(client.waitingSince) {
+		i--
+	}
+	h.waiting = append(h.waiting, nil)
+	copy(h.waiting[i+1:], h.waiting[i:])
+	h.waiting[i] = client
+	h.enqueueWaitingUnsafe(client)
+
+	policy := h.policyUnsafe(config.ModeFriend)
+	if !policy.BotFallback {
+		return
+	}
+	client.waitingBotTimer = time.AfterFunc(policy.BotFallbackDelay, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if !h.isWaitingUnsafe(client) {
+			return
+		}
+		if !h.stopWaitingUnsafe(client) {
+			// Another instance matched this player; its gameStart is on the way
+			return
+		}
+		h.startBotGameUnsafe(client, game.DefaultSettings(), config.ModeFriend)
+	})
+}
+
+// leaveLineUnsafe takes client out of the local line and stops its bot
+// fallback. Must be called with h.mu held.
+func (h *Hub) leaveLineUnsafe(client *Client) {
+	for i, waiting := range h.waiting {
+		if waiting == client {
+			h.waiting = append(h.waiting[:i], h.waiting[i+1:]...)
+			break
+		}
+	}
+	if client.waitingBotTimer != nil {
+		client.waitingBotTimer.Stop()
+	}
+}
+
+// stopWaitingUnsafe takes client out of matchmaking, here and in the shared
+// queue. It returns false if another instance has already matched the
+// player. Must be called with h.mu held.
+func (h *Hub) stopWaitingUnsafe(client *Client) bool {
+	if !h.isWaitingUnsafe(client) {
+		return true
+	}
+	h.leaveLineUnsafe(client)
+	return h.dequeueWaitingUnsafe(client)
+}
+
+// matchLocalUnsafe pairs client with the longest waiting local player whose
+// rating is close enough. It returns true once client needs no further
+// matching, including when another instance has matched them meanwhile.
+// Must be called with h.mu held.
+func (h *Hub) matchLocalUnsafe(client *Client, now time.Time) bool {
+	for _, opponent := range append([]*Client(nil), h.waiting...) {
+		if opponent == client {
+			continue
+		}
+		since := opponent.waitingSince
+		if client.waitingSince.Before(since) {
+			since = client.waitingSince
+		}
+		if !h.acceptsUnsafe(opponent.rating, client.rating, since, now) {
+			continue
+		}
+		if !h.stopWaitingUnsafe(opponent) {
+			// Another instance matched the opponent; its gameStart is on the way
+			continue
+		}
+		if !h.stopWaitingUnsafe(client) {
+			h.queueUnsafe(opponent)
+			return true
+		}
+		// Whoever waited longer takes the first seat
+		first, second := opponent, client
+		if client.waitingSince.Before(opponent.waitingSince) {
+			first, second = client, opponent
+		}
+		h.createGame(first, second, game.DefaultSettings(), config.ModeFriend, nil, "")
+		return true
+	}
+	return false
+}
+
+// matchWaiting pairs players who have waited long enough for their rating
+// windows to cover each other, on this instance or across instances
+func (h *Hub) matchWaiting() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for _, client := range append([]*Client(nil), h.waiting...) {
+		if !h.isWaitingUnsafe(client) {
+			continue // paired earlier in this pass
+		}
+		if !h.matchLocalUnsafe(client, now) {
+			h.matchQueuedRemoteUnsafe(client, now)
+		}
+	}
+}
+
+// matchQueuedRemoteUnsafe pairs a queued local player with a player waiting
+// on another instance. Both leave the shared queue together, so neither can
+// be taken by a third instance meanwhile. Must be called with h.mu held.
+func (h *Hub) matchQueuedRemoteUnsafe(client *Client, now time.Time) {
+	if h.backplane == nil {
+		return
+	}
+	entry, ok := h.backplane.PairWaiting(client.username, h.instanceID, func(entry backplane.WaitingEntry) bool {
+		since := entry.QueuedAt
+		if client.waitingSince.Before(since) {
+			since = client.waitingSince
+		}
+		return h.acceptsUnsafe(entry.Rating, client.rating, since, now)
+	})
+	if !ok {
+		return
+	}
+	h.leaveLineUnsafe(client)
+	h.startRemoteGameUnsafe(entry, client)
+}
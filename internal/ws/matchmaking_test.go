@@ -0,0 +1,159 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/backplane"
+	"github.com/connect4/backend/internal/config"
+)
+
+// newMatchmakingHub creates a hub whose rating window is 100 wide and grows
+// by 10 for every second a player waits
+func newMatchmakingHub(t *testing.T) *Hub {
+	h := NewHub()
+	policies := config.DefaultPolicies()
+	policy := policies.Modes[config.ModeFriend]
+	policy.BotFallback = false
+	policy.RatingWindow = 100
+	policy.RatingWindowGrowth = 10
+	policies.Modes[config.ModeFriend] = policy
+	h.SetPolicies(policies)
+	return h
+}
+
+// queueRated puts client in line with the given rating as if it joined at since
+func queueRated(h *Hub, client *Client, rating int, since time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	client.rating = rating
+	client.waitingSince = since
+	h.queueUnsafe(client)
+}
+
+func TestAccepts(t *testing.T) {
+	h := newMatchmakingHub(t)
+	now := time.Now()
+	tests := []struct {
+		name    string
+		gap     int
+		waited  time.Duration
+		accepts bool
+	}{
+		{"same rating", 0, 0, true},
+		{"edge of the window", 100, 0, true},
+		{"outside the window", 101, 0, false},
+		{"widened by waiting", 300, 20 * time.Second, true},
+		{"not widened enough", 300, 19 * time.Second, false},
+		{"partial seconds do not count", 110, 1900 * time.Millisecond, true},
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, tt := range tests {
+		for _, gap := range []int{tt.gap, -tt.gap} {
+			if got := h.acceptsUnsafe(1500, 1500+gap, now.Add(-tt.waited), now); got != tt.accepts {
+				t.Errorf("%s (gap %d): accepts = %v, want %v", tt.name, gap, got, tt.accepts)
+			}
+		}
+	}
+
+	// A window of 0 pairs anyone
+	policy := h.policies.Modes[config.ModeFriend]
+	policy.RatingWindow = 0
+	h.policies.Modes[config.ModeFriend] = policy
+	if !h.acceptsUnsafe(800, 2400, now, now) {
+		t.Error("a window of 0 refused a pairing")
+	}
+}
+
+// TestRatingWindowMatchmaking keeps players far apart in rating waiting until
+// their window has grown to cover the gap, while close players pair at once
+func TestRatingWindowMatchmaking(t *testing.T) {
+	h := newMatchmakingHub(t)
+	sockets := newSocketServer(t)
+	now := time.Now()
+
+	strong := sockets.mustClient(t, h, "strong")
+	queueRated(h, strong, 1900, now)
+	weak := sockets.mustClient(t, h, "weak")
+	h.mu.Lock()
+	weak.rating, weak.waitingSince = 1500, now
+	matched := h.matchLocalUnsafe(weak, now)
+	if !matched {
+		h.queueUnsafe(weak)
+	}
+	h.mu.Unlock()
+	if matched {
+		t.Fatal("players 400 apart were paired before either had waited")
+	}
+
+	h.matchWaiting()
+	if id := currentGame(h, weak); id != "" {
+		t.Fatalf("players 400 apart were paired into %s before the window widened", id)
+	}
+
+	near := sockets.mustClient(t, h, "near")
+	h.mu.Lock()
+	near.rating, near.waitingSince = 1550, now
+	matched = h.matchLocalUnsafe(near, now)
+	h.mu.Unlock()
+	if !matched {
+		t.Fatal("players 50 apart were not paired at once")
+	}
+	if currentGame(h, near) == "" || currentGame(h, near) != currentGame(h, weak) {
+		t.Fatal("the close player was not seated with the player nearest in rating")
+	}
+
+	// 30 seconds of waiting widen the window to 400
+	other := sockets.mustClient(t, h, "other")
+	queueRated(h, other, 1500, now.Add(-30*time.Second))
+	h.matchWaiting()
+	gameID := currentGame(h, strong)
+	if gameID == "" || gameID != currentGame(h, other) {
+		t.Fatal("the widened window did not pair the waiting players")
+	}
+	h.mu.Lock()
+	waiting := len(h.waiting)
+	g := h.activeGames[gameID]
+	h.mu.Unlock()
+	if waiting != 0 {
+		t.Errorf("%d players still waiting", waiting)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.player1Client != other {
+		t.Errorf("%s took the first seat, want the player who waited longest", g.player1Client.username)
+	}
+}
+
+// TestRatingWindowAcrossInstances pairs players queued on different
+// instances once their window has widened, taking both from the shared queue
+func TestRatingWindowAcrossInstances(t *testing.T) {
+	a, b := newClusterHubs(t)
+	sockets := newSocketServer(t)
+	now := time.Now()
+
+	early := sockets.mustClient(t, a, "early")
+	queueRated(a, early, 1500, now.Add(-60*time.Second))
+	late := sockets.mustClient(t, b, "late")
+	queueRated(b, late, 1900, now)
+
+	b.matchWaiting()
+	gameID := currentGame(b, late)
+	if gameID == "" {
+		t.Fatal("the widened window did not pair players on different instances")
+	}
+	waitFor(t, "early to learn about the game", func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		return early.gameID == gameID && early.gameOwner == "b"
+	})
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.isWaitingUnsafe(early) {
+		t.Error("early is still waiting on instance a")
+	}
+	if entry, ok := a.backplane.TakeWaiting("", func(backplane.WaitingEntry) bool { return true }); ok {
+		t.Errorf("%s was left in the shared queue", entry.Username)
+	}
+}
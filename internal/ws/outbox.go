@@ -0,0 +1,168 @@
+package ws
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/connect4/backend/internal/metrics"
+)
+
+// Outbound queue limits. Past outboxSize only critical messages are queued;
+// a client whose queue reaches outboxHardLimit, or stays above outboxBehind
+// for slowConsumerTimeout, is disconnected.
+const (
+	outboxSize          = 256
+	outboxBehind        = 64
+	outboxHardLimit     = 1024
+	slowConsumerTimeout = 10 * time.Second
+)
+
+// closeReasonSlowConsumer is sent in the close frame of a dropped client
+const closeReasonSlowConsumer = "slow consumer"
+
+// maxCloseReason is the room left for the reason in a close frame
+const maxCloseReason = 123
+
+var (
+	droppedFrames   = metrics.NewCounter("ws_outbound_dropped_total", "Non-critical messages dropped because a client queue was full")
+	coalescedFrames = metrics.NewCounter("ws_outbound_coalesced_total", "Stale gameState messages replaced by a newer one before being sent")
+	slowConsumers   = metrics.NewCounter("ws_slow_consumer_disconnects_total", "Clients disconnected for falling too far behind")
+)
+
+// criticalMessage reports whether a message type must reach the client.
+// Game states are coalesced instead and leaderboard updates may be dropped.
+func criticalMessage(msgType string) bool {
+	switch msgType {
+	case MsgGameState, MsgLeaderboardUpdate:
+		return false
+	}
+	return true
+}
+
+// outbox is a client's queue of encoded frames waiting for the write pump
+type outbox struct {
+	mu          sync.Mutex
+	frames      []outboundFrame
+	wake        chan struct{} // signalled when frames are queued or the outbox closes
+	closed      bool
+	closeReason string // set when the client is dropped rather than closed normally
+	behindSince time.Time
+}
+
+type outboundFrame struct {
+	data     []byte
+	coalesce string // frames with the same key replace each other, empty if none
+}
+
+func newOutbox() *outbox {
+	return &outbox{wake: make(chan struct{}, 1)}
+}
+
+// push queues a frame and reports whether it will be sent. A queued game
+// state for the same game is replaced by the new one.
+func (o *outbox) push(msgType, gameID string, data []byte) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return false
+	}
+
+	var key string
+	if msgType == MsgGameState {
+		key = msgType + ":" + gameID
+		for i, f := range o.frames {
+			if f.coalesce == key {
+				o.frames = append(o.frames[:i], o.frames[i+1:]...)
+				coalescedFrames.Inc()
+				break
+			}
+		}
+	}
+
+	switch {
+	case len(o.frames) >= outboxHardLimit:
+		o.abortLocked(closeReasonSlowConsumer)
+		return false
+	case len(o.frames) >= outboxSize && !criticalMessage(msgType):
+		droppedFrames.Inc()
+		return false
+	}
+	o.frames = append(o.frames, outboundFrame{data: data, coalesce: key})
+
+	if len(o.frames) >= outboxBehind {
+		now := time.Now()
+		if o.behindSince.IsZero() {
+			o.behindSince = now
+		} else if now.Sub(o.behindSince) > slowConsumerTimeout {
+			o.abortLocked(closeReasonSlowConsumer)
+			return false
+		}
+	}
+	o.signal()
+	return true
+}
+
+// pop takes the oldest queued frame
+func (o *outbox) pop() ([]byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.frames) == 0 {
+		return nil, false
+	}
+	f := o.frames[0]
+	o.frames[0] = outboundFrame{}
+	o.frames = o.frames[1:]
+	if len(o.frames) < outboxBehind {
+		o.behindSince = time.Time{}
+	}
+	return f.data, true
+}
+
+// done reports whether the outbox is closed and drained, and why
+func (o *outbox) done() (bool, string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.closed && len(o.frames) == 0, o.closeReason
+}
+
+// close stops accepting frames; those already queued are still written
+func (o *outbox) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.signal()
+}
+
+// closeWithReason flushes the queued frames, then disconnects the client
+// with reason in the close frame
+func (o *outbox) closeWithReason(reason string) {
+	// Close frames carry at most 123 bytes of reason
+	if len(reason) > maxCloseReason {
+		reason = strings.ToValidUTF8(reason[:maxCloseReason], "")
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.closeReason = reason
+	o.signal()
+}
+
+// abortLocked discards the queue and disconnects the client with reason.
+// Must be called with o.mu held.
+func (o *outbox) abortLocked(reason string) {
+	log.Printf("[BACKEND-SEND] Dropping client with %d queued frames: %s", len(o.frames), reason)
+	slowConsumers.Inc()
+	o.frames = nil
+	o.closed = true
+	o.closeReason = reason
+	o.signal()
+}
+
+func (o *outbox) signal() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// drain pops every queued frame
+func drain(o *outbox) []string {
+	var frames []string
+	for {
+		data, ok := o.pop()
+		if !ok {
+			return frames
+		}
+		frames = append(frames, string(data))
+	}
+}
+
+// A queued game state is replaced by a newer one for the same game; states
+// of other games and other messages are kept in order
+func TestOutboxCoalescesGameStates(t *testing.T) {
+	o := newOutbox()
+	coalesced := coalescedFrames.Value()
+	o.push(MsgGameState, "g1", []byte("g1 state 1"))
+	o.push(MsgAck, "", []byte("ack"))
+	o.push(MsgGameState, "g2", []byte("g2 state 1"))
+	o.push(MsgGameState, "g1", []byte("g1 state 2"))
+	o.push(MsgGameFinished, "g1", []byte("g1 finished"))
+
+	want := []string{"ack", "g2 state 1", "g1 state 2", "g1 finished"}
+	if got := drain(o); strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+	if n := coalescedFrames.Value() - coalesced; n != 1 {
+		t.Errorf("%d frames counted as coalesced, want 1", n)
+	}
+}
+
+// Past outboxSize only critical messages are queued
+func TestOutboxDropsNonCritical(t *testing.T) {
+	o := newOutbox()
+	dropped := droppedFrames.Value()
+	for i := 0; i < outboxSize; i++ {
+		o.push(MsgAck, "", []byte("ack"))
+	}
+	if o.push(MsgLeaderboardUpdate, "", []byte("leaderboard")) {
+		t.Error("a leaderboard update was queued on a full outbox")
+	}
+	if !o.push(MsgGameFinished, "g1", []byte("finished")) {
+		t.Error("a critical message was refused on a full outbox")
+	}
+	if n := droppedFrames.Value() - dropped; n != 1 {
+		t.Errorf("%d frames counted as dropped, want 1", n)
+	}
+	if closed, _ := o.done(); closed {
+		t.Error("the client was dropped for a full outbox")
+	}
+}
+
+func TestOutboxDropsSlowConsumer(t *testing.T) {
+	tests := []struct {
+		name    string
+		queued  int
+		stalled time.Duration // how long the queue has been behind
+	}{
+		{"hard limit", outboxHardLimit, 0},
+		{"behind too long", outboxBehind, slowConsumerTimeout + time.Second},
+	}
+	for _, tt := range tests {
+		o := newOutbox()
+		for i := 0; i < tt.queued; i++ {
+			o.push(MsgAck, "", []byte("ack"))
+		}
+		if tt.stalled > 0 {
+			o.mu.Lock()
+			o.behindSince = time.Now().Add(-tt.stalled)
+			o.mu.Unlock()
+		}
+		slow := slowConsumers.Value()
+		if o.push(MsgAck, "", []byte("ack")) {
+			t.Errorf("%s: frame queued for a slow consumer", tt.name)
+		}
+		if closed, reason := o.done(); !closed || reason != closeReasonSlowConsumer {
+			t.Errorf("%s: closed %v with reason %q, want %q", tt.name, closed, reason, closeReasonSlowConsumer)
+		}
+		if n := slowConsumers.Value() - slow; n != 1 {
+			t.Errorf("%s: %d slow consumers counted, want 1", tt.name, n)
+		}
+		// The queue is discarded rather than written to a client that is not reading
+		if frames := drain(o); len(frames) != 0 {
+			t.Errorf("%s: %d frames left to send", tt.name, len(frames))
+		}
+		if o.push(MsgAck, "", []byte("ack")) {
+			t.Errorf("%s: frame queued after the client was dropped", tt.name)
+		}
+	}
+}
+
+// A consumer that catches up is no longer timed
+func TestOutboxCatchUp(t *testing.T) {
+	o := newOutbox()
+	for i := 0; i < outboxBehind; i++ {
+		o.push(MsgAck, "", []byte("ack"))
+	}
+	o.mu.Lock()
+	behind := !o.behindSince.IsZero()
+	o.mu.Unlock()
+	if !behind {
+		t.Fatal("a queue at outboxBehind is not timed")
+	}
+	o.pop()
+	o.mu.Lock()
+	behind = !o.behindSince.IsZero()
+	o.mu.Unlock()
+	if behind {
+		t.Error("the queue is still timed after catching up")
+	}
+}
+
+// closeWithReason keeps the queued frames and fits the reason in a close frame
+func TestOutboxCloseWithReason(t *testing.T) {
+	o := newOutbox()
+	o.push(MsgAnnouncement, "", []byte("goodbye"))
+	reason := strings.Repeat("é", maxCloseReason)
+	o.closeWithReason(reason)
+	if o.push(MsgAck, "", []byte("ack")) {
+		t.Error("frame queued after closing")
+	}
+	if closed, _ := o.done(); closed {
+		t.Error("done before the queued frames were sent")
+	}
+	if frames := drain(o); len(frames) != 1 || frames[0] != "goodbye" {
+		t.Errorf("sent %q, want the announcement", frames)
+	}
+	closed, got := o.done()
+	if !closed || len(got) > maxCloseReason || !utf8.ValidString(got) || !strings.HasPrefix(reason, got) {
+		t.Errorf("closed %v with a %d byte reason %q", closed, len(got), got)
+	}
+}
+
+// A dropped client's connection is closed with the reason in the close frame
+func TestSlowConsumerDisconnected(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	p := dialPlayer(t, srv)
+	joinLobby(t, p, "alice")
+
+	h.mu.Lock()
+	var out *outbox
+	for c := range h.clients {
+		if c.username == "alice" {
+			out = c.send.Load()
+		}
+	}
+	h.mu.Unlock()
+	// What push does when the queue overflows
+	out.mu.Lock()
+	out.abortLocked(closeReasonSlowConsumer)
+	out.mu.Unlock()
+	if reason := p.closed(t); reason != closeReasonSlowConsumer {
+		t.Errorf("closed with %q, want %q", reason, closeReasonSlowConsumer)
+	}
+}
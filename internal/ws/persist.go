@@ -0,0 +1,263 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/connect4/backend/internal/game"
+)
+
+// SnapshotStore is durable storage for in-progress games
+type SnapshotStore interface {
+	SaveGameSnapshot(ctx context.Context, gameID string, snapshot []byte) error
+	DeleteGameSnapshot(ctx context.Context, gameID string) error
+	LoadGameSnapshots(ctx context.Context) (map[string][]byte, error)
+}
+
+// gameSnapshot is what gets stored for each active game
+type gameSnapshot struct {
+	Game  game.Snapshot   `json:"game"`
+	Seats [2]seatSnapshot `json:"seats"`
+	Mode  string          `json:"mode,omitempty"`
+	// Event numbering continues after a restore; the log itself is not kept
+	EventSeq uint64  `json:"eventSeq"`
+	Series   *series `json:"series,omitempty"`
+}
+
+// seatSnapshot records who sits in a seat and how they can reclaim it
+type seatSnapshot struct {
+	Username     string `json:"username"`
+	IsBot        bool   `json:"isBot"`
+	SessionToken string `json:"sessionToken,omitempty"`
+}
+
+// persistOp is a queued write to the snapshot store. A nil snapshot deletes
+// the stored game; a non-nil done channel is closed once the op is handled.
+type persistOp struct {
+	gameID   string
+	snapshot []byte
+	done     chan struct{}
+}
+
+// SetSnapshotStore enables game persistence (optional)
+func (h *Hub) SetSnapshotStore(store SnapshotStore) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.snapshotStore != nil {
+		return
+	}
+	h.snapshotStore = store
+	h.persistQueue = make(chan persistOp, 1024)
+	go h.persistLoop()
+}
+
+// persistLoop writes snapshots in the order they were queued
+func (h *Hub) persistLoop() {
+	for op := range h.persistQueue {
+		if op.gameID != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			var err error
+			if op.snapshot == nil {
+				err = h.snapshotStore.DeleteGameSnapshot(ctx, op.gameID)
+			} else {
+				err = h.snapshotStore.SaveGameSnapshot(ctx, op.gameID, op.snapshot)
+			}
+			cancel()
+			if err != nil {
+				log.Printf("[BACKEND-PERSIST] Failed to persist game %s: %v", op.gameID, err)
+			}
+		}
+		if op.done != nil {
+			close(op.done)
+		}
+	}
+}
+
+// persistGameLocked queues a snapshot of g, or its removal once the game is
+// over. Must be called with g.mu held and h.mu not held.
+func (h *Hub) persistGameLocked(g *WSGame) {
+	if h.snapshotStore == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.persistGameUnsafe(g)
+}
+
+// persistGameUnsafe is persistGameLocked for callers that already hold h.mu.
+// Must be called with g.mu and h.mu held.
+func (h *Hub) persistGameUnsafe(g *WSGame) {
+	if h.snapshotStore == nil {
+		return
+	}
+	if !g.game.IsActive {
+		h.enqueuePersist(persistOp{gameID: g.game.ID})
+		return
+	}
+
+	data, err := json.Marshal(h.snapshotGameUnsafe(g))
+	if err != nil {
+		log.Printf("[BACKEND-PERSIST] Failed to marshal snapshot of game %s: %v", g.game.ID, err)
+		return
+	}
+	h.enqueuePersist(persistOp{gameID: g.game.ID, snapshot: data})
+}
+
+// forgetGameUnsafe queues the removal of a stored game
+func (h *Hub) forgetGameUnsafe(gameID string) {
+	if h.snapshotStore == nil || gameID == "" {
+		return
+	}
+	h.enqueuePersist(persistOp{gameID: gameID})
+}
+
+// enqueuePersist hands op to the persist loop without blocking
+func (h *Hub) enqueuePersist(op persistOp) {
+	select {
+	case h.persistQueue <- op:
+	default:
+		log.Printf("[BACKEND-PERSIST] Persist queue full, dropping write for game %s", op.gameID)
+	}
+}
+
+// snapshotGameUnsafe captures a game together with its seats. Must be called
+// with g.mu and h.mu held, since the session tokens belong to the hub.
+func (h *Hub) snapshotGameUnsafe(g *WSGame) gameSnapshot {
+	snap := gameSnapshot{Game: g.game.Snapshot(), Mode: g.mode, EventSeq: g.eventSeq, Series: g.series}
+	for i, c := range []*Client{g.player1Client, g.player2Client} {
+		if c == nil {
+			continue
+		}
+		snap.Seats[i] = seatSnapshot{
+			Username:     c.username,
+			IsBot:        c.isBot,
+			SessionToken: c.sessionToken,
+		}
+	}
+	return snap
+}
+
+// SnapshotAll writes every active game to the store and waits until all
+// queued writes are done. It is meant to run on shutdown.
+func (h *Hub) SnapshotAll() error {
+	h.mu.Lock()
+	if h.snapshotStore == nil {
+		h.mu.Unlock()
+		return nil
+	}
+	games := make([]*WSGame, 0, len(h.activeGames))
+	for _, g := range h.activeGames {
+		games = append(games, g)
+	}
+	queue := h.persistQueue
+	h.mu.Unlock()
+
+	count := 0
+	for _, g := range games {
+		g.mu.Lock()
+		if !g.closed {
+			h.persistGameLocked(g)
+			count++
+		}
+		g.mu.Unlock()
+	}
+	done := make(chan struct{})
+	// The marker must not be dropped, so block until there is room
+	queue <- persistOp{done: done}
+	select {
+	case <-done:
+		log.Printf("[BACKEND-PERSIST] Snapshotted %d active games", count)
+		return nil
+	case <-time.After(10 * time.Second):
+		log.Printf("[BACKEND-PERSIST] Timed out waiting for snapshots to be written")
+		return context.DeadlineExceeded
+	}
+}
+
+// RestoreGames loads stored games back into the hub. Their players appear as
+// disconnected and can reclaim their seats with the session token from
+// gameStart within the usual reconnect window.
+func (h *Hub) RestoreGames(ctx context.Context) error {
+	h.mu.Lock()
+	store := h.snapshotStore
+	h.mu.Unlock()
+	if store == nil {
+		return nil
+	}
+
+	snapshots, err := store.LoadGameSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for gameID, data := range snapshots {
+		var snap gameSnapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			log.Printf("[BACKEND-PERSIST] Discarding unreadable snapshot of game %s: %v", gameID, err)
+			h.forgetGameUnsafe(gameID)
+			continue
+		}
+		if !snap.Game.IsActive {
+			h.forgetGameUnsafe(gameID)
+			continue
+		}
+		if _, exists := h.activeGames[gameID]; exists {
+			continue
+		}
+
+		db := h.db
+		if !snap.Game.Settings.Rated {
+			db = nil
+		}
+		g := game.RestoreGame(db, snap.Game)
+		wsGame := &WSGame{
+			game:     g,
+			hub:      h,
+			mode:     snap.Mode,
+			policy:   h.policyUnsafe(snap.Mode),
+			eventSeq: snap.EventSeq,
+			series:   snap.Series,
+		}
+		// Not registered yet, so nobody can be waiting for the lock
+		wsGame.mu.Lock()
+		wsGame.syncRunning()
+
+		now := time.Now()
+		seats := make([]*Client, 2)
+		players := [2]game.Player{g.Player1, g.Player2}
+		for i, seat := range snap.Seats {
+			c := &Client{
+				hub:          h,
+				username:     seat.Username,
+				gameID:       g.ID,
+				isBot:        seat.IsBot,
+				sessionToken: seat.SessionToken,
+				botAccount:   players[i].BotAccount,
+			}
+			if !seat.IsBot {
+				c.disconnectedAt = &now
+			}
+			h.clients[c] = true
+			seats[i] = c
+		}
+		wsGame.player1Client = seats[0]
+		wsGame.player2Client = seats[1]
+		h.activeGames[g.ID] = wsGame
+
+		for _, c := range seats {
+			if !c.isBot {
+				h.armReconnectWindowUnsafe(c, wsGame)
+			}
+		}
+		h.scheduleClockTimeout(wsGame)
+		h.scheduleBotMoveLocked(wsGame)
+		wsGame.mu.Unlock()
+		log.Printf("[BACKEND-PERSIST] Restored game %s (%s vs %s)", g.ID, g.Player1.Username, g.Player2.Username)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// memSnapshotStore keeps snapshots in memory, as a restarted server would
+// find them in the database
+type memSnapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string][]byte
+}
+
+func newMemSnapshotStore() *memSnapshotStore {
+	return &memSnapshotStore{snapshots: make(map[string][]byte)}
+}
+
+func (s *memSnapshotStore) SaveGameSnapshot(ctx context.Context, gameID string, snapshot []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[gameID] = snapshot
+	return nil
+}
+
+func (s *memSnapshotStore) DeleteGameSnapshot(ctx context.Context, gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.snapshots, gameID)
+	return nil
+}
+
+func (s *memSnapshotStore) LoadGameSnapshots(ctx context.Context) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshots := make(map[string][]byte, len(s.snapshots))
+	for id, data := range s.snapshots {
+		snapshots[id] = data
+	}
+	return snapshots, nil
+}
+
+func (s *memSnapshotStore) stored(gameID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.snapshots[gameID]
+	return ok
+}
+
+// TestGameSurvivesRestart persists a game on one hub and restores it on
+// another. Both players take their seats back with their session tokens and
+// play on.
+func TestGameSurvivesRestart(t *testing.T) {
+	store := newMemSnapshotStore()
+	before := NewHub()
+	before.SetSnapshotStore(store)
+	first, second, firstStart, secondStart := joinFriendGame(t, newPlayerServer(t, before), "alice", "bob")
+	gameID := firstStart.ID
+
+	first.send(t, MsgMove, "m1", map[string]int{"column": 3})
+	expectReply(t, first.peer, MsgAck, "m1")
+	second.send(t, MsgMove, "m2", map[string]int{"column": 4})
+	expectReply(t, second.peer, MsgAck, "m2")
+	if err := before.SnapshotAll(); err != nil {
+		t.Fatal(err)
+	}
+	if !store.stored(gameID) {
+		t.Fatal("the game was not stored")
+	}
+
+	// Snapshots that cannot be restored are dropped
+	store.SaveGameSnapshot(context.Background(), "unreadable", []byte("{"))
+	store.SaveGameSnapshot(context.Background(), "finished", []byte(`{"game": {"id": "finished", "isActive": false}}`))
+
+	after := NewHub()
+	after.SetSnapshotStore(store)
+	if err := after.RestoreGames(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	srv := newPlayerServer(t, after)
+	waitFor(t, "bad snapshots to be dropped", func() bool {
+		return !store.stored("unreadable") && !store.stored("finished")
+	})
+
+	for i, start := range []GameStartPayload{firstStart, secondStart} {
+		p := dialPlayer(t, srv)
+		p.send(t, MsgResume, "r1", map[string]string{"sessionToken": start.SessionToken})
+		msg := p.expect(t, MsgGameState)
+		var state GameStatePayload
+		if perr := msg.decodePayload(&state); perr != nil {
+			t.Fatal(perr)
+		}
+		if state.ID != gameID || state.You == nil || state.You.Seat != start.You.Seat {
+			t.Fatalf("player %d resumed into %s", i+1, msg.Payload)
+		}
+		bottom := state.Board[len(state.Board)-1]
+		if bottom[3] != firstStart.You.Seat || bottom[4] != secondStart.You.Seat || state.CurrentTurn != firstStart.You.Seat {
+			t.Errorf("player %d resumed into board %v with seat %d to move", i+1, state.Board, state.CurrentTurn)
+		}
+		if i == 0 {
+			first = p
+		}
+	}
+
+	first.send(t, MsgMove, "m3", map[string]int{"column": 3, "moveNumber": 2})
+	expectReply(t, first.peer, MsgAck, "m3")
+
+	// A finished game is removed from the store
+	first.send(t, MsgExitGame, "x1", nil)
+	expectReply(t, first.peer, MsgAck, "x1")
+	waitFor(t, "the finished game to be removed", func() bool { return !store.stored(gameID) })
+}
+
+// The seats of a stored game carry the session tokens that reclaim them
+func TestSnapshotSeats(t *testing.T) {
+	store := newMemSnapshotStore()
+	h := NewHub()
+	h.SetSnapshotStore(store)
+	_, _, firstStart, secondStart := joinFriendGame(t, newPlayerServer(t, h), "alice", "bob")
+	if err := h.SnapshotAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshots, _ := store.LoadGameSnapshots(context.Background())
+	var snap gameSnapshot
+	if err := json.Unmarshal(snapshots[firstStart.ID], &snap); err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, start := range []GameStartPayload{firstStart, secondStart} {
+		seat := snap.Seats[start.You.Seat-1]
+		if seat.SessionToken != start.SessionToken || seat.IsBot {
+			t.Errorf("seat %d stored as %+v, want the token %s", start.You.Seat, seat, start.SessionToken)
+		}
+		names[seat.Username] = true
+	}
+	if !names["alice"] || !names["bob"] {
+		t.Errorf("seats stored as %+v", snap.Seats)
+	}
+	if snap.Mode != "friend" || !snap.Game.IsActive || snap.Game.ID != firstStart.ID {
+		t.Errorf("stored mode %q, game %s active %v", snap.Mode, snap.Game.ID, snap.Game.IsActive)
+	}
+}
@@ -0,0 +1,220 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/connect4/backend/internal/backplane"
+)
+
+// Presence states of an online player
+const (
+	PresenceIdle       = "idle"       // online, not in a game or queue
+	PresenceQueued     = "queued"     // waiting for a matchmaking opponent
+	PresencePlaying    = "playing"    // seated in a running game
+	PresenceSpectating = "spectating" // watching someone else's game
+	PresenceAway       = "away"       // disconnected, seat held for the reconnect window
+)
+
+// Presence delta events
+const (
+	PresenceJoin   = "join"
+	PresenceLeave  = "leave"
+	PresenceStatus = "status"
+)
+
+// presenceOfUnsafe derives a local client's presence state. Must be called
+// with h.mu held.
+func (h *Hub) presenceOfUnsafe(client *Client) string {
+	if client.disconnectedAt != nil {
+		return PresenceAway
+	}
+	if client.gameOwner != "" {
+		return PresencePlaying
+	}
+	if g, exists := h.activeGames[client.gameID]; exists && g.isRunning() {
+		return PresencePlaying
+	}
+	if h.isWaitingUnsafe(client) {
+		return PresenceQueued
+	}
+	if client.spectating != "" {
+		return PresenceSpectating
+	}
+	return PresenceIdle
+}
+
+// refreshPresenceUnsafe recomputes a local player's presence and, when it
+// changed, publishes it to the backplane and pushes a delta to subscribers.
+// A client no longer registered with the hub has left. Must be called with
+// h.mu held.
+func (h *Hub) refreshPresenceUnsafe(client *Client) {
+	if client == nil || client.isBot || client.username == "" || client.remoteInstance != "" {
+		return
+	}
+
+	status := ""
+	if _, ok := h.clients[client]; ok {
+		status = h.presenceOfUnsafe(client)
+	}
+	previous, known := h.presence[client.username]
+
+	delta := PresenceDeltaPayload{Username: client.username, Status: status}
+	switch {
+	case status == "" && !known:
+		return
+	case status == "":
+		// Another connection may still be using the name
+		if other := h.findLocalClientUnsafe(client.username); other != nil && other != client {
+			h.refreshPresenceUnsafe(other)
+			return
+		}
+		delete(h.presence, client.username)
+		if h.backplane != nil {
+			h.backplane.RemovePresence(client.username, h.instanceID)
+		}
+		delta.Event = PresenceLeave
+	case status == previous:
+		return
+	default:
+		h.presence[client.username] = status
+		if h.backplane != nil {
+			h.backplane.SetPresence(backplane.Presence{
+				Username:   client.username,
+				InstanceID: h.instanceID,
+				Status:     status,
+				UpdatedAt:  time.Now(),
+			})
+		}
+		delta.Event = PresenceStatus
+		if !known {
+			delta.Event = PresenceJoin
+		}
+	}
+
+	env := Envelope{Type: MsgPresence, Payload: delta}
+	for sub := range h.presenceSubscribers {
+		sub.sendEnvelope(env)
+	}
+	if h.backplane != nil {
+		data, err := json.Marshal(env)
+		if err != nil {
+			return
+		}
+		h.backplane.PublishAll(backplane.Envelope{
+			Kind: backplane.KindPresence,
+			From: h.instanceID,
+			Data: data,
+		})
+	}
+}
+
+// deliverPresence passes a presence delta from another instance on to the
+// local subscribers
+func (h *Hub) deliverPresence(env backplane.Envelope) {
+	var msg rawMessage
+	if err := json.Unmarshal(env.Data, &msg); err != nil {
+		log.Printf("[BACKEND-PRESENCE] Invalid presence delta from %s: %v", env.From, err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.presenceSubscribers {
+		sub.sendEnvelope(msg.envelope())
+	}
+}
+
+// subscribePresence sends the client the current online list, followed by
+// every change to it until the client unsubscribes or disconnects
+func (h *Hub) subscribePresence(client *Client) *ProtocolError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.presenceSubscribers[client] = true
+	client.sendEnvelope(Envelope{
+		Type:    MsgPresenceSnapshot,
+		Payload: PresenceSnapshotPayload{Users: h.activeUsersUnsafe()},
+	})
+	return nil
+}
+
+// unsubscribePresence stops presence deltas for the client
+func (h *Hub) unsubscribePresence(client *Client) *ProtocolError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.presenceSubscribers, client)
+	return nil
+}
+
+// handleSpectate lets a client that is not playing watch a game on this
+// instance. The client gets the current state, then every game event.
+func (h *Hub) handleSpectate(client *Client, gameID string) *ProtocolError {
+	h.mu.Lock()
+	joined := client.username != ""
+	g := h.activeGames[gameID]
+	h.mu.Unlock()
+	if !joined {
+		return newProtocolError(ErrCodeNotJoined, "join before spectating")
+	}
+	if g == nil {
+		return newProtocolError(ErrCodeNoSuchGame, "game %s not found", gameID)
+	}
+
+	// Holding the game lock keeps its events from overtaking the snapshot
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.presenceOfUnsafe(client) == PresencePlaying {
+		return newProtocolError(ErrCodeAlreadyInGame, "you are playing a game")
+	}
+	if g.closed {
+		return newProtocolError(ErrCodeNoSuchGame, "game %s not found", gameID)
+	}
+
+	h.stopSpectatingUnsafe(client)
+	g.watchMu.Lock()
+	if g.spectators == nil {
+		g.spectators = make(map[*Client]bool)
+	}
+	g.spectators[client] = true
+	g.watchMu.Unlock()
+	client.spectating = gameID
+	client.sendEnvelope(Envelope{
+		Type:     MsgGameState,
+		GameID:   g.game.ID,
+		EventSeq: g.eventSeq,
+		Payload:  g.ToGameState(),
+	})
+	h.refreshPresenceUnsafe(client)
+	return nil
+}
+
+// handleStopSpectating stops watching the current game
+func (h *Hub) handleStopSpectating(client *Client) *ProtocolError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if client.spectating == "" {
+		return newProtocolError(ErrCodeNoSuchGame, "you are not spectating")
+	}
+	h.stopSpectatingUnsafe(client)
+	h.refreshPresenceUnsafe(client)
+	return nil
+}
+
+// stopSpectatingUnsafe detaches a client from the game it watches.
+// Must be called with h.mu held.
+func (h *Hub) stopSpectatingUnsafe(client *Client) {
+	if client.spectating == "" {
+		return
+	}
+	if g, exists := h.activeGames[client.spectating]; exists {
+		g.watchMu.Lock()
+		delete(g.spectators, client)
+		g.watchMu.Unlock()
+	}
+	client.spectating = ""
+}
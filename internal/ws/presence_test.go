@@ -0,0 +1,162 @@
+package ws
+
+import "testing"
+
+// expectPresence waits for the next presence delta about username
+func expectPresence(t *testing.T, p *player, username string) PresenceDeltaPayload {
+	t.Helper()
+	for {
+		msg := p.expect(t, MsgPresence)
+		var delta PresenceDeltaPayload
+		if perr := msg.decodePayload(&delta); perr != nil {
+			t.Fatal(perr)
+		}
+		if delta.Username == username {
+			return delta
+		}
+	}
+}
+
+// presenceOf returns username's status in the hub's online list
+func presenceOf(h *Hub, username string) string {
+	for _, u := range h.GetActiveUsers() {
+		if u.Username == username {
+			return u.Status
+		}
+	}
+	return ""
+}
+
+// TestPresenceDeltas subscribes to the online list and follows players as
+// they queue, play, drop out and leave
+func TestPresenceDeltas(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	watcher := dialPlayer(t, srv)
+	joinLobby(t, watcher, "watcher")
+	watcher.send(t, MsgSubscribePresence, "s1", nil)
+	msg := watcher.expect(t, MsgPresenceSnapshot)
+	var snapshot PresenceSnapshotPayload
+	if perr := msg.decodePayload(&snapshot); perr != nil {
+		t.Fatal(perr)
+	}
+	if len(snapshot.Users) != 1 || snapshot.Users[0] != (ActiveUser{Username: "watcher", Status: PresenceIdle}) {
+		t.Fatalf("presence snapshot %s", msg.Payload)
+	}
+	expectReply(t, watcher.peer, MsgAck, "s1")
+
+	alice := dialPlayer(t, srv)
+	alice.send(t, MsgJoin, "", JoinPayload{Username: "alice", GameMode: "friend", ProtocolVersion: ProtocolVersion})
+	if delta := expectPresence(t, watcher, "alice"); delta != (PresenceDeltaPayload{Event: PresenceJoin, Username: "alice", Status: PresenceQueued}) {
+		t.Errorf("alice queued: %+v", delta)
+	}
+	bob := dialPlayer(t, srv)
+	bob.send(t, MsgJoin, "", JoinPayload{Username: "bob", GameMode: "friend", ProtocolVersion: ProtocolVersion})
+	if delta := expectPresence(t, watcher, "alice"); delta != (PresenceDeltaPayload{Event: PresenceStatus, Username: "alice", Status: PresencePlaying}) {
+		t.Errorf("alice paired: %+v", delta)
+	}
+	if delta := expectPresence(t, watcher, "bob"); delta != (PresenceDeltaPayload{Event: PresenceJoin, Username: "bob", Status: PresencePlaying}) {
+		t.Errorf("bob paired: %+v", delta)
+	}
+
+	alice.conn.Close()
+	if delta := expectPresence(t, watcher, "alice"); delta != (PresenceDeltaPayload{Event: PresenceStatus, Username: "alice", Status: PresenceAway}) {
+		t.Errorf("alice dropped out: %+v", delta)
+	}
+
+	carol := dialPlayer(t, srv)
+	joinLobby(t, carol, "carol")
+	if delta := expectPresence(t, watcher, "carol"); delta != (PresenceDeltaPayload{Event: PresenceJoin, Username: "carol", Status: PresenceIdle}) {
+		t.Errorf("carol joined: %+v", delta)
+	}
+	carol.conn.Close()
+	if delta := expectPresence(t, watcher, "carol"); delta != (PresenceDeltaPayload{Event: PresenceLeave, Username: "carol"}) {
+		t.Errorf("carol left: %+v", delta)
+	}
+
+	watcher.send(t, MsgUnsubscribePresence, "u1", nil)
+	expectReply(t, watcher.peer, MsgAck, "u1")
+	dave := dialPlayer(t, srv)
+	joinLobby(t, dave, "dave")
+	// Anything pushed before dave's ack is queued ahead of the watcher's reply
+	watcher.send(t, "noop", "n1", nil)
+	watcher.expectError(t, "n1")
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	for _, m := range watcher.msgs {
+		var delta PresenceDeltaPayload
+		if m.Type == MsgPresence && m.decodePayload(&delta) == nil && delta.Username == "dave" {
+			t.Errorf("delta %s pushed after unsubscribing", m.Payload)
+		}
+	}
+}
+
+// TestSpectate watches a game: the spectator gets its state, then its
+// events, until they stop watching
+func TestSpectate(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	first, second, start, _ := joinFriendGame(t, srv, "alice", "bob")
+	carol := dialPlayer(t, srv)
+	joinLobby(t, carol, "carol")
+
+	first.send(t, MsgMove, "m1", map[string]int{"column": 3})
+	expectReply(t, first.peer, MsgAck, "m1")
+	carol.send(t, MsgSpectate, "w1", SpectatePayload{GameID: start.ID})
+	msg := carol.expect(t, MsgGameState)
+	expectReply(t, carol.peer, MsgAck, "w1")
+	var state GameStatePayload
+	if perr := msg.decodePayload(&state); perr != nil {
+		t.Fatal(perr)
+	}
+	if msg.GameID != start.ID || msg.EventSeq != 1 || state.You != nil || state.Board[len(state.Board)-1][3] != start.You.Seat {
+		t.Errorf("spectating from event %d: %s", msg.EventSeq, msg.Payload)
+	}
+	if status := presenceOf(h, "carol"); status != PresenceSpectating {
+		t.Errorf("carol is %q while spectating", status)
+	}
+
+	second.send(t, MsgMove, "m2", map[string]int{"column": 4})
+	if msg := carol.expect(t, MsgGameState); msg.EventSeq != 2 {
+		t.Errorf("spectator got event %d, want 2", msg.EventSeq)
+	}
+
+	carol.send(t, MsgStopSpectating, "w2", nil)
+	expectReply(t, carol.peer, MsgAck, "w2")
+	if status := presenceOf(h, "carol"); status != PresenceIdle {
+		t.Errorf("carol is %q after spectating", status)
+	}
+	first.send(t, MsgMove, "m3", map[string]int{"column": 3})
+	expectReply(t, first.peer, MsgAck, "m3")
+	// The move was pushed to every watcher before its ack
+	carol.send(t, "noop", "n1", nil)
+	carol.expectError(t, "n1")
+	carol.mu.Lock()
+	for _, m := range carol.msgs {
+		if m.Type == MsgGameState && m.EventSeq > 2 {
+			t.Errorf("event %d pushed after the spectator stopped watching", m.EventSeq)
+		}
+	}
+	carol.mu.Unlock()
+
+	stranger := dialPlayer(t, srv)
+	tests := []struct {
+		name   string
+		p      *player
+		msg    string
+		gameID string
+		code   string
+	}{
+		{"not joined", stranger, MsgSpectate, start.ID, ErrCodeNotJoined},
+		{"unknown game", carol, MsgSpectate, "unknown", ErrCodeNoSuchGame},
+		{"own game", first, MsgSpectate, start.ID, ErrCodeAlreadyInGame},
+		{"not spectating", carol, MsgStopSpectating, "", ErrCodeNoSuchGame},
+	}
+	for i, tt := range tests {
+		requestID := string(rune('a' + i))
+		tt.p.send(t, tt.msg, requestID, SpectatePayload{GameID: tt.gameID})
+		if code := tt.p.expectError(t, requestID); code != tt.code {
+			t.Errorf("%s: error %s, want %s", tt.name, code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/connect4/backend/internal/game"
+)
+
+// Protocol versions. Version 1 is the original untyped protocol; clients
+// that do not send a version on join are assumed to speak it.
+const (
+	ProtocolVersion    = 2
+	MinProtocolVersion = 1
+)
+
+// Client → server message types
+const (
+	MsgJoin                = "join"
+	MsgMove                = "move"
+	MsgCancelWaiting       = "cancelWaiting"
+	MsgResume              = "resume"
+	MsgChallenge           = "challenge"
+	MsgAcceptChallenge     = "acceptChallenge"
+	MsgDeclineChallenge    = "declineChallenge"
+	MsgPlayAgain           = "playAgain"
+	MsgExitGame            = "exitGame"
+	MsgResync              = "resync"
+	MsgSubscribePresence   = "subscribePresence"
+	MsgUnsubscribePresence = "unsubscribePresence"
+	MsgSpectate            = "spectate"
+	MsgStopSpectating      = "stopSpectating"
+)
+
+// Server → client message types
+const (
+	MsgWelcome           = "welcome"
+	MsgAck               = "ack"
+	MsgError             = "error"
+	MsgGameStart         = "gameStart"
+	MsgGameState         = "gameState"
+	MsgGameFinished      = "gameFinished"
+	MsgPlayAgainUpdate   = "playAgainUpdate"
+	MsgOpponentExited    = "opponentExited"
+	MsgWaitingCancelled  = "waitingCancelled"
+	MsgLeaderboardUpdate = "leaderboardUpdate"
+	MsgChallengeReceived = "challengeReceived"
+	MsgChallengeSent     = "challengeSent"
+	MsgChallengeDeclined = "challengeDeclined"
+	MsgChallengeExpired  = "challengeExpired"
+	MsgChallengeCancel   = "challengeCancelled"
+	MsgResynced          = "resynced"
+	MsgTournamentRound   = "tournamentRound"
+	MsgPresenceSnapshot  = "presenceSnapshot"
+	MsgPresence          = "presence"
+	MsgAnnouncement      = "announcement"
+)
+
+// Stable error codes sent in error replies
+const (
+	ErrCodeInvalidMessage      = "invalid_message"
+	ErrCodeUnknownType         = "unknown_type"
+	ErrCodeUnsupportedProtocol = "unsupported_protocol"
+	ErrCodeNotJoined           = "not_joined"
+	ErrCodeNoSuchGame          = "no_such_game"
+	ErrCodeNotAPlayer          = "not_a_player"
+	ErrCodeNotYourTurn         = "not_your_turn"
+	ErrCodeColumnFull          = "column_full"
+	ErrCodeStaleMove           = "stale_move"
+	ErrCodeInvalidColumn       = "invalid_column"
+	ErrCodeOutOfTime           = "out_of_time"
+	ErrCodeGameOver            = "game_over"
+	ErrCodeGameInProgress      = "game_in_progress"
+	ErrCodeNotWaiting          = "not_waiting"
+	ErrCodeSessionInvalid      = "session_invalid"
+	ErrCodeNoSuchChallenge     = "no_such_challenge"
+	ErrCodeUserUnavailable     = "user_unavailable"
+	ErrCodeAlreadyInGame       = "already_in_game"
+	ErrCodeInvalidSettings     = "invalid_settings"
+	ErrCodeNotAllowed          = "not_allowed"
+	ErrCodeInvalidUsername     = "invalid_username"
+	ErrCodeUsernameTaken       = "username_taken"
+	ErrCodeBanned              = "banned"
+	ErrCodeUnauthorized        = "unauthorized"
+	ErrCodeRateLimited         = "rate_limited"
+	ErrCodeNoSuchWebhook       = "no_such_webhook"
+	ErrCodeInternal            = "internal_error"
+)
+
+// Envelope is the frame every server message is sent in. Seq increases by
+// one for each message a connection receives; RequestID echoes the client
+// request a reply belongs to. EventSeq numbers the events of a game and is
+// what a client quotes when it asks to resync.
+type Envelope struct {
+	Type      string      `json:"type"`
+	Seq       uint64      `json:"seq,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+	GameID    string      `json:"gameId,omitempty"`
+	EventSeq  uint64      `json:"eventSeq,omitempty"`
+	Payload   interface{} `json:"payload,omitempty"`
+}
+
+// rawMessage is a message whose payload has not been decoded yet. Client
+// requests arrive in this form, as do messages relayed between instances.
+type rawMessage struct {
+	Type      string          `json:"type"`
+	RequestID string          `json:"requestId,omitempty"`
+	GameID    string          `json:"gameId,omitempty"`
+	EventSeq  uint64          `json:"eventSeq,omitempty"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+// decodePayload decodes the payload into v, reporting malformed input as an
+// invalid_message error
+func (m *rawMessage) decodePayload(v interface{}) *ProtocolError {
+	if len(m.Payload) == 0 || bytes.Equal(m.Payload, []byte("null")) {
+		return newProtocolError(ErrCodeInvalidMessage, "%s requires a payload", m.Type)
+	}
+	if err := json.Unmarshal(m.Payload, v); err != nil {
+		return newProtocolError(ErrCodeInvalidMessage, "invalid %s payload: %v", m.Type, err)
+	}
+	return nil
+}
+
+// envelope converts a relayed message back into an Envelope
+func (m *rawMessage) envelope() Envelope {
+	env := Envelope{
+		Type:      m.Type,
+		RequestID: m.RequestID,
+		GameID:    m.GameID,
+		EventSeq:  m.EventSeq,
+	}
+	if len(m.Payload) > 0 {
+		env.Payload = m.Payload
+	}
+	return env
+}
+
+// ProtocolError is a request failure reported to the client with a stable code
+type ProtocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newProtocolError(code, format string, args ...interface{}) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Client → server payloads
+
+// JoinPayload is sent to enter matchmaking or the lobby. Version 1 clients
+// may send the bare username as a string instead.
+type JoinPayload struct {
+	Username        string `json:"username"`
+	GameMode        string `json:"gameMode,omitempty"`
+	SessionToken    string `json:"sessionToken,omitempty"`
+	ProtocolVersion int    `json:"protocolVersion,omitempty"`
+	// Take a numbered variant such as "alice-2" when the name is in use,
+	// instead of failing with username_taken
+	SuffixDuplicate bool `json:"suffixDuplicate,omitempty"`
+	// Registered bots announce themselves and authenticate with their API key
+	Bot    bool   `json:"bot,omitempty"`
+	APIKey string `json:"apiKey,omitempty"`
+}
+
+// MovePayload drops a disc into a column
+type MovePayload struct {
+	Column *int `json:"column"`
+	// When set, the move is rejected unless this many moves have been played
+	MoveNumber *int `json:"moveNumber,omitempty"`
+}
+
+// ResumePayload reattaches a connection to an existing session
+type ResumePayload struct {
+	SessionToken string `json:"sessionToken"`
+}
+
+// ChallengePayload challenges an online player
+type ChallengePayload struct {
+	Username string        `json:"username"`
+	Settings game.Settings `json:"settings"`
+}
+
+// ChallengeResponsePayload accepts or declines a challenge
+type ChallengeResponsePayload struct {
+	ChallengeID string `json:"challengeId"`
+}
+
+// PlayAgainPayload optionally proposes a best-of-N series for the rematch.
+// It is ignored while a series is still being played.
+type PlayAgainPayload struct {
+	BestOf int `json:"bestOf,omitempty"`
+}
+
+// ResyncPayload asks for the events of the current game after LastEventSeq
+type ResyncPayload struct {
+	GameID       string `json:"gameId,omitempty"`
+	LastEventSeq uint64 `json:"lastEventSeq"`
+}
+
+// SpectatePayload starts watching a game
+type SpectatePayload struct {
+	GameID string `json:"gameId"`
+}
+
+// Server → client payloads
+
+// WelcomePayload confirms a join and the negotiated protocol version
+type WelcomePayload struct {
+	Username           string `json:"username"`
+	ProtocolVersion    int    `json:"protocolVersion"`
+	MinProtocolVersion int    `json:"minProtocolVersion"`
+	MaxProtocolVersion int    `json:"maxProtocolVersion"`
+}
+
+// GameStartPayload is the per-player payload of a gameStart message
+type GameStartPayload struct {
+	*game.GameState
+	SessionToken string       `json:"sessionToken,omitempty"`
+	Series       *SeriesScore `json:"series,omitempty"`
+	You          *SeatPayload `json:"you,omitempty"`
+}
+
+// GameStatePayload is the state of a game sent to one player, e.g. after
+// a resume or resync
+type GameStatePayload struct {
+	*game.GameState
+	You *SeatPayload `json:"you,omitempty"`
+}
+
+// SeatPayload tells a player which side they are playing
+type SeatPayload struct {
+	Seat       int    `json:"seat"`  // 1 or 2, the value of their discs on the board
+	Color      string `json:"color"` // game.ColorRed or game.ColorYellow
+	MovesFirst bool   `json:"movesFirst"`
+}
+
+// WaitingPayload is sent as a gameState while a player waits for an opponent
+type WaitingPayload struct {
+	Status      game.GameStatus `json:"status"`
+	Board       [][]int         `json:"board"`
+	CurrentTurn int             `json:"currentTurn"`
+}
+
+// GameFinishedPayload announces the result of a game
+type GameFinishedPayload struct {
+	GameID string  `json:"gameId"`
+	IsDraw bool    `json:"isDraw"`
+	Winner *string `json:"winner"`
+	BotWon bool    `json:"botWon"`
+	Reason string  `json:"reason,omitempty"`
+	// Series is the score after this game when it belongs to a match series
+	Series *SeriesScore `json:"series,omitempty"`
+}
+
+// PlayAgainUpdatePayload lists who has asked for a rematch
+type PlayAgainUpdatePayload struct {
+	PlayAgainRequests []string `json:"playAgainRequests"`
+	BestOf            int      `json:"bestOf,omitempty"` // series length proposed for the rematch
+}
+
+// SeriesScore is the running score of a best-of-N match series
+type SeriesScore struct {
+	SeriesID string         `json:"seriesId"`
+	BestOf   int            `json:"bestOf"`
+	Game     int            `json:"game"`  // number of the game being or last played
+	Score    map[string]int `json:"score"` // wins by username
+	Draws    int            `json:"draws"`
+	Finished bool           `json:"finished"`
+	Winner   *string        `json:"winner,omitempty"` // nil while running or when drawn
+}
+
+// OpponentExitedPayload tells a player the opponent left the game
+type OpponentExitedPayload struct {
+	GameID  string `json:"gameId"`
+	Message string `json:"message"`
+}
+
+// WaitingCancelledPayload confirms a player left the matchmaking queue
+type WaitingCancelledPayload struct {
+	Message string `json:"message"`
+}
+
+// ResyncedPayload ends a resync, after the replayed events or the snapshot.
+// The envelope's EventSeq is the game's latest event.
+type ResyncedPayload struct {
+	Replayed int  `json:"replayed"`
+	Snapshot bool `json:"snapshot"`
+}
+
+// LeaderboardUpdatePayload tells clients a finished game changed the
+// leaderboard. Winner is only set for human winners so frontends do not
+// optimistically add bots to the leaderboard.
+type LeaderboardUpdatePayload struct {
+	GameID string  `json:"gameId"`
+	IsDraw bool    `json:"isDraw"`
+	Winner *string `json:"winner"`
+	BotWon bool    `json:"botWon,omitempty"`
+	// The winner is a registered bot and belongs on the bot leaderboard
+	WinnerIsBot bool `json:"winnerIsBot,omitempty"`
+}
+
+// PresenceSnapshotPayload lists everyone online when a client subscribes
+type PresenceSnapshotPayload struct {
+	Users []ActiveUser `json:"users"`
+}
+
+// PresenceDeltaPayload is one change to the online list. Status is empty
+// when the player left.
+type PresenceDeltaPayload struct {
+	Event    string `json:"event"` // PresenceJoin, PresenceLeave or PresenceStatus
+	Username string `json:"username"`
+	Status   string `json:"status,omitempty"`
+}
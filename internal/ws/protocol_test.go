@@ -0,0 +1,216 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestEnvelopeJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		env  Envelope
+		want string
+	}{
+		{"bare", Envelope{Type: MsgAck}, `{"type":"ack"}`},
+		{"reply", Envelope{Type: MsgAck, Seq: 3, RequestID: "r1"}, `{"type":"ack","seq":3,"requestId":"r1"}`},
+		{"game event", Envelope{Type: MsgGameState, Seq: 7, GameID: "g", EventSeq: 2, Payload: map[string]int{"turn": 1}},
+			`{"type":"gameState","seq":7,"gameId":"g","eventSeq":2,"payload":{"turn":1}}`},
+		{"error", Envelope{Type: MsgError, RequestID: "r2", Payload: newProtocolError(ErrCodeNotYourTurn, "it is not your turn")},
+			`{"type":"error","requestId":"r2","payload":{"code":"not_your_turn","message":"it is not your turn"}}`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: encoded as %s, want %s", tt.name, data, tt.want)
+		}
+	}
+}
+
+func TestDecodePayload(t *testing.T) {
+	tests := []struct {
+		payload string
+		code    string
+	}{
+		{"", ErrCodeInvalidMessage},
+		{"null", ErrCodeInvalidMessage},
+		{`"alice"`, ErrCodeInvalidMessage},
+		{`{"column": "3"}`, ErrCodeInvalidMessage},
+		{`{"column": 3}`, ""},
+	}
+	for _, tt := range tests {
+		msg := rawMessage{Type: MsgMove, Payload: json.RawMessage(tt.payload)}
+		var move MovePayload
+		perr := msg.decodePayload(&move)
+		switch {
+		case tt.code == "" && perr != nil:
+			t.Errorf("payload %q: %v", tt.payload, perr)
+		case tt.code == "" && (move.Column == nil || *move.Column != 3):
+			t.Errorf("payload %q decoded as %+v", tt.payload, move)
+		case tt.code != "" && (perr == nil || perr.Code != tt.code):
+			t.Errorf("payload %q: error %v, want %s", tt.payload, perr, tt.code)
+		}
+	}
+}
+
+// TestProtocolErrors sends malformed and refused requests over a socket.
+// Each is answered with an error carrying its request ID and a stable code.
+func TestProtocolErrors(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	stranger := dialPlayer(t, srv)
+	stranger.send(t, MsgMove, "s1", map[string]int{"column": 3})
+	if code := stranger.expectError(t, "s1"); code != ErrCodeNoSuchGame {
+		t.Errorf("moving before joining: %s, want %s", code, ErrCodeNoSuchGame)
+	}
+
+	first, second, _, _ := joinFriendGame(t, srv, "alice", "bob")
+	raw := []struct {
+		name      string
+		frame     string
+		requestID string
+		code      string
+	}{
+		{"not JSON", `{`, "", ErrCodeInvalidMessage},
+		{"no type", `{"requestId": "r1"}`, "r1", ErrCodeInvalidMessage},
+		{"unknown type", `{"type": "noop", "requestId": "r2"}`, "r2", ErrCodeUnknownType},
+		{"move without a payload", `{"type": "move", "requestId": "r3"}`, "r3", ErrCodeInvalidMessage},
+		{"move without a column", `{"type": "move", "requestId": "r4", "payload": {}}`, "r4", ErrCodeInvalidMessage},
+		{"column not a number", `{"type": "move", "requestId": "r5", "payload": {"column": "3"}}`, "r5", ErrCodeInvalidMessage},
+		{"resume without a payload", `{"type": "resume", "requestId": "r6"}`, "r6", ErrCodeInvalidMessage},
+	}
+	for _, tt := range raw {
+		if err := first.conn.WriteMessage(websocket.TextMessage, []byte(tt.frame)); err != nil {
+			t.Fatal(err)
+		}
+		if code := first.expectError(t, tt.requestID); code != tt.code {
+			t.Errorf("%s: error %s, want %s", tt.name, code, tt.code)
+		}
+	}
+
+	second.send(t, MsgMove, "m1", map[string]int{"column": 3})
+	if code := second.expectError(t, "m1"); code != ErrCodeNotYourTurn {
+		t.Errorf("moving out of turn: %s, want %s", code, ErrCodeNotYourTurn)
+	}
+	first.send(t, MsgMove, "m2", map[string]int{"column": 7})
+	if code := first.expectError(t, "m2"); code != ErrCodeInvalidColumn {
+		t.Errorf("column 7: %s, want %s", code, ErrCodeInvalidColumn)
+	}
+	first.send(t, MsgMove, "m3", map[string]int{"column": 3, "moveNumber": 1})
+	if code := first.expectError(t, "m3"); code != ErrCodeStaleMove {
+		t.Errorf("moving as if one move was played: %s, want %s", code, ErrCodeStaleMove)
+	}
+
+	// Alternating discs fill a column without a win
+	for i := 0; i < 6; i++ {
+		p := first
+		if i%2 == 1 {
+			p = second
+		}
+		requestID := string(rune('a' + i))
+		p.send(t, MsgMove, requestID, map[string]int{"column": 3, "moveNumber": i})
+		expectReply(t, p.peer, MsgAck, requestID)
+	}
+	first.send(t, MsgMove, "m4", map[string]int{"column": 3})
+	if code := first.expectError(t, "m4"); code != ErrCodeColumnFull {
+		t.Errorf("full column: %s, want %s", code, ErrCodeColumnFull)
+	}
+	// The refused requests left the game playable
+	first.send(t, MsgMove, "m5", map[string]int{"column": 0, "moveNumber": 6})
+	expectReply(t, first.peer, MsgAck, "m5")
+}
+
+func TestProtocolVersionNegotiation(t *testing.T) {
+	srv := newPlayerServer(t, NewHub())
+	tests := []struct {
+		name    string
+		payload interface{}
+		want    int // negotiated version, or 0 when no welcome is sent
+	}{
+		{"version 1", JoinPayload{Username: "alice", GameMode: "lobby", ProtocolVersion: 1}, 1},
+		{"current version", JoinPayload{Username: "bob", GameMode: "lobby", ProtocolVersion: ProtocolVersion}, ProtocolVersion},
+		{"newer than the server", JoinPayload{Username: "carol", GameMode: "lobby", ProtocolVersion: ProtocolVersion + 7}, ProtocolVersion},
+		// Version 1 clients send the bare username and expect no welcome
+		{"bare username", "dave", 0},
+	}
+	for _, tt := range tests {
+		p := dialPlayer(t, srv)
+		p.send(t, MsgJoin, "join", tt.payload)
+		expectReply(t, p.peer, MsgAck, "join")
+		var welcome *rawMessage
+		p.mu.Lock()
+		for i := range p.msgs {
+			if p.msgs[i].Type == MsgWelcome {
+				welcome = &p.msgs[i]
+			}
+		}
+		p.mu.Unlock()
+		if tt.want == 0 {
+			if welcome != nil {
+				t.Errorf("%s: welcomed with %s", tt.name, welcome.Payload)
+			}
+			continue
+		}
+		if welcome == nil {
+			t.Errorf("%s: no welcome", tt.name)
+			continue
+		}
+		var w WelcomePayload
+		if perr := welcome.decodePayload(&w); perr != nil {
+			t.Fatal(perr)
+		}
+		if w.ProtocolVersion != tt.want || w.MinProtocolVersion != MinProtocolVersion || w.MaxProtocolVersion != ProtocolVersion || welcome.RequestID != "join" {
+			t.Errorf("%s: welcomed with %s, request %q", tt.name, welcome.Payload, welcome.RequestID)
+		}
+	}
+
+	old := dialPlayer(t, srv)
+	// Version 0 is the absent version, so the first one below it is -1
+	old.send(t, MsgJoin, "join", JoinPayload{Username: "erin", ProtocolVersion: -1})
+	if code := old.expectError(t, "join"); code != ErrCodeUnsupportedProtocol {
+		t.Errorf("version -1: %s, want %s", code, ErrCodeUnsupportedProtocol)
+	}
+}
+
+// Every message a connection receives carries the next sequence number
+func TestEnvelopeSeq(t *testing.T) {
+	srv := newPlayerServer(t, NewHub())
+	header := http.Header{"Origin": {"http://localhost:3000"}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	requests := []map[string]interface{}{
+		{"type": MsgJoin, "requestId": "join", "payload": JoinPayload{Username: "alice", GameMode: "lobby", ProtocolVersion: ProtocolVersion}},
+		{"type": "noop", "requestId": "noop"},
+		{"type": MsgSubscribePresence, "requestId": "sub"},
+	}
+	var seq uint64
+	for _, req := range requests {
+		if err := conn.WriteJSON(req); err != nil {
+			t.Fatal(err)
+		}
+		requestID := req["requestId"]
+		for {
+			var env Envelope
+			if err := conn.ReadJSON(&env); err != nil {
+				t.Fatal(err)
+			}
+			seq++
+			if env.Seq != seq {
+				t.Fatalf("%s arrived with seq %d, want %d", env.Type, env.Seq, seq)
+			}
+			if (env.Type == MsgAck || env.Type == MsgError) && env.RequestID == requestID {
+				break
+			}
+		}
+	}
+}
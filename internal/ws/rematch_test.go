@@ -0,0 +1,73 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+)
+
+// TestRematchSides plays a game and a rematch under each rematch policy and
+// checks who is told to move first, with red
+func TestRematchSides(t *testing.T) {
+	for _, sides := range []string{config.RematchSwap, config.RematchKeep, config.RematchRandom} {
+		t.Run(sides, func(t *testing.T) {
+			h := NewHub()
+			policies := config.DefaultPolicies()
+			policy := policies.Modes[config.ModeFriend]
+			policy.RematchSides = sides
+			policies.Modes[config.ModeFriend] = policy
+			h.SetPolicies(policies)
+			first, second, start1, start2 := joinFriendGame(t, newPlayerServer(t, h), "alice", "bob")
+			if start1.You.Color != game.ColorRed || start2.You.Color != game.ColorYellow || start2.You.MovesFirst {
+				t.Fatalf("first game: the first mover has %+v, the other %+v", start1.You, start2.You)
+			}
+
+			// The first mover wins with four in column 0
+			for move := 0; move < 7; move++ {
+				p, column := first, 0
+				if move%2 == 1 {
+					p, column = second, 1
+				}
+				requestID := string(rune('a' + move))
+				p.send(t, MsgMove, requestID, map[string]int{"column": column})
+				expectReply(t, p.peer, MsgAck, requestID)
+			}
+			first.send(t, MsgPlayAgain, "again", nil)
+			expectReply(t, first.peer, MsgAck, "again")
+			// The second request starts the rematch before it is acknowledged
+			second.send(t, MsgPlayAgain, "again", nil)
+
+			var rematch [2]GameStartPayload
+			for i, p := range []*player{first, second} {
+				msg := p.expect(t, MsgGameStart)
+				if perr := msg.decodePayload(&rematch[i]); perr != nil {
+					t.Fatal(perr)
+				}
+				if rematch[i].You == nil {
+					t.Fatalf("rematch without a seat: %s", msg.Payload)
+				}
+			}
+			expectReply(t, second.peer, MsgAck, "again")
+			firstAgain, secondAgain := rematch[0].You, rematch[1].You
+			if firstAgain.MovesFirst == secondAgain.MovesFirst || firstAgain.Seat == secondAgain.Seat {
+				t.Fatalf("rematch seats %+v and %+v", firstAgain, secondAgain)
+			}
+			for _, you := range []*SeatPayload{firstAgain, secondAgain} {
+				if you.MovesFirst != (you.Color == game.ColorRed) || you.MovesFirst != (you.Seat == rematch[0].FirstTurn) || rematch[0].CurrentTurn != rematch[0].FirstTurn {
+					t.Errorf("seat %+v in a game where seat %d moves first", you, rematch[0].FirstTurn)
+				}
+			}
+			switch sides {
+			case config.RematchSwap:
+				if !secondAgain.MovesFirst {
+					t.Error("the same player moves first after swapping sides")
+				}
+			case config.RematchKeep:
+				if !firstAgain.MovesFirst {
+					t.Error("the sides changed although they were kept")
+				}
+			}
+		})
+	}
+}
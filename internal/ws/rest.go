@@ -0,0 +1,295 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+)
+
+// apiIdleTimeout is how long a player driven through the REST API may go
+// without a request before they are treated as disconnected
+const apiIdleTimeout = 5 * time.Minute
+
+// maxAPIBodySize bounds the JSON bodies accepted by the REST API
+const maxAPIBodySize = 4096
+
+// APICreateGameRequest is the body of POST /api/games. Without Invite the
+// game is against the bot.
+type APICreateGameRequest struct {
+	Username string        `json:"username"` // ignored when a session token is sent
+	Invite   string        `json:"invite,omitempty"`
+	Settings game.Settings `json:"settings"`
+}
+
+// APIInvitePayload answers POST /api/games when an invite was sent. The
+// game starts once the invited player accepts the challenge.
+type APIInvitePayload struct {
+	SessionToken string     `json:"sessionToken"`
+	Challenge    *Challenge `json:"challenge"`
+}
+
+// APISessionPayload answers GET /api/me
+type APISessionPayload struct {
+	Username string `json:"username"`
+	GameID   string `json:"gameId,omitempty"`
+}
+
+// HandleAPI serves the REST API for driving games without a socket.
+// Players authenticate with the session token they were given on creation
+// as "Authorization: Bearer <token>". REST and WebSocket players share
+// games, so either can play the other.
+//
+//	POST   /api/games             create a game against the bot or invite a player
+//	GET    /api/games/{id}        current GameState
+//	POST   /api/games/{id}/moves  play {"column", "moveNumber"}
+//	DELETE /api/games/{id}        leave the game
+//	GET    /api/me                the caller's name and current game
+func (h *Hub) HandleAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api"), "/")
+	parts := strings.Split(path, "/")
+
+	var perr *ProtocolError
+	switch {
+	case path == "me" && r.Method == http.MethodGet:
+		perr = h.apiSession(w, r)
+	case path == "games" && r.Method == http.MethodPost:
+		perr = h.apiCreateGame(w, r)
+	case len(parts) == 2 && parts[0] == "games" && r.Method == http.MethodGet:
+		perr = h.apiGetGame(w, r, parts[1])
+	case len(parts) == 2 && parts[0] == "games" && r.Method == http.MethodDelete:
+		perr = h.apiLeaveGame(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "games" && parts[2] == "moves" && r.Method == http.MethodPost:
+		perr = h.apiMove(w, r, parts[1])
+	default:
+		perr = newProtocolError(ErrCodeUnknownType, "no such endpoint: %s %s", r.Method, r.URL.Path)
+	}
+	if perr != nil {
+		writeProtocolError(w, perr)
+	}
+}
+
+// apiClient returns the client holding the request's session token, or nil.
+// A player who went idle and was marked absent comes back the way a
+// reconnecting socket does, taking their seat back from a substitute bot.
+func (h *Hub) apiClient(r *http.Request) *Client {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil
+	}
+	h.mu.Lock()
+	client := h.findSessionUnsafe(token)
+	if client == nil || client.disconnectedAt == nil {
+		if client != nil && client.apiIdle != nil {
+			client.apiIdle.Reset(apiIdleTimeout)
+		}
+		h.mu.Unlock()
+		return client
+	}
+	h.mu.Unlock()
+
+	resumed := h.newAPIClient()
+	if !h.reconnectClient(resumed, token) {
+		resumed.apiIdle.Stop()
+		return nil
+	}
+	log.Printf("[BACKEND-API] REST player %s is back", resumed.username)
+	return resumed
+}
+
+// requireAPIClient is apiClient for endpoints that need a session
+func (h *Hub) requireAPIClient(r *http.Request) (*Client, *ProtocolError) {
+	client := h.apiClient(r)
+	if client == nil {
+		return nil, newProtocolError(ErrCodeSessionInvalid, "a valid session token is required")
+	}
+	return client, nil
+}
+
+// decodeAPIBody reads a JSON request body into v
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v interface{}) *ProtocolError {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize)).Decode(v); err != nil {
+		return newProtocolError(ErrCodeInvalidMessage, "invalid request body: %v", err)
+	}
+	return nil
+}
+
+// newAPIClient creates an unregistered REST player. Going quiet counts as
+// closing the socket would.
+func (h *Hub) newAPIClient() *Client {
+	client := &Client{
+		hub:             h,
+		codec:           jsonCodec{},
+		protocolVersion: ProtocolVersion,
+	}
+	client.apiIdle = time.AfterFunc(apiIdleTimeout, func() { h.unregister <- client })
+	return client
+}
+
+// newAPIClientUnsafe registers a player for the REST API under name. Must
+// be called with h.mu held.
+func (h *Hub) newAPIClientUnsafe(name string) (*Client, *ProtocolError) {
+	client := h.newAPIClient()
+	client.sessionToken = newSessionToken()
+	username, perr := h.claimUsernameUnsafe(client, name, "", false)
+	if perr != nil {
+		client.apiIdle.Stop()
+		return nil, perr
+	}
+	client.username = username
+	h.clients[client] = true
+	h.refreshPresenceUnsafe(client)
+	log.Printf("[BACKEND-API] Registered REST player %s", username)
+	return client, nil
+}
+
+// dropAPIClient forgets a REST player that never got into a game
+func (h *Hub) dropAPIClient(client *Client) {
+	client.apiIdle.Stop()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, client)
+	h.refreshPresenceUnsafe(client)
+}
+
+func (h *Hub) apiSession(w http.ResponseWriter, r *http.Request) *ProtocolError {
+	client, perr := h.requireAPIClient(r)
+	if perr != nil {
+		return perr
+	}
+	h.mu.Lock()
+	resp := APISessionPayload{Username: client.username, GameID: client.gameID}
+	h.mu.Unlock()
+	json.NewEncoder(w).Encode(resp)
+	return nil
+}
+
+func (h *Hub) apiCreateGame(w http.ResponseWriter, r *http.Request) *ProtocolError {
+	req := APICreateGameRequest{Settings: game.DefaultSettings()}
+	if perr := decodeAPIBody(w, r, &req); perr != nil {
+		return perr
+	}
+	if err := req.Settings.Validate(); err != nil {
+		return newProtocolError(ErrCodeInvalidSettings, "%v", err)
+	}
+
+	client := h.apiClient(r)
+	h.mu.Lock()
+	created := client == nil
+	if created {
+		var perr *ProtocolError
+		if client, perr = h.newAPIClientUnsafe(req.Username); perr != nil {
+			h.mu.Unlock()
+			return perr
+		}
+	} else if client.gameID != "" {
+		h.mu.Unlock()
+		return newProtocolError(ErrCodeAlreadyInGame, "you are already in a game")
+	}
+
+	if req.Invite != "" {
+		h.mu.Unlock()
+		if perr := h.handleChallenge(client, ChallengePayload{Username: req.Invite, Settings: req.Settings}); perr != nil {
+			if created {
+				h.dropAPIClient(client)
+			}
+			return perr
+		}
+		h.mu.Lock()
+		resp := APIInvitePayload{SessionToken: client.sessionToken}
+		for _, ch := range h.challenges {
+			if ch.challenger == client && ch.To == req.Invite {
+				resp.Challenge = ch
+			}
+		}
+		h.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(resp)
+		return nil
+	}
+
+	h.leaveRemoteGameUnsafe(client)
+	h.startBotGameUnsafe(client, req.Settings, config.ModeComputer)
+	token := client.sessionToken
+	h.mu.Unlock()
+
+	g := h.lockGameOf(client)
+	if g == nil {
+		return newProtocolError(ErrCodeInternal, "the game could not be created")
+	}
+	defer g.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(GameStartPayload{
+		GameState:    g.game.GetState(),
+		SessionToken: token,
+		Series:       g.seriesScore(),
+		You:          g.seatPayload(g.seatOf(client)),
+	})
+	return nil
+}
+
+func (h *Hub) apiGetGame(w http.ResponseWriter, r *http.Request, gameID string) *ProtocolError {
+	client := h.apiClient(r)
+	g := h.lockGame(gameID)
+	if g == nil {
+		return newProtocolError(ErrCodeNoSuchGame, "game %s not found", gameID)
+	}
+	defer g.mu.Unlock()
+
+	// Anyone may look at a game; its players also learn their seat
+	state := GameStatePayload{GameState: g.game.GetState()}
+	if client != nil {
+		state.You = g.seatPayload(g.seatOf(client))
+	}
+	json.NewEncoder(w).Encode(state)
+	return nil
+}
+
+// apiPlayerIn returns the caller if they are seated in gameID
+func (h *Hub) apiPlayerIn(r *http.Request, gameID string) (*Client, *ProtocolError) {
+	client, perr := h.requireAPIClient(r)
+	if perr != nil {
+		return nil, perr
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if client.gameID != gameID {
+		return nil, newProtocolError(ErrCodeNotAPlayer, "you are not a player in game %s", gameID)
+	}
+	return client, nil
+}
+
+func (h *Hub) apiMove(w http.ResponseWriter, r *http.Request, gameID string) *ProtocolError {
+	client, perr := h.apiPlayerIn(r, gameID)
+	if perr != nil {
+		return perr
+	}
+	var move MovePayload
+	if perr := decodeAPIBody(w, r, &move); perr != nil {
+		return perr
+	}
+	if move.Column == nil || move.MoveNumber == nil {
+		return newProtocolError(ErrCodeInvalidMessage, "column and moveNumber are required")
+	}
+	if perr := h.handleMoveAt(client, *move.Column, *move.MoveNumber); perr != nil {
+		return perr
+	}
+	return h.apiGetGame(w, r, gameID)
+}
+
+func (h *Hub) apiLeaveGame(w http.ResponseWriter, r *http.Request, gameID string) *ProtocolError {
+	client, perr := h.apiPlayerIn(r, gameID)
+	if perr != nil {
+		return perr
+	}
+	if perr := h.handleExit(client); perr != nil {
+		return perr
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,220 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+)
+
+// TestAPIPlayerReturnsAfterIdle lets a REST player go idle until a bot takes
+// over their seat. Their next request must hand the seat back, and until
+// then nobody may move for the seat but the bot.
+func TestAPIPlayerReturnsAfterIdle(t *testing.T) {
+	h := NewHub()
+	policies := config.DefaultPolicies()
+	policy := policies.Modes[config.ModeFriend]
+	policy.BotTakeoverDelay = 10 * time.Millisecond
+	policy.BotThinkDelay = 0
+	policies.Modes[config.ModeFriend] = policy
+	h.SetPolicies(policies)
+	go h.Run()
+	sockets := newSocketServer(t)
+
+	h.mu.Lock()
+	rest, perr := h.newAPIClientUnsafe("rest")
+	h.mu.Unlock()
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	opponent := sockets.mustClient(t, h, "socket")
+	h.mu.Lock()
+	h.createGame(rest, opponent, game.DefaultSettings(), config.ModeFriend, nil, "")
+	gameID, token := rest.gameID, rest.sessionToken
+	g := h.activeGames[gameID]
+	h.mu.Unlock()
+
+	// What the idle timer does
+	h.unregister <- rest
+	waitFor(t, "the bot to take over", func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.substitutes[0] && g.game.CurrentTurn == 2
+	})
+
+	// The bot has played for the seat; the old client may not join in
+	if perr := h.handleMove(opponent, 3); perr != nil {
+		t.Fatal(perr)
+	}
+	if perr := h.handleMove(rest, 3); perr == nil || perr.Code != ErrCodeNotAllowed {
+		t.Fatalf("move from a substituted seat: got %v, want %s", perr, ErrCodeNotAllowed)
+	}
+
+	api := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.HandleAPI(rec, req)
+		return rec
+	}
+	if rec := api(http.MethodGet, "/api/games/"+gameID, ""); rec.Code != http.StatusOK {
+		t.Fatalf("get game after idle: %d %s", rec.Code, rec.Body)
+	}
+	g.mu.Lock()
+	substituted := g.substitutes[0]
+	g.mu.Unlock()
+	if substituted {
+		t.Fatal("the seat was not handed back to the returning player")
+	}
+
+	waitFor(t, "the returning player's turn", func() bool {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.game.CurrentTurn == 1
+	})
+	if rec := api(http.MethodPost, "/api/games/"+gameID+"/moves", `{"column": 0, "moveNumber": 2}`); rec.Code != http.StatusOK {
+		t.Fatalf("move after returning: %d %s", rec.Code, rec.Body)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		if c.username == "rest" && c.disconnectedAt != nil {
+			t.Error("the returning player is still marked absent")
+		}
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func apiRequest(h *Hub, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.HandleAPI(rec, req)
+	return rec
+}
+
+// expectAPIError checks that rec is the error code answered with status
+func expectAPIError(t *testing.T, what string, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	var perr ProtocolError
+	json.Unmarshal(rec.Body.Bytes(), &perr)
+	if rec.Code != status || perr.Code != code {
+		t.Errorf("%s: %d %s, want %d %s", what, rec.Code, rec.Body, status, code)
+	}
+}
+
+// createAPIBotGame starts a game against the bot for a new REST player
+func createAPIBotGame(t *testing.T, h *Hub, username string) GameStartPayload {
+	t.Helper()
+	rec := apiRequest(h, http.MethodPost, "/api/games", "", `{"username": "`+username+`"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create game: %d %s", rec.Code, rec.Body)
+	}
+	var start GameStartPayload
+	if err := json.Unmarshal(rec.Body.Bytes(), &start); err != nil {
+		t.Fatal(err)
+	}
+	if start.SessionToken == "" || start.GameState == nil || start.You == nil {
+		t.Fatalf("created game without a session token, state or seat: %s", rec.Body)
+	}
+	return start
+}
+
+// TestAPIBotGame plays a game against the bot over the REST API
+func TestAPIBotGame(t *testing.T) {
+	h := NewHub()
+	policies := config.DefaultPolicies()
+	policy := policies.Modes[config.ModeComputer]
+	policy.BotThinkDelay = 0
+	policies.Modes[config.ModeComputer] = policy
+	h.SetPolicies(policies)
+	go h.Run()
+
+	start := createAPIBotGame(t, h, "rest")
+	token, gameID, seat := start.SessionToken, start.ID, start.You.Seat
+	if !start.You.MovesFirst {
+		t.Fatalf("the creator does not move first: %+v", start.You)
+	}
+
+	rec := apiRequest(h, http.MethodGet, "/api/me", token, "")
+	var me APISessionPayload
+	json.Unmarshal(rec.Body.Bytes(), &me)
+	if rec.Code != http.StatusOK || me.Username != "rest" || me.GameID != gameID {
+		t.Errorf("me: %d %s, want rest in %s", rec.Code, rec.Body, gameID)
+	}
+	expectAPIError(t, "me without a token", apiRequest(h, http.MethodGet, "/api/me", "", ""), http.StatusUnauthorized, ErrCodeSessionInvalid)
+	expectAPIError(t, "me with a wrong token", apiRequest(h, http.MethodGet, "/api/me", token+"x", ""), http.StatusUnauthorized, ErrCodeSessionInvalid)
+	expectAPIError(t, "second game", apiRequest(h, http.MethodPost, "/api/games", token, `{}`), http.StatusConflict, ErrCodeAlreadyInGame)
+
+	rec = apiRequest(h, http.MethodPost, "/api/games/"+gameID+"/moves", token, `{"column": 3, "moveNumber": 0}`)
+	var state GameStatePayload
+	json.Unmarshal(rec.Body.Bytes(), &state)
+	if rec.Code != http.StatusOK || state.GameState == nil || state.Board[len(state.Board)-1][3] != seat {
+		t.Fatalf("move: %d %s", rec.Code, rec.Body)
+	}
+	waitFor(t, "the bot to answer", func() bool {
+		rec := apiRequest(h, http.MethodGet, "/api/games/"+gameID, token, "")
+		var state GameStatePayload
+		json.Unmarshal(rec.Body.Bytes(), &state)
+		return state.GameState != nil && state.CurrentTurn == seat
+	})
+
+	// The move number must be the number of moves played so far
+	expectAPIError(t, "stale move", apiRequest(h, http.MethodPost, "/api/games/"+gameID+"/moves", token, `{"column": 0, "moveNumber": 1}`), http.StatusConflict, ErrCodeStaleMove)
+	expectAPIError(t, "move from the future", apiRequest(h, http.MethodPost, "/api/games/"+gameID+"/moves", token, `{"column": 0, "moveNumber": 3}`), http.StatusConflict, ErrCodeStaleMove)
+	expectAPIError(t, "move without a number", apiRequest(h, http.MethodPost, "/api/games/"+gameID+"/moves", token, `{"column": 0}`), http.StatusBadRequest, ErrCodeInvalidMessage)
+	rec = apiRequest(h, http.MethodPost, "/api/games/"+gameID+"/moves", token, `{"column": 0, "moveNumber": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move 2: %d %s", rec.Code, rec.Body)
+	}
+
+	// Anyone may watch, but only players are told their seat
+	other := createAPIBotGame(t, h, "other")
+	for _, watcher := range []struct{ name, token string }{{"anonymous", ""}, {"player of another game", other.SessionToken}} {
+		rec := apiRequest(h, http.MethodGet, "/api/games/"+gameID, watcher.token, "")
+		var state GameStatePayload
+		json.Unmarshal(rec.Body.Bytes(), &state)
+		if rec.Code != http.StatusOK || state.GameState == nil || state.ID != gameID {
+			t.Errorf("%s: get game %d %s", watcher.name, rec.Code, rec.Body)
+		}
+		if state.You != nil {
+			t.Errorf("%s: told seat %+v", watcher.name, state.You)
+		}
+	}
+	rec = apiRequest(h, http.MethodGet, "/api/games/"+gameID, token, "")
+	state = GameStatePayload{}
+	json.Unmarshal(rec.Body.Bytes(), &state)
+	if state.You == nil || state.You.Seat != seat {
+		t.Errorf("the player is told seat %+v, want %d", state.You, seat)
+	}
+	expectAPIError(t, "unknown game", apiRequest(h, http.MethodGet, "/api/games/nope", token, ""), http.StatusNotFound, ErrCodeNoSuchGame)
+
+	// Only the game's players may move in it or leave it
+	expectAPIError(t, "move by another game's player", apiRequest(h, http.MethodPost, "/api/games/"+gameID+"/moves", other.SessionToken, `{"column": 0, "moveNumber": 4}`), http.StatusForbidden, ErrCodeNotAPlayer)
+	expectAPIError(t, "leave by another game's player", apiRequest(h, http.MethodDelete, "/api/games/"+gameID, other.SessionToken, ""), http.StatusForbidden, ErrCodeNotAPlayer)
+	expectAPIError(t, "leave without a token", apiRequest(h, http.MethodDelete, "/api/games/"+gameID, "", ""), http.StatusUnauthorized, ErrCodeSessionInvalid)
+
+	if rec := apiRequest(h, http.MethodDelete, "/api/games/"+gameID, token, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("leave: %d %s", rec.Code, rec.Body)
+	}
+	expectAPIError(t, "leave twice", apiRequest(h, http.MethodDelete, "/api/games/"+gameID, token, ""), http.StatusForbidden, ErrCodeNotAPlayer)
+	expectAPIError(t, "unknown endpoint", apiRequest(h, http.MethodPut, "/api/games/"+gameID, token, ""), http.StatusNotFound, ErrCodeUnknownType)
+}
@@ -0,0 +1,133 @@
+package ws
+
+import (
+	"context"
+	"log"
+
+	"github.com/connect4/backend/internal/database"
+	"github.com/google/uuid"
+)
+
+// series is a best-of-N match between two players, carried from one game to
+// the next through rematches. Players keeps the order of the first game; the
+// player moving first alternates between games.
+type series struct {
+	ID          string    `json:"id"`
+	BestOf      int       `json:"bestOf"`
+	Players     [2]string `json:"players"`
+	Wins        [2]int    `json:"wins"`
+	Draws       int       `json:"draws"`
+	GamesPlayed int       `json:"gamesPlayed"`
+	Finished    bool      `json:"finished"`
+	Winner      string    `json:"winner,omitempty"`
+}
+
+func newSeries(player1, player2 string, bestOf int) *series {
+	return &series{
+		ID:      uuid.New().String(),
+		BestOf:  bestOf,
+		Players: [2]string{player1, player2},
+	}
+}
+
+// firstMover returns the username that moves first in the next game
+func (s *series) firstMover() string {
+	return s.Players[s.GamesPlayed%2]
+}
+
+// record adds the result of a game and decides the series once a player has
+// won a majority of the games, or all games have been played
+func (s *series) record(winner string, isDraw bool) {
+	if s.Finished {
+		return
+	}
+	s.GamesPlayed++
+	switch {
+	case isDraw:
+		s.Draws++
+	case winner == s.Players[0]:
+		s.Wins[0]++
+	case winner == s.Players[1]:
+		s.Wins[1]++
+	}
+
+	needed := s.BestOf/2 + 1
+	if s.Wins[0] < needed && s.Wins[1] < needed && s.GamesPlayed < s.BestOf {
+		return
+	}
+	s.Finished = true
+	if s.Wins[0] > s.Wins[1] {
+		s.Winner = s.Players[0]
+	} else if s.Wins[1] > s.Wins[0] {
+		s.Winner = s.Players[1]
+	}
+}
+
+// score returns the client-facing view of the series
+func (s *series) score() *SeriesScore {
+	score := &SeriesScore{
+		SeriesID: s.ID,
+		BestOf:   s.BestOf,
+		Game:     s.GamesPlayed,
+		Score:    map[string]int{s.Players[0]: s.Wins[0], s.Players[1]: s.Wins[1]},
+		Draws:    s.Draws,
+		Finished: s.Finished,
+	}
+	if !s.Finished {
+		score.Game++
+	}
+	if s.Winner != "" {
+		winner := s.Winner
+		score.Winner = &winner
+	}
+	return score
+}
+
+// seriesScore returns the series score of g, or nil for a single game
+func (g *WSGame) seriesScore() *SeriesScore {
+	if g.series == nil {
+		return nil
+	}
+	return g.series.score()
+}
+
+// recordSeriesGameLocked counts a finished game towards its series and stores
+// the series once it is decided. Must be called with g.mu held.
+func (h *Hub) recordSeriesGameLocked(g *WSGame, winner int, isDraw bool) {
+	s := g.series
+	if s == nil || s.Finished {
+		return
+	}
+	var username string
+	switch winner {
+	case 1:
+		username = g.game.Player1.Username
+	case 2:
+		username = g.game.Player2.Username
+	}
+	s.record(username, isDraw)
+	log.Printf("[BACKEND-SERIES] Series %s after game %d: %s %d - %d %s", s.ID, s.GamesPlayed, s.Players[0], s.Wins[0], s.Wins[1], s.Players[1])
+	if !s.Finished {
+		return
+	}
+
+	log.Printf("[BACKEND-SERIES] Series %s finished, winner=%q", s.ID, s.Winner)
+	if h.db == nil {
+		return
+	}
+	result := database.SeriesResult{
+		SeriesID:    s.ID,
+		BestOf:      s.BestOf,
+		Player1:     s.Players[0],
+		Player2:     s.Players[1],
+		Player1Wins: s.Wins[0],
+		Player2Wins: s.Wins[1],
+		Draws:       s.Draws,
+		Winner:      s.Winner,
+	}
+	go func() {
+		if err := h.db.SaveSeriesResult(context.Background(), result); err != nil {
+			log.Printf("[BACKEND-SERIES] Failed to save series %s: %v", result.SeriesID, err)
+		}
+	}()
+}
@@ -0,0 +1,145 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+)
+
+func TestSeriesRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		bestOf   int
+		results  []string // winner of each game, "" for a draw
+		wins     [2]int
+		draws    int
+		played   int
+		finished bool
+		winner   string
+	}{
+		{"running", 3, []string{"a"}, [2]int{1, 0}, 0, 1, false, ""},
+		{"clinched early", 3, []string{"a", "a"}, [2]int{2, 0}, 0, 2, true, "a"},
+		{"decider", 3, []string{"a", "b", "b"}, [2]int{1, 2}, 0, 3, true, "b"},
+		{"clinched early in a best of 5", 5, []string{"b", "a", "b", "b"}, [2]int{1, 3}, 0, 4, true, "b"},
+		{"draws still count as games", 3, []string{"", "a", ""}, [2]int{1, 0}, 2, 3, true, "a"},
+		{"draw after a draw keeps it open", 5, []string{"", ""}, [2]int{0, 0}, 2, 2, false, ""},
+		{"drawn series", 3, []string{"a", "", "b"}, [2]int{1, 1}, 1, 3, true, ""},
+		{"all draws", 3, []string{"", "", ""}, [2]int{0, 0}, 3, 3, true, ""},
+		{"games after the end are ignored", 3, []string{"b", "b", "a"}, [2]int{0, 2}, 0, 2, true, "b"},
+	}
+	for _, tt := range tests {
+		s := newSeries("a", "b", tt.bestOf)
+		for _, winner := range tt.results {
+			s.record(winner, winner == "")
+		}
+		if s.Wins != tt.wins || s.Draws != tt.draws || s.GamesPlayed != tt.played {
+			t.Errorf("%s: wins %v, draws %d after %d games, want %v, %d after %d",
+				tt.name, s.Wins, s.Draws, s.GamesPlayed, tt.wins, tt.draws, tt.played)
+		}
+		if s.Finished != tt.finished || s.Winner != tt.winner {
+			t.Errorf("%s: finished %v winner %q, want %v %q", tt.name, s.Finished, s.Winner, tt.finished, tt.winner)
+		}
+	}
+}
+
+func TestSeriesFirstMoverAlternates(t *testing.T) {
+	s := newSeries("a", "b", 5)
+	// Who wins does not change the order
+	results := []string{"b", "b", "", "", ""}
+	for i, want := range []string{"a", "b", "a", "b", "a"} {
+		if got := s.firstMover(); got != want {
+			t.Errorf("game %d: %s moves first, want %s", i+1, got, want)
+		}
+		s.record(results[i], results[i] == "")
+	}
+}
+
+func TestSeriesScore(t *testing.T) {
+	s := newSeries("a", "b", 3)
+	s.record("a", false)
+	score := s.score()
+	if score.Game != 2 || score.Finished || score.Winner != nil {
+		t.Errorf("running series: %+v, want game 2 without a winner", score)
+	}
+	if score.Score["a"] != 1 || score.Score["b"] != 0 {
+		t.Errorf("score %v, want a 1 b 0", score.Score)
+	}
+
+	s.record("a", false)
+	score = s.score()
+	if score.Game != 2 || !score.Finished || score.Winner == nil || *score.Winner != "a" {
+		t.Errorf("finished series: %+v, want game 2 won by a", score)
+	}
+}
+
+// TestSeriesRematches plays a best-of-3 through rematches. The first move
+// alternates between the players, and the series ends when one of them has
+// two wins.
+func TestSeriesRematches(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	sockets := newSocketServer(t)
+
+	alice := sockets.mustClient(t, h, "alice")
+	bob := sockets.mustClient(t, h, "bob")
+	settings := game.DefaultSettings()
+	settings.BestOf = 3
+	h.mu.Lock()
+	h.createGame(alice, bob, settings, config.ModeFriend, nil, "")
+	h.mu.Unlock()
+
+	// The first mover wins every game: alice, bob, alice
+	for i, first := range []*Client{alice, bob, alice} {
+		h.mu.Lock()
+		g := h.activeGames[alice.gameID]
+		h.mu.Unlock()
+		if g == nil {
+			t.Fatalf("game %d did not start", i+1)
+		}
+		second := bob
+		if first == bob {
+			second = alice
+		}
+		g.mu.Lock()
+		mover := g.player1Client
+		if g.game.CurrentTurn == 2 {
+			mover = g.player2Client
+		}
+		g.mu.Unlock()
+		if mover != first {
+			t.Fatalf("game %d: %s moves first, want %s", i+1, mover.username, first.username)
+		}
+
+		for move := 0; move < 7; move++ {
+			c, column := first, 0
+			if move%2 == 1 {
+				c, column = second, 1
+			}
+			if perr := h.handleMove(c, column); perr != nil {
+				t.Fatalf("game %d, move %d: %v", i+1, move+1, perr)
+			}
+		}
+
+		g.mu.Lock()
+		score := g.seriesScore()
+		g.mu.Unlock()
+		if score == nil || score.Score[first.username] < 1 {
+			t.Fatalf("game %d: series score %+v does not count the win of %s", i+1, score, first.username)
+		}
+		if i < 2 {
+			if score.Finished {
+				t.Fatalf("game %d: series finished at %v", i+1, score.Score)
+			}
+			for _, c := range []*Client{alice, bob} {
+				if perr := h.handlePlayAgain(c, 0); perr != nil {
+					t.Fatal(perr)
+				}
+			}
+			continue
+		}
+		if !score.Finished || score.Winner == nil || *score.Winner != "alice" {
+			t.Errorf("after game 3: %+v, want alice to win 2-1", score)
+		}
+	}
+}
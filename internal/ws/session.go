@@ -0,0 +1,58 @@
+package ws
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// newSessionToken returns an opaque random token identifying a player's seat
+func newSessionToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[BACKEND-SESSION] crypto/rand failed, falling back to uuid: %v", err)
+		return uuid.New().String() + uuid.New().String()
+	}
+	return hex.EncodeToString(b)
+}
+
+// findSessionUnsafe finds the client holding a session token without locking
+func (h *Hub) findSessionUnsafe(token string) *Client {
+	if token == "" {
+		return nil
+	}
+	for client := range h.clients {
+		if client.sessionToken == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(client.sessionToken), []byte(token)) == 1 {
+			return client
+		}
+	}
+	return nil
+}
+
+// handleResume reattaches client to the session identified by token
+func (h *Hub) handleResume(client *Client, token string) *ProtocolError {
+	if h.reconnectClient(client, token) {
+		return nil
+	}
+	return newProtocolError(ErrCodeSessionInvalid, "session expired or invalid")
+}
+
+// sendCurrentStateLocked sends the state of g to the client.
+// Must be called with g.mu held.
+func (h *Hub) sendCurrentStateLocked(g *WSGame, client *Client) {
+	client.sendEnvelope(Envelope{
+		Type:     MsgGameState,
+		GameID:   g.game.ID,
+		EventSeq: g.eventSeq,
+		Payload: GameStatePayload{
+			GameState: g.ToGameState(),
+			You:       g.seatPayload(g.seatOf(client)),
+		},
+	})
+}
@@ -0,0 +1,249 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// player is a client connected through ServeWs, as a browser would be
+type player struct {
+	conn *websocket.Conn
+	*peer
+}
+
+// newPlayerServer serves the player WebSocket of a running hub
+func newPlayerServer(t *testing.T, h *Hub) *httptest.Server {
+	go h.Run()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { ServeWs(h, w, r) }))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { endGames(h) })
+	return srv
+}
+
+// endGames stops the games still open on h. Otherwise the bot takes over the
+// seats of the players a test leaves behind and plays on through later tests.
+func endGames(h *Hub) {
+	h.mu.Lock()
+	games := make([]*WSGame, 0, len(h.activeGames))
+	for _, g := range h.activeGames {
+		games = append(games, g)
+	}
+	h.mu.Unlock()
+	for _, g := range games {
+		g.mu.Lock()
+		g.game.IsActive = false
+		g.syncRunning()
+		h.mu.Lock()
+		h.removeGameUnsafe(g)
+		h.mu.Unlock()
+		g.mu.Unlock()
+	}
+}
+
+func dialPlayer(t *testing.T, srv *httptest.Server) *player {
+	header := http.Header{"Origin": {"http://localhost:3000"}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	p := &player{conn: conn, peer: &peer{}}
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				p.end(err)
+				return
+			}
+			p.record(data)
+		}
+	}()
+	return p
+}
+
+func (p *player) send(t *testing.T, msgType, requestID string, payload interface{}) {
+	t.Helper()
+	msg := map[string]interface{}{"type": msgType}
+	if requestID != "" {
+		msg["requestId"] = requestID
+	}
+	if payload != nil {
+		msg["payload"] = payload
+	}
+	if err := p.conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectError waits for the error answering requestID and returns its code
+func (p *player) expectError(t *testing.T, requestID string) string {
+	t.Helper()
+	for {
+		msg := p.expect(t, MsgError)
+		if msg.RequestID != requestID {
+			continue
+		}
+		var perr ProtocolError
+		msg.decodePayload(&perr)
+		return perr.Code
+	}
+}
+
+// joinFriendGame connects two players and pairs them. The first one to
+// move is returned first, with the payloads of their gameStart messages.
+func joinFriendGame(t *testing.T, srv *httptest.Server, name1, name2 string) (first, second *player, start1, start2 GameStartPayload) {
+	t.Helper()
+	p1, p2 := dialPlayer(t, srv), dialPlayer(t, srv)
+	p1.send(t, MsgJoin, "", JoinPayload{Username: name1, GameMode: "friend", ProtocolVersion: ProtocolVersion})
+	p1.expect(t, MsgWelcome)
+	p2.send(t, MsgJoin, "", JoinPayload{Username: name2, GameMode: "friend", ProtocolVersion: ProtocolVersion})
+	for _, p := range []struct {
+		player *player
+		start  *GameStartPayload
+	}{{p1, &start1}, {p2, &start2}} {
+		msg := p.player.expect(t, MsgGameStart)
+		if perr := msg.decodePayload(p.start); perr != nil {
+			t.Fatal(perr)
+		}
+		if p.start.SessionToken == "" || p.start.You == nil {
+			t.Fatalf("gameStart without a session token or seat: %s", msg.Payload)
+		}
+	}
+	if start2.You.MovesFirst {
+		return p2, p1, start2, start1
+	}
+	return p1, p2, start1, start2
+}
+
+// waitDisconnected waits until the hub holds username's seat for a reconnect
+func waitDisconnected(t *testing.T, h *Hub, username string) {
+	t.Helper()
+	waitFor(t, username+" to be marked disconnected", func() bool {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		for c := range h.clients {
+			if c.username == username && c.disconnectedAt != nil {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// TestResumeNeedsSessionToken disconnects a player and tries to take their
+// seat back. Knowing the username is not enough; only the session token
+// reattaches, and the player then gets the current state with their seat.
+func TestResumeNeedsSessionToken(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	alice, bob, start, _ := joinFriendGame(t, srv, "alice", "bob")
+	gameID, token, seat := start.ID, start.SessionToken, start.You.Seat
+
+	alice.send(t, MsgMove, "m1", map[string]int{"column": 3})
+	alice.expect(t, MsgAck)
+	alice.conn.Close()
+	waitDisconnected(t, h, "alice")
+
+	// Someone who only knows the name
+	mallory := dialPlayer(t, srv)
+	attempts := []struct {
+		msgType string
+		payload interface{}
+		code    string
+	}{
+		{MsgJoin, JoinPayload{Username: "alice", GameMode: "friend", ProtocolVersion: ProtocolVersion}, ErrCodeUsernameTaken},
+		{MsgJoin, JoinPayload{Username: "alice", GameMode: "friend", SessionToken: "not-the-token", ProtocolVersion: ProtocolVersion}, ErrCodeUsernameTaken},
+		{MsgJoin, JoinPayload{Username: "alice", GameMode: "friend", SessionToken: token[:len(token)-1], ProtocolVersion: ProtocolVersion}, ErrCodeUsernameTaken},
+		{MsgResume, map[string]string{"sessionToken": "not-the-token"}, ErrCodeSessionInvalid},
+		{MsgResume, map[string]string{"sessionToken": ""}, ErrCodeSessionInvalid},
+		{MsgResume, map[string]string{}, ErrCodeSessionInvalid},
+	}
+	for i, a := range attempts {
+		requestID := string(rune('a' + i))
+		mallory.send(t, a.msgType, requestID, a.payload)
+		if code := mallory.expectError(t, requestID); code != a.code {
+			t.Errorf("attempt %d (%s %v): error %s, want %s", i+1, a.msgType, a.payload, code, a.code)
+		}
+	}
+	h.mu.Lock()
+	g := h.activeGames[gameID]
+	h.mu.Unlock()
+	g.mu.Lock()
+	holder := g.player1Client
+	if seat == 2 {
+		holder = g.player2Client
+	}
+	g.mu.Unlock()
+	h.mu.Lock()
+	name, away := holder.username, holder.disconnectedAt != nil
+	h.mu.Unlock()
+	if name != "alice" || !away {
+		t.Fatalf("the seat is held by %s (away %v), want the disconnected alice", name, away)
+	}
+
+	// The token resumes the seat, with the current state and the seat in it
+	resumed := dialPlayer(t, srv)
+	resumed.send(t, MsgResume, "r1", map[string]string{"sessionToken": token})
+	msg := resumed.expect(t, MsgGameState)
+	if ack := resumed.expect(t, MsgAck); ack.RequestID != "r1" {
+		t.Errorf("resume acknowledged as %q", ack.RequestID)
+	}
+	var state GameStatePayload
+	if perr := msg.decodePayload(&state); perr != nil {
+		t.Fatal(perr)
+	}
+	if msg.GameID != gameID || state.GameState == nil || state.ID != gameID {
+		t.Fatalf("resumed into %s: %s", msg.GameID, msg.Payload)
+	}
+	if state.You == nil || state.You.Seat != seat || !state.You.MovesFirst {
+		t.Errorf("seat after resuming: %+v, want seat %d moving first", state.You, seat)
+	}
+	if state.Board[len(state.Board)-1][3] != seat || state.CurrentTurn == seat {
+		t.Errorf("resumed state does not have the move played: %s", msg.Payload)
+	}
+
+	// The resumed player plays on
+	bob.send(t, MsgMove, "b1", map[string]int{"column": 4})
+	bob.expect(t, MsgAck)
+	resumed.send(t, MsgMove, "m2", map[string]int{"column": 3})
+	resumed.expect(t, MsgAck)
+
+	// Joining with the name and the token reattaches as well
+	resumed.conn.Close()
+	waitDisconnected(t, h, "alice")
+	rejoined := dialPlayer(t, srv)
+	rejoined.send(t, MsgJoin, "j1", JoinPayload{Username: "alice", GameMode: "friend", SessionToken: token, ProtocolVersion: ProtocolVersion})
+	msg = rejoined.expect(t, MsgGameState)
+	state = GameStatePayload{}
+	if perr := msg.decodePayload(&state); perr != nil {
+		t.Fatal(perr)
+	}
+	if msg.GameID != gameID || state.You == nil || state.You.Seat != seat {
+		t.Errorf("rejoined into %s with seat %+v, want %s seat %d", msg.GameID, state.You, gameID, seat)
+	}
+}
+
+// TestResumeTakesOverLiveConnection resumes a session that is still
+// connected elsewhere. The new connection gets the seat and the old one is
+// closed.
+func TestResumeTakesOverLiveConnection(t *testing.T) {
+	h := NewHub()
+	srv := newPlayerServer(t, h)
+	alice, _, start, _ := joinFriendGame(t, srv, "alice", "bob")
+
+	other := dialPlayer(t, srv)
+	other.send(t, MsgResume, "r1", map[string]string{"sessionToken": start.SessionToken})
+	msg := other.expect(t, MsgGameState)
+	var state GameStatePayload
+	if perr := msg.decodePayload(&state); perr != nil || state.You == nil || state.You.Seat != start.You.Seat {
+		t.Fatalf("resumed with %s: %v", msg.Payload, perr)
+	}
+	alice.closed(t)
+
+	other.send(t, MsgMove, "m1", map[string]int{"column": 0})
+	other.expect(t, MsgAck)
+}
@@ -0,0 +1,84 @@
+package ws
+
+import "log"
+
+// seatOf returns the seat (1 or 2) client holds in g, or 0
+func (g *WSGame) seatOf(client *Client) int {
+	switch client {
+	case g.player1Client:
+		return 1
+	case g.player2Client:
+		return 2
+	}
+	return 0
+}
+
+// botToMove reports whether the player to move is a bot, either a real bot
+// opponent or one standing in for an absent player
+func (g *WSGame) botToMove() bool {
+	switch g.game.CurrentTurn {
+	case 1:
+		return g.game.Player1.IsBot || g.substitutes[0]
+	case 2:
+		return g.game.Player2.IsBot || g.substitutes[1]
+	}
+	return false
+}
+
+// substitutedLocked reports whether a bot is playing client's seat in g
+// while it is still running. Must be called with g.mu held.
+func (h *Hub) substitutedLocked(g *WSGame, client *Client) bool {
+	if g.closed || !g.game.IsActive {
+		return false
+	}
+	seat := g.seatOf(client)
+	return seat != 0 && g.substitutes[seat-1]
+}
+
+// scheduleBotMoveLocked starts the bot's turn if a bot is to move.
+// Must be called with g.mu held.
+func (h *Hub) scheduleBotMoveLocked(g *WSGame) {
+	if g.game.IsActive && g.botToMove() {
+		go h.makeBotMove(g)
+	}
+}
+
+// substituteLocked hands the seat of an absent client to a bot for the rest
+// of the game, or until the client returns. Must be called with g.mu held
+// and h.mu not held.
+func (h *Hub) substituteLocked(g *WSGame, client *Client) {
+	seat := g.seatOf(client)
+	if seat == 0 || g.substitutes[seat-1] {
+		return
+	}
+	log.Printf("[BACKEND-TAKEOVER] Bot takes over seat %d of %s in game %s", seat, client.username, g.game.ID)
+	g.substitutes[seat-1] = true
+	g.game.Substituted = true
+
+	h.persistGameLocked(g)
+	h.broadcastGameUpdate(g)
+	h.scheduleBotMoveLocked(g)
+}
+
+// forfeitLocked ends an active game with the absent client losing.
+// Must be called with g.mu held and h.mu not held.
+func (h *Hub) forfeitLocked(g *WSGame, client *Client) {
+	seat := g.seatOf(client)
+	if seat == 0 || !g.game.IsActive {
+		return
+	}
+	log.Printf("[BACKEND-TAKEOVER] %s abandoned game %s and forfeits", client.username, g.game.ID)
+	g.game.EndByAbandonment(seat)
+	h.storeGameResult(g, g.game.Winner, false)
+	h.broadcastGameUpdate(g)
+}
+
+// restoreSeatLocked gives a substituted seat back to its returning player.
+// The game stays marked substituted. Must be called with g.mu held.
+func (h *Hub) restoreSeatLocked(g *WSGame, seat int) {
+	if seat == 0 || !g.substitutes[seat-1] {
+		return
+	}
+	log.Printf("[BACKEND-TAKEOVER] Seat %d of game %s returned to its player", seat, g.game.ID)
+	g.substitutes[seat-1] = false
+}
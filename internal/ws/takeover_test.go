@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+)
+
+// TestSeatReturnedOnReconnect lets the bot take over a disconnected
+// player's seat and play for them. When the player resumes, the seat is
+// theirs again: the bot stops moving for it and the player moves instead.
+func TestSeatReturnedOnReconnect(t *testing.T) {
+	h := NewHub()
+	policies := config.DefaultPolicies()
+	policy := policies.Modes[config.ModeFriend]
+	policy.BotTakeoverDelay = 20 * time.Millisecond
+	policy.BotThinkDelay = 10 * time.Millisecond
+	policy.ReconnectGrace = time.Minute
+	policies.Modes[config.ModeFriend] = policy
+	h.SetPolicies(policies)
+	srv := newPlayerServer(t, h)
+
+	alice, bob, start, _ := joinFriendGame(t, srv, "alice", "bob")
+	seat := start.You.Seat
+	h.mu.Lock()
+	g := h.activeGames[start.ID]
+	h.mu.Unlock()
+	inspect := func() (substituted bool, moves, turn int) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		return g.substitutes[seat-1], g.game.Board.MoveCount(), g.game.CurrentTurn
+	}
+
+	alice.conn.Close()
+	waitDisconnected(t, h, "alice")
+	waitFor(t, "the bot to play for alice", func() bool {
+		substituted, moves, _ := inspect()
+		return substituted && moves == 1
+	})
+
+	resumed := dialPlayer(t, srv)
+	resumed.send(t, MsgResume, "r1", map[string]string{"sessionToken": start.SessionToken})
+	msg := resumed.expect(t, MsgGameState)
+	resumed.expect(t, MsgAck)
+	var state GameStatePayload
+	if perr := msg.decodePayload(&state); perr != nil {
+		t.Fatal(perr)
+	}
+	if state.You == nil || state.You.Seat != seat || state.Board[len(state.Board)-1][3] != seat {
+		t.Fatalf("resumed into %s, want seat %d with the bot's move", msg.Payload, seat)
+	}
+	// The record still shows that a bot played part of the game
+	if !state.Substituted {
+		t.Error("the resumed game is not marked substituted")
+	}
+	if substituted, _, _ := inspect(); substituted {
+		t.Fatal("the bot still holds the seat after resuming")
+	}
+
+	bob.send(t, MsgMove, "b1", map[string]int{"column": 0})
+	bob.expect(t, MsgAck)
+	time.Sleep(10 * policy.BotThinkDelay)
+	if _, moves, turn := inspect(); moves != 2 || turn != seat {
+		t.Fatalf("%d moves played with seat %d to move, want 2 and alice's seat %d", moves, turn, seat)
+	}
+	resumed.send(t, MsgMove, "m1", map[string]int{"column": 6})
+	if ack := resumed.expect(t, MsgAck); ack.RequestID != "m1" {
+		t.Fatalf("acknowledged %q, want alice's move", ack.RequestID)
+	}
+	if substituted, moves, _ := inspect(); substituted || moves != 3 {
+		t.Errorf("after alice's move: substituted %v, %d moves; want false, 3", substituted, moves)
+	}
+
+	// Closing the sockets would hand both seats to the bot, which would
+	// play on against itself through the remaining tests
+	resumed.send(t, MsgExitGame, "x1", nil)
+	expectReply(t, resumed.peer, MsgAck, "x1")
+}
@@ -0,0 +1,99 @@
+package ws
+
+import (
+	"log"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/tournament"
+)
+
+// ResultListener is told about every finished game. winner is empty for
+// draws and games won by a bot.
+type ResultListener interface {
+	GameFinished(gameID, winner string, isDraw bool)
+}
+
+// AddResultListener registers l for the results of future games
+func (h *Hub) AddResultListener(l ResultListener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resultListeners = append(h.resultListeners, l)
+}
+
+// notifyResultUnsafe hands a game result to the listeners. They run on their
+// own goroutine so they may call back into the hub. Must be called with g.mu
+// and h.mu held.
+func (h *Hub) notifyResultUnsafe(g *WSGame, winner int, isDraw bool) {
+	if len(h.resultListeners) == 0 {
+		return
+	}
+	var winnerName string
+	if winner == 1 && !g.game.Player1.IsBot {
+		winnerName = g.game.Player1.Username
+	} else if winner == 2 && !g.game.Player2.IsBot {
+		winnerName = g.game.Player2.Username
+	}
+	listeners := append([]ResultListener(nil), h.resultListeners...)
+	gameID := g.game.ID
+	go func() {
+		for _, l := range listeners {
+			l.GameFinished(gameID, winnerName, isDraw)
+		}
+	}()
+}
+
+// StartTournamentGame seats two connected players in a tournament game.
+// Players who are offline or busy in another game are reported back so the
+// tournament can score a forfeit.
+func (h *Hub) StartTournamentGame(player1, player2 string, settings game.Settings) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients := make([]*Client, 2)
+	var unavailable []string
+	for i, username := range []string{player1, player2} {
+		c := h.findLocalClientUnsafe(username)
+		if c != nil && c.gameID != "" {
+			if g, exists := h.activeGames[c.gameID]; exists && g.isRunning() {
+				c = nil
+			}
+		}
+		if c == nil {
+			unavailable = append(unavailable, username)
+			continue
+		}
+		clients[i] = c
+	}
+	if len(unavailable) > 0 {
+		return "", &tournament.UnavailableError{Usernames: unavailable}
+	}
+
+	for _, c := range clients {
+		h.stopWaitingUnsafe(c)
+		h.cancelChallengesUnsafe(c)
+		// Leave the finished game the player may still be looking at; a
+		// rematch offered there lapses
+		c.gameID = ""
+	}
+
+	// Each tournament game stands on its own
+	settings.BestOf = 0
+	h.createGame(clients[0], clients[1], settings, config.ModeTournament, nil, "")
+	if clients[0].gameID == "" {
+		return "", &tournament.UnavailableError{Usernames: []string{player1, player2}}
+	}
+	log.Printf("[BACKEND-TOURNAMENT] Started game %s: %s vs %s", clients[0].gameID, player1, player2)
+	return clients[0].gameID, nil
+}
+
+// NotifyRoundStart tells a connected player that a tournament round started
+func (h *Hub) NotifyRoundStart(username string, notice tournament.RoundNotice) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.findLocalClientUnsafe(username).sendEnvelope(Envelope{
+		Type:    MsgTournamentRound,
+		GameID:  notice.GameID,
+		Payload: notice,
+	})
+}
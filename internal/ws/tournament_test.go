@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/connect4/backend/internal/config"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/tournament"
+)
+
+// TestTournamentRoundWithDisconnect plays a round in which one player
+// drops out and never comes back. The round must still end, with the
+// player who stayed winning, even when tournament games are configured to
+// be cancelled on abandonment.
+func TestTournamentRoundWithDisconnect(t *testing.T) {
+	h := NewHub()
+	policies := config.DefaultPolicies()
+	policy := policies.Modes[config.ModeTournament]
+	policy.Abandonment = config.AbandonCancel
+	policy.ReconnectGrace = 50 * time.Millisecond
+	policies.Modes[config.ModeTournament] = policy
+	h.SetPolicies(policies)
+	go h.Run()
+	sockets := newSocketServer(t)
+
+	manager := tournament.NewManager(h, h)
+	h.AddResultListener(manager)
+
+	stays := sockets.mustClient(t, h, "stays")
+	leaves := sockets.mustClient(t, h, "leaves")
+	created, err := manager.Create("cup", tournament.FormatRoundRobin, game.DefaultSettings(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []string{"stays", "leaves"} {
+		if err := manager.Register(created.ID, username); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := manager.Start(created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if currentGame(h, stays) == "" || currentGame(h, stays) != currentGame(h, leaves) {
+		t.Fatal("the players were not seated in the same game")
+	}
+
+	leaves.conn.Close()
+	h.handlePlayerDisconnect(leaves)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		v, err := manager.Get(created.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Status == tournament.StatusFinished {
+			p := v.Rounds[0][0]
+			winner := p.Player1
+			if p.Result == tournament.ResultLoss {
+				winner = p.Player2
+			}
+			if p.Result == tournament.ResultDraw || winner != "stays" {
+				t.Errorf("result %s %s %s, want a win for stays", p.Player1, p.Result, p.Player2)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("tournament still %s after a player abandoned its only game", v.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.activeGames) != 0 {
+		t.Errorf("%d games left after the tournament", len(h.activeGames))
+	}
+}
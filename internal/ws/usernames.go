@@ -0,0 +1,104 @@
+package ws
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxUsernameLength matches the players.username column, VARCHAR(50)
+const maxUsernameLength = 50
+
+// botUsername is the name every bot client plays under; players cannot take it
+const botUsername = "AI Bot"
+
+// maxUsernameSuffix bounds the search for a free suffixed name
+const maxUsernameSuffix = 100
+
+// validateUsername trims name and checks it is 1 to 50 letters, digits,
+// spaces, '_', '-' or '.'
+func validateUsername(name string) (string, *ProtocolError) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", newProtocolError(ErrCodeInvalidUsername, "username is required")
+	}
+	if utf8.RuneCountInString(name) > maxUsernameLength {
+		return "", newProtocolError(ErrCodeInvalidUsername, "username must be at most %d characters", maxUsernameLength)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" _-.", r) {
+			return "", newProtocolError(ErrCodeInvalidUsername, "username may only contain letters, digits, spaces, '_', '-' and '.'")
+		}
+	}
+	if strings.EqualFold(name, botUsername) {
+		return "", newProtocolError(ErrCodeInvalidUsername, "username %q is reserved", name)
+	}
+	return name, nil
+}
+
+// claimUsernameUnsafe checks that client may play as name and returns the
+// name to use. A name is held by any other connected player, and by a
+// disconnected player for their reconnect window unless token resumes that
+// player's session. With suffix set, a held name gets the first free numbered
+// suffix instead of being rejected. Must be called with h.mu held.
+func (h *Hub) claimUsernameUnsafe(client *Client, name, token string, suffix bool) (string, *ProtocolError) {
+	name, perr := validateUsername(name)
+	if perr != nil {
+		return "", perr
+	}
+	if reason, banned := h.bans[name]; banned {
+		return "", newProtocolError(ErrCodeBanned, "username %q is banned: %s", name, reason)
+	}
+	if h.usernameFreeUnsafe(client, name, token) {
+		return name, nil
+	}
+	if !suffix {
+		return "", newProtocolError(ErrCodeUsernameTaken, "username %q is already in use", name)
+	}
+
+	base := []rune(name)
+	for i := 2; i <= maxUsernameSuffix; i++ {
+		tail := fmt.Sprintf("-%d", i)
+		if keep := maxUsernameLength - len(tail); len(base) > keep {
+			base = base[:keep]
+		}
+		candidate := string(base) + tail
+		if _, banned := h.bans[candidate]; banned {
+			continue
+		}
+		if h.usernameFreeUnsafe(client, candidate, "") {
+			return candidate, nil
+		}
+	}
+	return "", newProtocolError(ErrCodeUsernameTaken, "username %q is already in use", name)
+}
+
+// usernameFreeUnsafe reports whether nobody but client holds name, on this
+// instance or another. Must be called with h.mu held.
+func (h *Hub) usernameFreeUnsafe(client *Client, name, token string) bool {
+	// Only the bot itself may use a registered bot's name
+	if _, registered := h.botAccounts[name]; registered && !client.botAccount {
+		return false
+	}
+	for other := range h.clients {
+		if other == client || other.isBot || other.username != name {
+			continue
+		}
+		// Joining with the holder's session token takes the seat back
+		if token != "" && other.sessionToken != "" &&
+			subtle.ConstantTimeCompare([]byte(other.sessionToken), []byte(token)) == 1 {
+			continue
+		}
+		return false
+	}
+	if h.backplane != nil {
+		for _, p := range h.backplane.ListPresence() {
+			if p.Username == name && p.InstanceID != h.instanceID {
+				return false
+			}
+		}
+	}
+	return true
+}
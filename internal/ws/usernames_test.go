@@ -0,0 +1,109 @@
+package ws
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestValidateUsername(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string // "" when the name is refused
+	}{
+		{"plain", "alice", "alice"},
+		{"trimmed", "  alice\t\n", "alice"},
+		{"allowed punctuation", "Dr. Bob_the-Builder 2", "Dr. Bob_the-Builder 2"},
+		{"letters beyond ASCII", "Zoë Ωmega 名前", "Zoë Ωmega 名前"},
+		{"digits only", "2024", "2024"},
+		{"empty", "", ""},
+		{"only spaces", "   ", ""},
+		{"one character", "x", "x"},
+		{"50 characters", strings.Repeat("a", 50), strings.Repeat("a", 50)},
+		{"51 characters", strings.Repeat("a", 51), ""},
+		{"50 characters of two bytes", strings.Repeat("é", 50), strings.Repeat("é", 50)},
+		{"51 characters of two bytes", strings.Repeat("é", 51), ""},
+		{"50 characters once trimmed", " " + strings.Repeat("a", 50) + " ", strings.Repeat("a", 50)},
+		{"colon", "al:ice", ""},
+		{"slash", "al/ice", ""},
+		{"at sign", "alice@example.com", ""},
+		{"angle brackets", "<script>", ""},
+		{"quote", `al"ice`, ""},
+		{"tab inside", "al\tice", ""},
+		{"newline inside", "al\nice", ""},
+		{"NUL", "al\x00ice", ""},
+		{"emoji", "alice 🙂", ""},
+		{"invalid UTF-8", "al\xffice", ""},
+		{"bot name", botUsername, ""},
+		{"bot name in another case", strings.ToUpper(botUsername), ""},
+		{"bot name with spaces around", "  ai bot ", ""},
+		{"bot name as a prefix", botUsername + " 2", botUsername + " 2"},
+	}
+	for _, tt := range tests {
+		got, perr := validateUsername(tt.input)
+		if tt.want == "" {
+			if perr == nil {
+				t.Errorf("%s: %q accepted as %q", tt.name, tt.input, got)
+			} else if perr.Code != ErrCodeInvalidUsername {
+				t.Errorf("%s: code %s, want %s", tt.name, perr.Code, ErrCodeInvalidUsername)
+			}
+			continue
+		}
+		if perr != nil {
+			t.Errorf("%s: %q refused: %v", tt.name, tt.input, perr)
+		} else if got != tt.want {
+			t.Errorf("%s: %q validated as %q, want %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestClaimUsername(t *testing.T) {
+	h := NewHub()
+	long := strings.Repeat("é", maxUsernameLength)
+	for _, name := range []string{"alice", "alice-2", long} {
+		h.clients[&Client{username: name}] = true
+	}
+	h.clients[&Client{username: "gone", sessionToken: "token"}] = true
+	h.botAccounts["deep-thought"] = "hash"
+	h.bans["mallory"] = "spam"
+	h.clients[&Client{username: "carol"}] = true
+	h.bans["carol-2"] = "spam"
+	h.bans["carol-3"] = "spam"
+
+	tests := []struct {
+		name    string
+		input   string
+		token   string
+		suffix  bool
+		want    string
+		errCode string
+	}{
+		{"free", "bob", "", false, "bob", ""},
+		{"invalid", "b@b", "", true, "", ErrCodeInvalidUsername},
+		{"banned", "mallory", "", true, "", ErrCodeBanned},
+		{"held", "alice", "", false, "", ErrCodeUsernameTaken},
+		{"held, suffixed", "alice", "", true, "alice-3", ""},
+		{"banned suffixes skipped", "carol", "", true, "carol-4", ""},
+		{"suffix kept within the length limit", long, "", true, strings.Repeat("é", maxUsernameLength-2) + "-2", ""},
+		{"held for reconnecting", "gone", "", false, "", ErrCodeUsernameTaken},
+		{"taken back with the session token", "gone", "token", false, "gone", ""},
+		{"wrong session token", "gone", "other", false, "", ErrCodeUsernameTaken},
+		{"registered bot", "deep-thought", "", false, "", ErrCodeUsernameTaken},
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, tt := range tests {
+		got, perr := h.claimUsernameUnsafe(&Client{}, tt.input, tt.token, tt.suffix)
+		code := ""
+		if perr != nil {
+			code = perr.Code
+		}
+		if got != tt.want || code != tt.errCode {
+			t.Errorf("%s: claimed %q, error %q; want %q, error %q", tt.name, got, code, tt.want, tt.errCode)
+		}
+		if utf8.RuneCountInString(got) > maxUsernameLength {
+			t.Errorf("%s: %q is longer than %d characters", tt.name, got, maxUsernameLength)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package ws
+
+import (
+	"context"
+	"errors"
+
+	"github.com/connect4/backend/internal/database"
+	"github.com/connect4/backend/internal/game"
+	"github.com/connect4/backend/internal/tournament"
+	"github.com/connect4/backend/internal/webhook"
+)
+
+// webhookDeliveryLimit is how many delivery attempts the admin API lists
+const webhookDeliveryLimit = 50
+
+// Abandonment reasons reported in game.abandoned events
+const (
+	AbandonedExited       = "exited"       // a player left the game
+	AbandonedDisconnected = "disconnected" // a player did not come back in time
+	AbandonedAborted      = "aborted"      // an operator stopped the game
+)
+
+// WebhookPlayer is a seat in the data of game webhook events
+type WebhookPlayer struct {
+	Username   string `json:"username"`
+	IsBot      bool   `json:"isBot,omitempty"`
+	BotAccount bool   `json:"botAccount,omitempty"`
+	Rating     int    `json:"rating,omitempty"`
+}
+
+// WebhookGamePayload is the data of game.started, game.finished and
+// game.abandoned events. Winner is empty for draws and unfinished games.
+type WebhookGamePayload struct {
+	GameID    string           `json:"gameId"`
+	Mode      string           `json:"mode"`
+	Players   [2]WebhookPlayer `json:"players"`
+	Settings  game.Settings    `json:"settings"`
+	Moves     int              `json:"moves"`
+	Winner    string           `json:"winner,omitempty"`
+	IsDraw    bool             `json:"isDraw,omitempty"`
+	EndReason string           `json:"endReason,omitempty"`
+	Reason    string           `json:"reason,omitempty"` // why a game was abandoned
+}
+
+// SetWebhooks sends game and tournament events to the webhooks registered
+// with d (optional)
+func (h *Hub) SetWebhooks(d *webhook.Dispatcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.webhooks = d
+}
+
+// emitGameEventLocked sends a game event to the webhooks. winner is the
+// winning seat, 0 for none. Must be called with g.mu held.
+func (h *Hub) emitGameEventLocked(g *WSGame, eventType string, winner int, reason string) {
+	if h.webhooks == nil {
+		return
+	}
+	payload := WebhookGamePayload{
+		GameID:   g.game.ID,
+		Mode:     g.mode,
+		Settings: g.game.Settings,
+		Moves:    g.game.Board.MoveCount(),
+		Reason:   reason,
+	}
+	for i, p := range [2]game.Player{g.game.Player1, g.game.Player2} {
+		payload.Players[i] = WebhookPlayer{Username: p.Username, IsBot: p.IsBot, BotAccount: p.BotAccount, Rating: p.Rating}
+		if winner == i+1 {
+			payload.Winner = p.Username
+		}
+	}
+	if eventType == webhook.EventGameFinished {
+		payload.IsDraw = winner == 0
+		payload.EndReason = g.game.EndReason
+	}
+	h.webhooks.Emit(eventType, payload)
+}
+
+// RoundFinished sends a tournament.round_finished event to the webhooks
+func (h *Hub) RoundFinished(summary tournament.RoundSummary) {
+	if h.webhooks != nil {
+		h.webhooks.Emit(webhook.EventRoundFinished, summary)
+	}
+}
+
+// webhookError maps a dispatcher error to a protocol error
+func webhookError(err error) *ProtocolError {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		return newProtocolError(ErrCodeNoSuchWebhook, "%v", err)
+	case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrUnknownEvent):
+		return newProtocolError(ErrCodeInvalidMessage, "%v", err)
+	}
+	return newProtocolError(ErrCodeInternal, "%v", err)
+}
+
+// requireWebhooks returns the dispatcher, or an error when webhooks are off
+func (h *Hub) requireWebhooks() (*webhook.Dispatcher, *ProtocolError) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.webhooks == nil {
+		return nil, newProtocolError(ErrCodeNotAllowed, "webhooks are not enabled")
+	}
+	return h.webhooks, nil
+}
+
+// AdminRegisterWebhook registers url for events, or every event when events
+// is empty. The result holds the signing secret, which is not shown again.
+func (h *Hub) AdminRegisterWebhook(url string, events []string) (*database.Webhook, *ProtocolError) {
+	d, perr := h.requireWebhooks()
+	if perr != nil {
+		return nil, perr
+	}
+	hook, err := d.Register(context.Background(), url, events)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return hook, nil
+}
+
+// AdminDeleteWebhook removes a webhook and its delivery log
+func (h *Hub) AdminDeleteWebhook(id int) *ProtocolError {
+	d, perr := h.requireWebhooks()
+	if perr != nil {
+		return perr
+	}
+	if err := d.Delete(context.Background(), id); err != nil {
+		return webhookError(err)
+	}
+	return nil
+}
+
+// AdminWebhooks returns the registered webhooks without their secrets
+func (h *Hub) AdminWebhooks() ([]database.Webhook, *ProtocolError) {
+	d, perr := h.requireWebhooks()
+	if perr != nil {
+		return nil, perr
+	}
+	return d.List(), nil
+}
+
+// AdminWebhookDeliveries returns the latest delivery attempts of a webhook
+func (h *Hub) AdminWebhookDeliveries(id int) ([]database.WebhookDelivery, *ProtocolError) {
+	d, perr := h.requireWebhooks()
+	if perr != nil {
+		return nil, perr
+	}
+	deliveries, err := d.Deliveries(context.Background(), id, webhookDeliveryLimit)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	return deliveries, nil
+}